@@ -0,0 +1,68 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/zalando/go-keyring"
+)
+
+const (
+	// StateKeyEnv holds the base64 state encryption key; it takes precedence over the keychain
+	StateKeyEnv = "STAR_WATCHER_STATE_KEY"
+	// NewStateKeyEnv holds the key that "state rekey" moves to when StateKeyEnv is used
+	NewStateKeyEnv = "STAR_WATCHER_NEW_STATE_KEY"
+
+	keychainStateKeyUser        = "state-key"
+	keychainPendingStateKeyUser = "state-key-next"
+)
+
+// GetStateKey returns the base64 state encryption key and where it was found:
+// 1. STAR_WATCHER_STATE_KEY environment variable
+// 2. OS keychain
+// An empty key means no key is configured.
+func GetStateKey() (key string, source string) {
+	if envKey := os.Getenv(StateKeyEnv); envKey != "" {
+		return envKey, "environment"
+	}
+	if keychainKey, err := keyring.Get(keychainService, keychainStateKeyUser); err == nil && keychainKey != "" {
+		return keychainKey, "keychain"
+	}
+	return "", ""
+}
+
+// GetPendingStateKey returns the key of an unfinished "state rekey" from the keychain,
+// or an empty key when no rekey is in progress
+func GetPendingStateKey() string {
+	key, err := keyring.Get(keychainService, keychainPendingStateKeyUser)
+	if err != nil {
+		return ""
+	}
+	return key
+}
+
+// StoreStateKey stores the state encryption key in the OS keychain
+func StoreStateKey(key string) error {
+	if err := keyring.Set(keychainService, keychainStateKeyUser, key); err != nil {
+		return fmt.Errorf("failed to store state key in keychain: %v", err)
+	}
+	return nil
+}
+
+// StorePendingStateKey stores the key of a "state rekey" in the OS keychain before
+// any state is sealed with it, so an interrupted rekey can still open that state
+func StorePendingStateKey(key string) error {
+	if err := keyring.Set(keychainService, keychainPendingStateKeyUser, key); err != nil {
+		return fmt.Errorf("failed to store pending state key in keychain: %v", err)
+	}
+	return nil
+}
+
+// RemovePendingStateKey removes the key of a finished "state rekey" from the OS keychain
+func RemovePendingStateKey() error {
+	if err := keyring.Delete(keychainService, keychainPendingStateKeyUser); err != nil && !errors.Is(err, keyring.ErrNotFound) {
+		return fmt.Errorf("failed to remove pending state key from keychain: %v", err)
+	}
+	return nil
+}
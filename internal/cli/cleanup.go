@@ -3,26 +3,25 @@ package cli
 import (
 	"fmt"
 	"log"
-	"os"
-	"path/filepath"
-	"strings"
 
+	"github.com/akme/gh-stars-watcher/internal/storage"
 	"github.com/spf13/cobra"
 )
 
 // cleanupCmd represents the cleanup command
 var cleanupCmd = &cobra.Command{
 	Use:   "cleanup [username]",
-	Short: "Remove stored state files for a user",
-	Long: `Remove stored state files for a specific user or all users.
+	Short: "Remove stored state for a user",
+	Long: `Remove the stored state of a specific user or all users.
 
-This command permanently deletes the stored baseline state, which means the next
-monitor run will establish a new baseline from the current starred repositories.
+This command permanently deletes the stored baseline state together with its snapshots and
+event history, which means the next monitor run will establish a new baseline from the
+current starred repositories.
 
 Examples:
   star-watcher cleanup octocat              # Remove state for specific user
   star-watcher cleanup octocat --state-file ./custom-state.json
-  star-watcher cleanup --all               # Remove all state files (use with caution)`,
+  star-watcher cleanup --all               # Remove the state of every user (use with caution)`,
 	Args: cobra.MaximumNArgs(1),
 	RunE: runCleanup,
 }
@@ -30,16 +29,26 @@ Examples:
 var cleanupAll bool
 
 func init() {
-	cleanupCmd.Flags().BoolVar(&cleanupAll, "all", false, "remove all state files (use with caution)")
+	cleanupCmd.Flags().BoolVar(&cleanupAll, "all", false, "remove the state of every user (use with caution)")
 }
 
 func runCleanup(cmd *cobra.Command, args []string) error {
-	if cleanupAll {
-		return cleanupAllStateFiles()
+	if !cleanupAll && len(args) == 0 {
+		return fmt.Errorf("username required unless --all flag is specified")
 	}
 
-	if len(args) == 0 {
-		return fmt.Errorf("username required unless --all flag is specified")
+	loaded, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	store, err := openStorage(loaded.Config)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	if cleanupAll {
+		return cleanupAllStates(store)
 	}
 
 	username := args[0]
@@ -49,35 +58,22 @@ func runCleanup(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("invalid GitHub username format: %s", username)
 	}
 
-	return cleanupUserStateFile(username)
+	return cleanupUserState(store, username)
 }
 
-func cleanupUserStateFile(username string) error {
-	statePath := getStateFilePath(username)
-
+func cleanupUserState(store storage.Store, username string) error {
 	if verbose {
-		log.Printf("Cleaning up state file: %s", statePath)
-	}
-
-	// Check if state file exists
-	if _, err := os.Stat(statePath); os.IsNotExist(err) {
-		if !quiet {
-			fmt.Printf("No state file found for user: %s\n", username)
-		}
-		return nil
-	}
-
-	// Remove the state file
-	if err := os.Remove(statePath); err != nil {
-		return fmt.Errorf("failed to remove state file %s: %v", statePath, err)
+		log.Printf("Cleaning up state of user: %s", username)
 	}
 
-	// Also remove backup file if it exists
-	backupPath := statePath + ".bak"
-	if _, err := os.Stat(backupPath); err == nil {
-		if err := os.Remove(backupPath); err != nil {
-			log.Printf("Warning: failed to remove backup file %s: %v", backupPath, err)
+	if err := store.Delete(username); err != nil {
+		if _, ok := err.(*storage.StateNotFoundError); ok {
+			if !quiet {
+				fmt.Printf("No state found for user: %s\n", username)
+			}
+			return nil
 		}
+		return err
 	}
 
 	if !quiet {
@@ -87,62 +83,30 @@ func cleanupUserStateFile(username string) error {
 	return nil
 }
 
-func cleanupAllStateFiles() error {
+func cleanupAllStates(store storage.Store) error {
 	if verbose {
-		log.Printf("Cleaning up all state files...")
-	}
-
-	// Get the default state directory
-	homeDir, err := os.UserHomeDir()
-	if err != nil {
-		return fmt.Errorf("failed to get home directory: %v", err)
-	}
-
-	stateDir := filepath.Join(homeDir, ".star-watcher")
-
-	// Check if state directory exists
-	if _, err := os.Stat(stateDir); os.IsNotExist(err) {
-		if !quiet {
-			fmt.Println("No state directory found.")
-		}
-		return nil
+		log.Printf("Cleaning up all states...")
 	}
 
-	// Read directory contents
-	entries, err := os.ReadDir(stateDir)
+	usernames, err := store.List()
 	if err != nil {
-		return fmt.Errorf("failed to read state directory: %v", err)
+		return err
 	}
 
 	removedCount := 0
-	for _, entry := range entries {
-		if entry.IsDir() {
+	for _, username := range usernames {
+		if err := store.Delete(username); err != nil {
+			log.Printf("Warning: failed to remove state of %s: %v", username, err)
 			continue
 		}
-
-		filename := entry.Name()
-		if strings.HasSuffix(filename, ".json") || strings.HasSuffix(filename, ".bak") {
-			filePath := filepath.Join(stateDir, filename)
-			if err := os.Remove(filePath); err != nil {
-				log.Printf("Warning: failed to remove %s: %v", filePath, err)
-			} else {
-				removedCount++
-				if verbose {
-					log.Printf("Removed: %s", filePath)
-				}
-			}
-		}
-	}
-
-	// Try to remove the directory if it's empty
-	if err := os.Remove(stateDir); err != nil {
+		removedCount++
 		if verbose {
-			log.Printf("State directory not empty or could not be removed: %v", err)
+			log.Printf("Removed state of %s", username)
 		}
 	}
 
 	if !quiet {
-		fmt.Printf("Cleaned up %d state files.\n", removedCount)
+		fmt.Printf("Cleaned up %d user states.\n", removedCount)
 	}
 
 	return nil
@@ -0,0 +1,196 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/akme/gh-stars-watcher/internal/config"
+	"github.com/spf13/cobra"
+)
+
+// configCmd groups the configuration subcommands
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Show, validate and edit the star-watcher configuration",
+	Long: `Inspect and edit the configuration used by the monitor.
+
+The effective configuration is built from the built-in defaults, the config file
+(~/.config/star-watcher/config.yaml or --config) and STAR_WATCHER_* environment variables,
+in that order of precedence.
+
+Examples:
+  star-watcher config show
+  star-watcher config validate --config ./staging.yaml
+  star-watcher config init
+  star-watcher config set incremental.max_incremental_pages 20`,
+}
+
+var configShowCmd = &cobra.Command{
+	Use:   "show",
+	Short: "Print the effective configuration and where each value comes from",
+	Args:  cobra.NoArgs,
+	RunE:  runConfigShow,
+}
+
+var configValidateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Validate the configuration file and environment overrides",
+	Args:  cobra.NoArgs,
+	RunE:  runConfigValidate,
+}
+
+var configInitCmd = &cobra.Command{
+	Use:   "init",
+	Short: "Write a commented config file with the default values",
+	Args:  cobra.NoArgs,
+	RunE:  runConfigInit,
+}
+
+var configSetCmd = &cobra.Command{
+	Use:   "set <key> <value>",
+	Short: "Set a single value in the config file",
+	Long: `Set a single value in the config file, keeping comments and other values intact.
+
+Keys use the dotted form shown by "config show", e.g. retry.max_delay.
+Durations accept Go syntax (30s, 5m, 1h30m); lists are comma-separated.`,
+	Args: cobra.ExactArgs(2),
+	RunE: runConfigSet,
+}
+
+var configInitForce bool
+
+func init() {
+	configInitCmd.Flags().BoolVar(&configInitForce, "force", false, "overwrite an existing config file")
+
+	configCmd.AddCommand(configShowCmd)
+	configCmd.AddCommand(configValidateCmd)
+	configCmd.AddCommand(configInitCmd)
+	configCmd.AddCommand(configSetCmd)
+}
+
+// configValue is a single row of `config show`
+type configValue struct {
+	Key    string        `json:"key"`
+	Value  string        `json:"value"`
+	Source config.Source `json:"source"`
+	Env    string        `json:"env,omitempty"`
+}
+
+func runConfigShow(cmd *cobra.Command, args []string) error {
+	loaded, err := config.Read(configFile)
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+
+	values := make([]configValue, 0, len(loaded.Sources))
+	for _, key := range loaded.Config.Keys() {
+		value, _ := loaded.Config.Get(key)
+		if value != "" && loaded.Config.IsSecret(key) {
+			value = "********"
+		}
+		row := configValue{Key: key, Value: value, Source: loaded.Sources[key]}
+		if row.Source == config.SourceEnv {
+			row.Env = config.EnvName(key)
+		}
+		values = append(values, row)
+	}
+
+	if output == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(struct {
+			Path   string        `json:"path,omitempty"`
+			Values []configValue `json:"values"`
+		}{
+			Path:   loaded.Path,
+			Values: values,
+		})
+	}
+
+	if loaded.Path != "" {
+		fmt.Printf("Config file: %s\n\n", loaded.Path)
+	} else {
+		fmt.Printf("Config file: none (using defaults)\n\n")
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "KEY\tVALUE\tSOURCE")
+	for _, row := range values {
+		source := string(row.Source)
+		if row.Env != "" {
+			source += " (" + row.Env + ")"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", row.Key, row.Value, source)
+	}
+	return w.Flush()
+}
+
+func runConfigValidate(cmd *cobra.Command, args []string) error {
+	loaded, err := config.Read(configFile)
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+
+	if err := loaded.Config.Validate(); err != nil {
+		var validationErr *config.ValidationError
+		if errors.As(err, &validationErr) && !quiet {
+			fmt.Fprintf(os.Stderr, "Configuration is invalid:\n")
+			for _, fieldErr := range validationErr.Errors {
+				fmt.Fprintf(os.Stderr, "  %s (%s): %s\n", fieldErr.Field, loaded.Sources[fieldErr.Field], fieldErr.Message)
+			}
+		}
+		return err
+	}
+
+	if !quiet {
+		if loaded.Path != "" {
+			fmt.Printf("Configuration is valid: %s\n", loaded.Path)
+		} else {
+			fmt.Println("Configuration is valid (no config file, using defaults)")
+		}
+	}
+	return nil
+}
+
+func runConfigInit(cmd *cobra.Command, args []string) error {
+	path, _, err := config.ResolvePath(configFile)
+	if err != nil {
+		return err
+	}
+
+	if err := config.WriteDefaultFile(path, configInitForce); err != nil {
+		return err
+	}
+
+	if !quiet {
+		fmt.Printf("Wrote default configuration to %s\n", path)
+	}
+	return nil
+}
+
+func runConfigSet(cmd *cobra.Command, args []string) error {
+	path, _, err := config.ResolvePath(configFile)
+	if err != nil {
+		return err
+	}
+
+	key, value := args[0], args[1]
+	if err := config.SetFileValue(path, key, value); err != nil {
+		return err
+	}
+
+	if !quiet {
+		shown := value
+		if shown != "" && config.DefaultConfig().IsSecret(key) {
+			shown = "********"
+		}
+		fmt.Printf("Set %s = %s in %s\n", key, shown, path)
+		if _, overridden := os.LookupEnv(config.EnvName(key)); overridden {
+			fmt.Fprintf(os.Stderr, "Note: %s is overridden by %s in the current environment\n", key, config.EnvName(key))
+		}
+	}
+	return nil
+}
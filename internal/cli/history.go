@@ -0,0 +1,157 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/akme/gh-stars-watcher/internal/storage"
+	"github.com/spf13/cobra"
+)
+
+// historyCmd represents the history command
+var historyCmd = &cobra.Command{
+	Use:   "history [username or usernames]",
+	Short: "Show past star, unstar, re-star and update events",
+	Long: `Show the star changes recorded in the event log of one or more users.
+
+Events from all users are merged and sorted chronologically. --since and --until accept
+a date (2026-03-01), an RFC 3339 time or a duration back from now such as 12h, 30d or 2w.
+A date given to --until includes that whole day. Baseline events recorded on the first
+run of a user are only shown with --event baseline.
+
+Examples:
+  star-watcher history alice --since 30d --event star
+  star-watcher history alice,bob --since 2026-03-01 --until 2026-03-31 --output table
+  star-watcher history alice --language go --output json`,
+	Args: cobra.ExactArgs(1),
+	RunE: runHistory,
+}
+
+var (
+	historySince    string
+	historyUntil    string
+	historyEvents   []string
+	historyLanguage string
+)
+
+// historyEventTypes maps --event values to event types
+var historyEventTypes = map[string]string{
+	"star":     storage.EventStar,
+	"unstar":   storage.EventUnstar,
+	"restar":   storage.EventReStar,
+	"re_star":  storage.EventReStar,
+	"update":   storage.EventUpdate,
+	"rename":   storage.EventRename,
+	"baseline": storage.EventBaseline,
+}
+
+func init() {
+	historyCmd.Flags().StringVar(&historySince, "since", "", "only events at or after this date, time or duration ago (e.g. 2026-03-01, 30d)")
+	historyCmd.Flags().StringVar(&historyUntil, "until", "", "only events before this date, time or duration ago")
+	historyCmd.Flags().StringSliceVar(&historyEvents, "event", nil, "event types to show: star, unstar, restar, update, rename, baseline (default: all but baseline)")
+	historyCmd.Flags().StringVar(&historyLanguage, "language", "", "only repositories in this language")
+}
+
+func runHistory(cmd *cobra.Command, args []string) error {
+	usernames, err := parseUsernames(args[0])
+	if err != nil {
+		return err
+	}
+
+	filter, err := historyFilter(time.Now())
+	if err != nil {
+		return err
+	}
+
+	loaded, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	store, err := openStorage(loaded.Config)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	var events []storage.Event
+	for _, username := range usernames {
+		userEvents, err := store.Events(username, filter)
+		if err != nil {
+			return fmt.Errorf("failed to read history of %s: %w", username, err)
+		}
+		events = append(events, userEvents...)
+	}
+
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].Timestamp.Before(events[j].Timestamp)
+	})
+
+	return NewOutputFormatter(os.Stdout, output).FormatHistory(events)
+}
+
+// historyFilter builds the event filter from the history flags
+func historyFilter(now time.Time) (storage.EventFilter, error) {
+	filter := storage.EventFilter{Language: historyLanguage}
+
+	var err error
+	if historySince != "" {
+		if filter.Since, err = parseHistoryTime(historySince, now, false); err != nil {
+			return filter, fmt.Errorf("invalid --since: %v", err)
+		}
+	}
+	if historyUntil != "" {
+		if filter.Until, err = parseHistoryTime(historyUntil, now, true); err != nil {
+			return filter, fmt.Errorf("invalid --until: %v", err)
+		}
+	}
+	if !filter.Since.IsZero() && !filter.Until.IsZero() && !filter.Since.Before(filter.Until) {
+		return filter, fmt.Errorf("--since must be before --until")
+	}
+
+	if len(historyEvents) == 0 {
+		filter.Types = []string{storage.EventStar, storage.EventUnstar, storage.EventReStar, storage.EventUpdate, storage.EventRename}
+	}
+	for _, name := range historyEvents {
+		eventType, ok := historyEventTypes[strings.ToLower(strings.TrimSpace(name))]
+		if !ok {
+			return filter, fmt.Errorf("invalid --event %q: must be one of star, unstar, restar, update, rename, baseline", name)
+		}
+		filter.Types = append(filter.Types, eventType)
+	}
+
+	return filter, nil
+}
+
+// parseHistoryTime parses a date, RFC 3339 time or a duration before now (90m, 12h, 30d, 2w).
+// A date marks the start of that local day, or the end of it when endOfDay is set.
+func parseHistoryTime(value string, now time.Time, endOfDay bool) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		if endOfDay {
+			return t.AddDate(0, 0, 1), nil
+		}
+		return t, nil
+	}
+
+	if n := len(value); n > 1 {
+		if count, err := strconv.Atoi(value[:n-1]); err == nil && count >= 0 {
+			switch value[n-1] {
+			case 'd':
+				return now.AddDate(0, 0, -count), nil
+			case 'w':
+				return now.AddDate(0, 0, -7*count), nil
+			}
+		}
+	}
+	if d, err := time.ParseDuration(value); err == nil && d >= 0 {
+		return now.Add(-d), nil
+	}
+
+	return time.Time{}, fmt.Errorf("%q is not a date (2006-01-02), RFC 3339 time or duration (12h, 30d, 2w)", value)
+}
@@ -5,15 +5,16 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
 	"regexp"
 	"strings"
-	"sync"
+	"time"
 
 	"github.com/akme/gh-stars-watcher/internal/auth"
 	"github.com/akme/gh-stars-watcher/internal/config"
 	"github.com/akme/gh-stars-watcher/internal/github"
 	"github.com/akme/gh-stars-watcher/internal/monitor"
-	"github.com/akme/gh-stars-watcher/internal/storage"
+	"github.com/akme/gh-stars-watcher/internal/watchlist"
 	"github.com/spf13/cobra"
 )
 
@@ -26,7 +27,19 @@ var monitorCmd = &cobra.Command{
 	Short: "Monitor GitHub user(s) starred repositories for changes",
 	Long: `Monitor one or more GitHub users' starred repositories and display only newly starred repositories since the last run.
 
-For single users, the command works as before. For multiple users, provide a comma-separated list.
+For single users, the command works as before. For multiple users, provide a comma-separated list,
+a file with --users-file (one username per line, # starts a comment), or both. Without either,
+every user in the watchlist is monitored (see the users command).
+
+--org and --team monitor every member of a GitHub organization or team, together with any
+usernames given. Members are cached in the state directory and looked up again after
+monitor.member_refresh_interval (default 6h); members who joined or left since the previous
+lookup are listed in the summary. Listing team members requires a token (--auth) that can
+read the organization.
+
+--following does the same for the accounts a user follows, for a feed of what they star.
+Accounts followed since the previous lookup start with a silent baseline, like a first run;
+unfollowed accounts are no longer checked.
 On the first run, this command establishes a baseline of currently starred repositories and shows no output.
 Subsequent runs compare against the stored state and display only newly starred repositories.
 
@@ -34,14 +47,52 @@ By default, the tool uses unauthenticated GitHub API access (60 requests/hour).
 
 Examples:
   star-watcher monitor octocat
+  star-watcher monitor
+  star-watcher monitor --users-file ./team.txt
+  star-watcher monitor --org acme
+  star-watcher monitor --team acme/platform --auth
+  star-watcher monitor --following octocat
   star-watcher monitor octocat,github,torvalds --output json
+  star-watcher monitor user1,user2,user3 --concurrency 2
   star-watcher monitor user1,user2 --verbose
   star-watcher monitor octocat --auth --verbose
-  star-watcher monitor octocat --state-file ./custom-state.json`,
-	Args: cobra.ExactArgs(1),
+  star-watcher monitor octocat --state-file ./custom-state.json
+  star-watcher monitor octocat --config ./star-watcher.yaml
+  star-watcher monitor octocat --on-change ./notify.sh
+  star-watcher monitor octocat --template ./stars.tmpl
+  star-watcher monitor octocat --lock-timeout 2m
+  star-watcher monitor octocat --accept-large-changes`,
+	Args: cobra.MaximumNArgs(1),
 	RunE: runMonitor,
 }
 
+var (
+	// acceptLargeChanges is the --accept-large-changes flag of the monitor and watch commands
+	acceptLargeChanges bool
+	// concurrency is the --concurrency flag of the monitor and watch commands; 0 uses the config
+	concurrency int
+	// lockTimeout is the --lock-timeout flag of the monitor and watch commands
+	lockTimeout string
+	// usersFile is the --users-file flag of the monitor and watch commands
+	usersFile string
+	// orgs, teams and following are the --org, --team and --following flags of the monitor command
+	orgs      []string
+	teams     []string
+	following []string
+)
+
+func init() {
+	for _, cmd := range []*cobra.Command{monitorCmd, watchCmd} {
+		cmd.Flags().BoolVar(&acceptLargeChanges, "accept-large-changes", false, "save runs whose starred repositories dropped by more than safety.max_drop_percent")
+		cmd.Flags().IntVar(&concurrency, "concurrency", 0, "users monitored at the same time (default: monitor.concurrency from config, 4)")
+		cmd.Flags().StringVar(&lockTimeout, "lock-timeout", "", "how long to wait while another run of the same user holds its lock (default: storage.lock_timeout from config, 30s)")
+		cmd.Flags().StringVar(&usersFile, "users-file", "", "file with one username per line, added to the usernames given as argument")
+	}
+	monitorCmd.Flags().StringSliceVar(&orgs, "org", nil, "monitor every member of a GitHub organization (repeatable)")
+	monitorCmd.Flags().StringSliceVar(&teams, "team", nil, "monitor every member of a team, given as org/team (repeatable)")
+	monitorCmd.Flags().StringSliceVar(&following, "following", nil, "monitor every account a user follows (repeatable)")
+}
+
 // parseUsernames parses the input string as either a single username or comma-separated usernames
 func parseUsernames(input string) ([]string, error) {
 	// Split by comma and trim whitespace
@@ -69,14 +120,114 @@ func parseUsernames(input string) ([]string, error) {
 	return usernames, nil
 }
 
+// argumentUsernames returns the users given as argument and in --users-file, without
+// duplicates. It returns none when neither is given.
+func argumentUsernames(args []string) ([]string, error) {
+	var candidates []string
+	if len(args) > 0 {
+		candidates = append(candidates, strings.Split(args[0], ",")...)
+	}
+	if usersFile != "" {
+		fileUsernames, err := watchlist.ReadUsersFile(usersFile)
+		if err != nil {
+			return nil, err
+		}
+		if len(fileUsernames) == 0 {
+			return nil, fmt.Errorf("users file %s lists no users", usersFile)
+		}
+		candidates = append(candidates, fileUsernames...)
+	}
+	if len(candidates) == 0 {
+		return nil, nil
+	}
+	return parseUsernames(strings.Join(uniqueUsernames(candidates), ","))
+}
+
+// uniqueUsernames drops blank and repeated usernames, ignoring case, keeping the first
+// spelling of each
+func uniqueUsernames(candidates []string) []string {
+	seen := make(map[string]bool)
+	var unique []string
+	for _, username := range candidates {
+		username = strings.TrimSpace(username)
+		key := strings.ToLower(username)
+		if key != "" && !seen[key] {
+			seen[key] = true
+			unique = append(unique, username)
+		}
+	}
+	return unique
+}
+
+// monitorUsernames returns the users to monitor: those given as argument and in
+// --users-file, or every user in the watchlist
+func monitorUsernames(args []string) ([]string, error) {
+	usernames, err := argumentUsernames(args)
+	if err != nil || len(usernames) > 0 {
+		return usernames, err
+	}
+
+	list, err := loadWatchlist()
+	if err != nil {
+		return nil, err
+	}
+	if len(list.Usernames()) == 0 {
+		return nil, fmt.Errorf("no users given: pass usernames, --users-file, or add users with 'star-watcher users add'")
+	}
+	return parseUsernames(strings.Join(list.Usernames(), ","))
+}
+
+// memberSources returns the organizations, teams and followed accounts given with --org,
+// --team and --following
+func memberSources() ([]monitor.MemberSource, error) {
+	var sources []monitor.MemberSource
+	for _, org := range orgs {
+		if !githubUsernamePattern.MatchString(org) {
+			return nil, fmt.Errorf("invalid GitHub organization: %s", org)
+		}
+		sources = append(sources, monitor.MemberSource{Org: org})
+	}
+	for _, team := range teams {
+		source, err := monitor.ParseTeam(team)
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, source)
+	}
+	for _, username := range following {
+		if !githubUsernamePattern.MatchString(username) {
+			return nil, fmt.Errorf("invalid GitHub username format: %s", username)
+		}
+		sources = append(sources, monitor.MemberSource{Following: username})
+	}
+	return sources, nil
+}
+
 func runMonitor(cmd *cobra.Command, args []string) error {
-	usernames, err := parseUsernames(args[0])
+	sources, err := memberSources()
+	if err != nil {
+		return err
+	}
+
+	// Organizations, teams and followed accounts replace the watchlist as the default users
+	var usernames []string
+	if len(sources) > 0 {
+		usernames, err = argumentUsernames(args)
+	} else {
+		usernames, err = monitorUsernames(args)
+	}
 	if err != nil {
 		return err
 	}
 
 	if verbose {
-		if len(usernames) == 1 {
+		if len(sources) > 0 {
+			names := make([]string, len(sources))
+			for i, source := range sources {
+				names[i] = source.String()
+			}
+			log.Printf("Starting monitor for the members of %s", strings.Join(names, ", "))
+		} else if len(usernames) == 1 {
 			log.Printf("Starting monitor for user: %s", usernames[0])
 		} else {
 			log.Printf("Starting monitor for %d users: %s", len(usernames), strings.Join(usernames, ", "))
@@ -87,28 +238,34 @@ func runMonitor(cmd *cobra.Command, args []string) error {
 	ctx := cmd.Context()
 
 	// Handle single user (existing behavior)
-	if len(usernames) == 1 {
+	if len(usernames) == 1 && len(sources) == 0 {
 		return runSingleUserMonitor(ctx, usernames[0])
 	}
 
 	// Handle multiple users
-	return runMultiUserMonitor(ctx, usernames)
+	return runMultiUserMonitor(ctx, usernames, sources)
 }
 
 // runSingleUserMonitor handles monitoring for a single user (preserves existing behavior)
 func runSingleUserMonitor(ctx context.Context, username string) error {
-	if verbose {
-		log.Printf("State file: %s", getStateFilePath(username))
+	cfg, err := loadMonitorConfig()
+	if err != nil {
+		return err
+	}
+
+	formatter, err := newMonitorFormatter(cfg)
+	if err != nil {
+		return err
 	}
 
 	// Create monitoring service with real implementations
-	service, err := createMonitoringService()
+	service, err := createMonitoringService(ctx, cfg)
 	if err != nil {
 		return fmt.Errorf("failed to create monitoring service: %w", err)
 	}
 
 	// Execute monitoring
-	result, err := service.MonitorUser(ctx, username, getStateFilePath(username))
+	result, err := service.MonitorUser(ctx, username)
 	if err != nil {
 		if !quiet && output != "json" {
 			fmt.Print("\r\033[K") // Clear the line completely before error
@@ -121,80 +278,137 @@ func runSingleUserMonitor(ctx context.Context, username string) error {
 	}
 
 	// Format and display results
-	formatter := NewOutputFormatter(os.Stdout, output)
-	return formatter.FormatMonitorResult(result)
+	if err := formatter.FormatMonitorResult(result); err != nil {
+		return err
+	}
+
+	dispatchNotifications(ctx, newNotificationDispatcher(cfg, service.Logger()), map[string]*monitor.MonitorResult{username: result})
+	return nil
 }
 
-// runMultiUserMonitor handles monitoring for multiple users with parallel processing
-func runMultiUserMonitor(ctx context.Context, usernames []string) error {
-	results := make(map[string]*monitor.MonitorResult)
-	errors := make(map[string]error)
-	var mu sync.Mutex
-	var wg sync.WaitGroup
+// runMultiUserMonitor handles monitoring for multiple users with parallel processing,
+// adding the members of sources to usernames
+func runMultiUserMonitor(ctx context.Context, usernames []string, sources []monitor.MemberSource) error {
+	cfg, err := loadMonitorConfig()
+	if err != nil {
+		return err
+	}
+
+	formatter, err := newMonitorFormatter(cfg)
+	if err != nil {
+		return err
+	}
 
 	// Create monitoring service (shared for all users)
-	service, err := createMonitoringService()
+	service, err := createMonitoringService(ctx, cfg)
 	if err != nil {
 		return fmt.Errorf("failed to create monitoring service: %w", err)
 	}
 
-	// Process users in parallel
-	for _, username := range usernames {
-		wg.Add(1)
-		go func(user string) {
-			defer wg.Done()
+	var cache *monitor.MembershipCache
+	var pending []string
+	if len(sources) > 0 {
+		if stateDir := getStateDir(); stateDir != "" {
+			cache = monitor.NewMembershipCache(filepath.Join(stateDir, monitor.MembershipFileName), cfg.Monitor.MemberRefreshInterval)
+		}
 
-			if verbose {
-				log.Printf("Processing user: %s", user)
+		memberships, members, err := resolveMembers(ctx, service, sources, cache)
+		if err != nil {
+			if !quiet && output != "json" {
+				fmt.Print("\r\033[K") // Clear the line completely before error
 			}
+			return err
+		}
+		formatter.SetMemberships(memberships)
 
-			result, err := service.MonitorUser(ctx, user, getStateFilePath(user))
+		newcomers := monitor.Newcomers(memberships, usernames)
+		usernames = uniqueUsernames(append(usernames, members...))
+		if len(usernames) == 0 {
+			return fmt.Errorf("no users to monitor: the given organizations, teams and users have no visible members or followed accounts")
+		}
 
-			mu.Lock()
-			if err != nil {
-				errors[user] = err
-			} else {
-				results[user] = result
-			}
-			mu.Unlock()
-		}(username)
+		// Members who joined since the previous lookup start with a silent baseline, even
+		// when state from an earlier membership is stored. They stay pending until a run
+		// saves their baseline, so a failed run is retried as a baseline.
+		if pending, err = cache.PendingBaselines(newcomers, usernames); err != nil {
+			return err
+		}
+		service.SetFreshBaselines(pending)
+		if verbose && len(pending) > 0 {
+			log.Printf("Recording new baselines for new members: %s", strings.Join(pending, ", "))
+		}
+	}
+
+	if verbose {
+		log.Printf("Processing users: %s", strings.Join(usernames, ", "))
 	}
 
-	// Wait for all users to complete
-	wg.Wait()
+	// Process users in parallel
+	results, errors := service.MonitorUsers(ctx, usernames)
+
+	var baselined []string
+	for _, username := range pending {
+		if _, ok := results[username]; ok {
+			baselined = append(baselined, username)
+		}
+	}
+	if err := cache.BaselinesSaved(baselined); err != nil {
+		log.Printf("Warning: failed to record new baselines: %v", err)
+	}
 
 	if !quiet && output != "json" {
 		fmt.Print("\r\033[K") // Clear the line completely before results
 	}
 
 	// Format and display results
-	formatter := NewOutputFormatter(os.Stdout, output)
-	return formatter.FormatMultiUserResults(results, errors)
+	formatErr := formatter.FormatMultiUserResults(results, errors)
+
+	dispatchNotifications(ctx, newNotificationDispatcher(cfg, service.Logger()), results)
+	return formatErr
 }
 
-// createMonitoringService creates a complete monitoring service with real implementations
-func createMonitoringService() (*monitor.Service, error) {
-	// Create GitHub client with empty token (will try to get from environment or keychain)
-	githubClient := github.NewAPIClient("")
+// resolveMembers resolves the members of every source, reusing those in cache until
+// monitor.member_refresh_interval has passed. It returns each membership and all members
+// together.
+func resolveMembers(ctx context.Context, service *monitor.Service, sources []monitor.MemberSource, cache *monitor.MembershipCache) ([]*monitor.Membership, []string, error) {
+	var memberships []*monitor.Membership
+	var members []string
+	for _, source := range sources {
+		membership, err := service.ResolveMembers(ctx, source, cache)
+		if err != nil {
+			return nil, nil, err
+		}
+		memberships = append(memberships, membership)
+		members = append(members, membership.Members...)
 
-	// Create storage
-	jsonStorage := storage.NewJSONStorage()
+		if verbose {
+			log.Printf("Resolved %d members of %s (cached: %v)", len(membership.Members), source, membership.Cached)
+		}
+	}
+	return memberships, members, nil
+}
 
-	// Create keychain authentication with the GitHub client as validator
-	keychainAuth := auth.NewKeychainTokenManager(githubClient)
+// loadMonitorConfig loads the configuration and adjusts logging for the verbosity flags
+func loadMonitorConfig() (*config.Config, error) {
+	loaded, err := loadConfig()
+	if err != nil {
+		return nil, err
+	}
+	cfg := loaded.Config
 
-	// Check if we should use interactive prompts based on CLI flag and environment
-	var tokenManager auth.TokenManager
-	if authToken && os.Getenv("CI") == "" && isInteractiveTerminal() {
-		// Interactive mode with explicit --auth flag: allow prompting
-		tokenManager = auth.NewPromptTokenManager(keychainAuth)
-	} else {
-		// Default mode: only use existing tokens (keychain/environment), don't prompt
-		tokenManager = keychainAuth
+	if lockTimeout != "" {
+		timeout, err := time.ParseDuration(lockTimeout)
+		if err != nil || timeout < 0 {
+			return nil, fmt.Errorf("invalid --lock-timeout %q: must be a non-negative duration such as 30s or 2m", lockTimeout)
+		}
+		cfg.Storage.LockTimeout = timeout
 	}
 
-	// Create monitoring service with configuration adjusted for verbosity
-	cfg := config.DefaultConfig()
+	if concurrency < 0 {
+		return nil, fmt.Errorf("invalid --concurrency %d: must be at least 1", concurrency)
+	} else if concurrency > 0 {
+		cfg.Monitor.Concurrency = concurrency
+	}
 
 	// Adjust logging configuration based on CLI flags
 	if quiet {
@@ -206,13 +420,54 @@ func createMonitoringService() (*monitor.Service, error) {
 		cfg.Logging.EnablePerformanceMetrics = true
 		cfg.Logging.LogAPICallsSaved = true
 	} else {
-		// Normal mode - less verbose than current default
-		cfg.Logging.LogLevel = "warn"
-		cfg.Logging.EnablePerformanceMetrics = false
-		cfg.Logging.LogAPICallsSaved = false
+		// Normal mode - less verbose than current default, unless configured explicitly
+		if !loaded.IsSet("logging.log_level") {
+			cfg.Logging.LogLevel = "warn"
+		}
+		if !loaded.IsSet("logging.enable_performance_metrics") {
+			cfg.Logging.EnablePerformanceMetrics = false
+		}
+		if !loaded.IsSet("logging.log_api_calls_saved") {
+			cfg.Logging.LogAPICallsSaved = false
+		}
+	}
+
+	return cfg, nil
+}
+
+// createMonitoringService creates a complete monitoring service with real implementations.
+// The GitHub token is resolved here, once, and the service uses the resulting client for
+// every user it monitors.
+func createMonitoringService(ctx context.Context, cfg *config.Config) (*monitor.Service, error) {
+	// Create storage
+	store, err := openStorage(cfg)
+	if err != nil {
+		return nil, err
 	}
 
-	service := monitor.NewService(githubClient, jsonStorage, tokenManager, cfg)
+	// Create keychain authentication with an unauthenticated client as validator
+	keychainAuth := auth.NewKeychainTokenManager(github.NewAPIClient(""))
+
+	// Check if we should use interactive prompts based on CLI flag and environment
+	var tokenManager auth.TokenManager
+	if authToken && os.Getenv("CI") == "" && isInteractiveTerminal() {
+		// Interactive mode with explicit --auth flag: allow prompting
+		tokenManager = auth.NewPromptTokenManager(keychainAuth)
+	} else {
+		// Default mode: only use existing tokens (keychain/environment), don't prompt
+		tokenManager = keychainAuth
+	}
+
+	githubClient := authenticatedClient(ctx, tokenManager)
+	service := monitor.NewService(githubClient, store, cfg)
+	service.SetAcceptLargeChanges(acceptLargeChanges)
+
+	// Watched users may override the full sync interval
+	list, err := loadWatchlist()
+	if err != nil {
+		return nil, err
+	}
+	service.SetFullSyncIntervals(list.FullSyncIntervals())
 
 	// Set up progress callback only for non-JSON output to avoid polluting JSON
 	if output != "json" && !quiet {
@@ -237,6 +492,22 @@ func createMonitoringService() (*monitor.Service, error) {
 	return service, nil
 }
 
+// authenticatedClient returns a GitHub client using the token of tokenManager (environment
+// or keychain), or an unauthenticated client when there is none
+func authenticatedClient(ctx context.Context, tokenManager auth.TokenManager) github.GitHubClient {
+	token, source, err := tokenManager.GetToken(ctx)
+	if err != nil || token == "" {
+		if verbose {
+			log.Printf("Using unauthenticated access (rate limits may apply)")
+		}
+		return github.NewAPIClient("")
+	}
+	if verbose {
+		log.Printf("Using authentication from %s", source)
+	}
+	return github.NewAPIClient(token)
+}
+
 // isInteractiveTerminal checks if we're running in an interactive terminal
 func isInteractiveTerminal() bool {
 	// Check if stdout is a terminal and stdin is available
@@ -261,6 +532,9 @@ func isEssentialProgress(message string) bool {
 		"Monitor complete",
 		"Full sync completed",
 		"Incremental fetch completed",
+		"Users:",
+		"Rate limit nearly exhausted",
+		"Resolving members",
 	}
 
 	for _, prefix := range essentialPrefixes {
@@ -0,0 +1,173 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/akme/gh-stars-watcher/internal/config"
+	"github.com/akme/gh-stars-watcher/internal/monitor"
+	"github.com/akme/gh-stars-watcher/internal/notify"
+	"github.com/spf13/cobra"
+)
+
+// notifyCmd groups the notification subcommands
+var notifyCmd = &cobra.Command{
+	Use:   "notify",
+	Short: "Inspect notification deliveries",
+	Long: `Inspect the notifications sent for detected star changes.
+
+Notification sinks are configured in the notify section of the config file. After every
+run with changes, each sink receives one notification per user; the outcome of the latest
+delivery per sink and user is kept in notifications.json in the state directory. The
+email digest accumulates changes in email-digest.json until it is due.
+
+Examples:
+  star-watcher notify status
+  star-watcher notify status --output json`,
+}
+
+var notifyStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show the latest delivery of every notification sink and user",
+	Args:  cobra.NoArgs,
+	RunE:  runNotifyStatus,
+}
+
+// onChangeCommand is the --on-change exec hook of the monitor and watch commands
+var onChangeCommand string
+
+func init() {
+	notifyCmd.AddCommand(notifyStatusCmd)
+
+	for _, cmd := range []*cobra.Command{monitorCmd, watchCmd} {
+		cmd.Flags().StringVar(&onChangeCommand, "on-change", "", "command run for detected changes, with the change as JSON on stdin (default: notify.exec.command from config)")
+	}
+}
+
+func runNotifyStatus(cmd *cobra.Command, args []string) error {
+	deliveries, err := notify.NewStatusStore(filepath.Join(getStateDir(), notify.StatusFileName)).List()
+	if err != nil {
+		return err
+	}
+
+	cfg, err := loadMonitorConfig()
+	if err != nil {
+		return err
+	}
+	var digest *notify.Digest
+	var digestDue time.Time
+	if dispatcher, err := notify.NewDispatcherFromConfig(cfg, getStateDir(), nil); err == nil && dispatcher != nil {
+		dispatcher.SetDigestEncryption(stateCipher, cfg.Storage.Encrypt)
+		if digest, digestDue, err = dispatcher.PendingDigest(); err != nil {
+			return err
+		}
+	}
+
+	if output == "json" {
+		status := struct {
+			Deliveries    []notify.Delivery `json:"deliveries"`
+			PendingDigest *notify.Digest    `json:"pending_digest,omitempty"`
+			DigestDue     *time.Time        `json:"digest_due,omitempty"`
+		}{Deliveries: deliveries, PendingDigest: digest}
+		if digest != nil {
+			status.DigestDue = &digestDue
+		}
+
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(status)
+	}
+
+	if digest != nil {
+		fmt.Printf("Email digest: %d pending change(s), due %s\n\n",
+			digest.Changes(), digestDue.Local().Format("2006-01-02 15:04:05"))
+	}
+
+	if len(deliveries) == 0 {
+		fmt.Println("No notifications delivered yet")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "SINK\tUSER\tSTATUS\tATTEMPTS\tCHANGES\tTIME\tERROR")
+	for _, delivery := range deliveries {
+		status := "delivered"
+		if !delivery.Delivered {
+			status = "failed"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%d\t%s\t%s\n",
+			delivery.Notifier, delivery.Username, status, delivery.Attempts, delivery.Changes,
+			delivery.Timestamp.Local().Format("2006-01-02 15:04:05"), delivery.Error)
+	}
+	return w.Flush()
+}
+
+// newNotificationDispatcher creates a dispatcher for the sinks in cfg and the --on-change
+// flag, or nil when none is configured
+func newNotificationDispatcher(cfg *config.Config, logger *slog.Logger) *notify.Dispatcher {
+	if onChangeCommand != "" {
+		// Override the command on a copy so the caller's configuration is left as loaded
+		withCommand := *cfg
+		withCommand.Notify.Exec.Command = onChangeCommand
+		cfg = &withCommand
+	}
+
+	dispatcher, err := notify.NewDispatcherFromConfig(cfg, getStateDir(), logger)
+	if err != nil {
+		log.Printf("Warning: notifications disabled: %v", err)
+		return nil
+	}
+	if dispatcher == nil {
+		return nil
+	}
+	dispatcher.SetDigestEncryption(stateCipher, cfg.Storage.Encrypt)
+
+	// Watched users may limit their changes to some sinks
+	if list, err := loadWatchlist(); err != nil {
+		log.Printf("Warning: notifying every sink of every user: %v", err)
+	} else {
+		dispatcher.SetRoutes(list.Routes())
+	}
+
+	// Retries are only reported in verbose mode; failed deliveries are reported after dispatch
+	if !verbose {
+		dispatcher.SetLogger(func(format string, args ...interface{}) {})
+	}
+	return dispatcher
+}
+
+// dispatchNotifications sends results to the configured sinks and reports failed deliveries
+func dispatchNotifications(ctx context.Context, dispatcher *notify.Dispatcher, results map[string]*monitor.MonitorResult) {
+	if dispatcher == nil {
+		return
+	}
+
+	usernames := make([]string, 0, len(results))
+	for username := range results {
+		usernames = append(usernames, username)
+	}
+	sort.Strings(usernames)
+
+	ordered := make([]*monitor.MonitorResult, len(usernames))
+	for i, username := range usernames {
+		ordered[i] = results[username]
+	}
+
+	for _, delivery := range dispatcher.Dispatch(ctx, ordered) {
+		switch {
+		case !delivery.Delivered && !quiet:
+			log.Printf("Warning: %s notification for %s failed after %d attempt(s): %s",
+				delivery.Notifier, delivery.Username, delivery.Attempts, delivery.Error)
+		case delivery.Delivered && verbose:
+			log.Printf("Delivered %s notification for %s (%d changes)", delivery.Notifier, delivery.Username, delivery.Changes)
+		}
+	}
+}
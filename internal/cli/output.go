@@ -7,16 +7,32 @@ import (
 	"os"
 	"sort"
 	"strings"
+	"text/tabwriter"
 	"time"
 
+	"github.com/akme/gh-stars-watcher/internal/config"
 	"github.com/akme/gh-stars-watcher/internal/monitor"
 	"github.com/akme/gh-stars-watcher/internal/storage"
+	"github.com/akme/gh-stars-watcher/internal/templates"
+	"github.com/spf13/cobra"
 )
 
 // OutputFormatter handles formatting of monitoring results
 type OutputFormatter struct {
-	writer io.Writer
-	format string // "json", "text", "summary"
+	writer   io.Writer
+	format   string              // "json", "text", "summary"
+	template *templates.Template // Replaces the built-in text layout of monitor results when set
+
+	memberships []*monitor.Membership // Organizations, teams and followed accounts expanded into users
+}
+
+// templatePath is the --template flag of the monitor and watch commands
+var templatePath string
+
+func init() {
+	for _, cmd := range []*cobra.Command{monitorCmd, watchCmd} {
+		cmd.Flags().StringVar(&templatePath, "template", "", "Go template file for text output (default: output.template from config)")
+	}
 }
 
 // NewOutputFormatter creates a new output formatter
@@ -33,6 +49,38 @@ func NewOutputFormatter(writer io.Writer, format string) *OutputFormatter {
 	}
 }
 
+// newMonitorFormatter creates the formatter for monitor results, loading the output
+// template from the --template flag or the config
+func newMonitorFormatter(cfg *config.Config) (*OutputFormatter, error) {
+	formatter := NewOutputFormatter(os.Stdout, output)
+
+	path := cfg.Output.Template
+	if templatePath != "" {
+		path = templatePath
+	}
+	if path == "" || output == "json" {
+		return formatter, nil
+	}
+
+	tmpl, err := templates.Load(path)
+	if err != nil {
+		return nil, err
+	}
+	formatter.SetTemplate(tmpl)
+	return formatter, nil
+}
+
+// SetTemplate renders monitor results in text output with tmpl instead of the built-in layout
+func (f *OutputFormatter) SetTemplate(tmpl *templates.Template) {
+	f.template = tmpl
+}
+
+// SetMemberships reports the organizations, teams and followed accounts that were
+// monitored in the multi-user summary
+func (f *OutputFormatter) SetMemberships(memberships []*monitor.Membership) {
+	f.memberships = memberships
+}
+
 // FormatMonitorResults formats monitoring results according to the configured format
 func (f *OutputFormatter) FormatMonitorResults(result *monitor.ComparisonResult, username string) error {
 	switch f.format {
@@ -53,6 +101,10 @@ func (f *OutputFormatter) FormatMonitorResult(result *monitor.MonitorResult) err
 		return encoder.Encode(result)
 	}
 
+	if f.template != nil {
+		return f.template.Execute(f.writer, result)
+	}
+
 	// Text format
 	if result.IsFirstRun {
 		fmt.Fprintf(f.writer, "First run for %s - baseline established with %d starred repositories.\n",
@@ -65,6 +117,7 @@ func (f *OutputFormatter) FormatMonitorResult(result *monitor.MonitorResult) err
 	var newRepos []storage.Repository
 	if result.Changes != nil {
 		newRepos = result.Changes.NewStars
+		f.formatRenamed(result.Changes.Renamed)
 	}
 
 	if len(newRepos) == 0 {
@@ -119,7 +172,7 @@ func (f *OutputFormatter) formatText(result *monitor.ComparisonResult, username
 	fmt.Fprintf(f.writer, "GitHub Stars Monitor Report for %s\n", username)
 	fmt.Fprintf(f.writer, "Generated: %s\n\n", time.Now().Format("2006-01-02 15:04:05"))
 
-	if len(result.Added) == 0 && len(result.Removed) == 0 && len(result.Updated) == 0 {
+	if len(result.Added) == 0 && len(result.Removed) == 0 && len(result.Updated) == 0 && len(result.Renamed) == 0 {
 		fmt.Fprintf(f.writer, "No changes detected in starred repositories.\n")
 		return nil
 	}
@@ -144,6 +197,13 @@ func (f *OutputFormatter) formatText(result *monitor.ComparisonResult, username
 		fmt.Fprintf(f.writer, "\n")
 	}
 
+	// Renamed repositories
+	if len(result.Renamed) > 0 {
+		fmt.Fprintf(f.writer, "📛 RENAMED REPOSITORIES (%d)\n", len(result.Renamed))
+		fmt.Fprintf(f.writer, "%s\n", strings.Repeat("=", 50))
+		f.formatRenamed(result.Renamed)
+	}
+
 	// Updated repositories
 	if len(result.Updated) > 0 {
 		fmt.Fprintf(f.writer, "🔄 UPDATED REPOSITORIES (%d)\n", len(result.Updated))
@@ -197,6 +257,20 @@ func (f *OutputFormatter) formatRepository(repo storage.Repository, action strin
 	fmt.Fprintf(f.writer, "\n   %s\n\n", repo.URL)
 }
 
+// formatRenamed lists renamed and transferred repositories with their previous names
+func (f *OutputFormatter) formatRenamed(renamed []monitor.RenamedRepository) {
+	for _, r := range renamed {
+		action := "renamed"
+		if r.Transferred() {
+			action = "transferred"
+		}
+		fmt.Fprintf(f.writer, "📛 %s → %s (%s)\n", r.PreviousFullName, r.Repository.FullName, action)
+	}
+	if len(renamed) > 0 {
+		fmt.Fprintf(f.writer, "\n")
+	}
+}
+
 // formatRepositoryUpdate formats a repository update
 func (f *OutputFormatter) formatRepositoryUpdate(update monitor.RepositoryUpdate) {
 	fmt.Fprintf(f.writer, "🔄 %s\n", update.Current.FullName)
@@ -404,13 +478,15 @@ func (f *OutputFormatter) FormatMultiUserResults(results map[string]*monitor.Mon
 // formatMultiUserJSON outputs multi-user results in JSON format
 func (f *OutputFormatter) formatMultiUserJSON(results map[string]*monitor.MonitorResult, errors map[string]error) error {
 	output := struct {
-		Results   map[string]*monitor.MonitorResult `json:"results"`
-		Errors    map[string]string                 `json:"errors,omitempty"`
-		Timestamp string                            `json:"timestamp"`
+		Results     map[string]*monitor.MonitorResult `json:"results"`
+		Errors      map[string]string                 `json:"errors,omitempty"`
+		Memberships []*monitor.Membership             `json:"memberships,omitempty"`
+		Timestamp   string                            `json:"timestamp"`
 	}{
-		Results:   results,
-		Errors:    make(map[string]string),
-		Timestamp: time.Now().Format(time.RFC3339),
+		Results:     results,
+		Errors:      make(map[string]string),
+		Memberships: f.memberships,
+		Timestamp:   time.Now().Format(time.RFC3339),
 	}
 
 	// Convert errors to strings for JSON serialization
@@ -433,6 +509,10 @@ func (f *OutputFormatter) formatMultiUserText(results map[string]*monitor.Monito
 	fmt.Fprintf(f.writer, "Generated: %s\n", time.Now().Format("2006-01-02 15:04:05"))
 	fmt.Fprintf(f.writer, "Users processed: %d (Success: %d, Errors: %d)\n\n", totalUsers, successCount, errorCount)
 
+	if len(f.memberships) > 0 {
+		f.formatMemberships()
+	}
+
 	// Show errors first if any
 	if errorCount > 0 {
 		fmt.Fprintf(f.writer, "❌ ERRORS (%d)\n", errorCount)
@@ -443,6 +523,22 @@ func (f *OutputFormatter) formatMultiUserText(results map[string]*monitor.Monito
 		fmt.Fprintf(f.writer, "\n")
 	}
 
+	// A template renders each user in turn
+	if f.template != nil {
+		usernames := make([]string, 0, len(results))
+		for username := range results {
+			usernames = append(usernames, username)
+		}
+		sort.Strings(usernames)
+
+		for _, username := range usernames {
+			if err := f.template.Execute(f.writer, results[username]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
 	// Show successful results grouped by user
 	if successCount > 0 {
 		// Sort usernames for consistent output
@@ -467,6 +563,7 @@ func (f *OutputFormatter) formatMultiUserText(results map[string]*monitor.Monito
 				var newRepos []storage.Repository
 				if result.Changes != nil {
 					newRepos = result.Changes.NewStars
+					f.formatRenamed(result.Changes.Renamed)
 				}
 
 				if len(newRepos) == 0 {
@@ -503,3 +600,211 @@ func (f *OutputFormatter) formatMultiUserText(results map[string]*monitor.Monito
 
 	return nil
 }
+
+// formatMemberships lists the expanded organizations, teams and followed accounts with the
+// members that joined or left since they were previously resolved
+func (f *OutputFormatter) formatMemberships() {
+	fmt.Fprintf(f.writer, "👥 MEMBERS\n")
+	fmt.Fprintf(f.writer, "%s\n", strings.Repeat("=", 50))
+	for _, membership := range f.memberships {
+		resolved := "resolved now"
+		if membership.Cached {
+			resolved = "cached from " + membership.ResolvedAt.Local().Format("2006-01-02 15:04")
+		}
+		fmt.Fprintf(f.writer, "• %s: %d members (%s)\n", membership.Source, len(membership.Members), resolved)
+		if len(membership.Added) > 0 {
+			fmt.Fprintf(f.writer, "  ➕ new: %s\n", strings.Join(membership.Added, ", "))
+		}
+		if len(membership.Removed) > 0 {
+			fmt.Fprintf(f.writer, "  ➖ removed: %s\n", strings.Join(membership.Removed, ", "))
+		}
+	}
+	fmt.Fprintf(f.writer, "\n")
+}
+
+// FormatCycleResult formats the results of one watch cycle
+func (f *OutputFormatter) FormatCycleResult(cycle *monitor.CycleResult) error {
+	if f.format == "json" {
+		output := struct {
+			*monitor.CycleResult
+			Errors map[string]string `json:"errors,omitempty"`
+		}{
+			CycleResult: cycle,
+			Errors:      make(map[string]string),
+		}
+
+		// Convert errors to strings for JSON serialization
+		for username, err := range cycle.Errors {
+			output.Errors[username] = err.Error()
+		}
+
+		encoder := json.NewEncoder(f.writer)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(output)
+	}
+
+	fmt.Fprintf(f.writer, "Cycle %d - %s\n\n", cycle.Cycle, cycle.FinishedAt.Format("2006-01-02 15:04:05"))
+
+	// A single user keeps the familiar monitor output
+	if len(cycle.Results)+len(cycle.Errors) == 1 {
+		for _, result := range cycle.Results {
+			return f.FormatMonitorResult(result)
+		}
+		for username, err := range cycle.Errors {
+			return f.FormatError(err, "monitoring "+username)
+		}
+	}
+
+	return f.formatMultiUserText(cycle.Results, cycle.Errors)
+}
+
+// historyEventLabels are the text labels of event log entries
+var historyEventLabels = map[string]string{
+	storage.EventBaseline: "📌 baseline",
+	storage.EventStar:     "⭐ starred",
+	storage.EventUnstar:   "💔 unstarred",
+	storage.EventReStar:   "🔁 re-starred",
+	storage.EventUpdate:   "🔄 updated",
+	storage.EventRename:   "📛 renamed",
+}
+
+// FormatHistory formats event log entries as text, a table or JSON
+func (f *OutputFormatter) FormatHistory(events []storage.Event) error {
+	switch f.format {
+	case "json":
+		output := struct {
+			Count  int             `json:"count"`
+			Events []storage.Event `json:"events"`
+		}{
+			Count:  len(events),
+			Events: events,
+		}
+		if output.Events == nil {
+			output.Events = []storage.Event{}
+		}
+
+		encoder := json.NewEncoder(f.writer)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(output)
+
+	case "table":
+		w := tabwriter.NewWriter(f.writer, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "TIME\tUSER\tEVENT\tREPOSITORY\tLANGUAGE\tSTARS\tCHANGES")
+		for _, event := range events {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%d\t%s\n",
+				event.Timestamp.Local().Format("2006-01-02 15:04"), event.Username, event.Type,
+				event.Repository.FullName, f.formatLanguage(event.Repository.Language),
+				event.Repository.StarCount, strings.Join(event.Changes, ","))
+		}
+		return w.Flush()
+	}
+
+	if len(events) == 0 {
+		fmt.Fprintf(f.writer, "No matching events found.\n")
+		return nil
+	}
+
+	day := ""
+	for _, event := range events {
+		local := event.Timestamp.Local()
+		if d := local.Format("Monday, 2006-01-02"); d != day {
+			if day != "" {
+				fmt.Fprintf(f.writer, "\n")
+			}
+			fmt.Fprintf(f.writer, "%s\n", d)
+			day = d
+		}
+
+		label := historyEventLabels[event.Type]
+		if label == "" {
+			label = event.Type
+		}
+		fmt.Fprintf(f.writer, "  %s  %-8s %-15s %s", local.Format("15:04"), event.Username, label, event.Repository.FullName)
+		if event.PreviousFullName != "" {
+			fmt.Fprintf(f.writer, " (was %s)", event.PreviousFullName)
+		}
+		if len(event.Changes) > 0 {
+			fmt.Fprintf(f.writer, " (%s)", strings.Join(event.Changes, ", "))
+		}
+		fmt.Fprintf(f.writer, "\n")
+	}
+
+	if len(events) == 1 {
+		fmt.Fprintf(f.writer, "\n1 event\n")
+	} else {
+		fmt.Fprintf(f.writer, "\n%d events\n", len(events))
+	}
+	return nil
+}
+
+// FormatStoredUsers formats the users with stored state as a table or JSON.
+// states holds the state of each username at the same index.
+func (f *OutputFormatter) FormatStoredUsers(usernames []string, states []*storage.UserState) error {
+	if f.format == "json" {
+		type storedUser struct {
+			Username     string    `json:"username"`
+			Repositories int       `json:"repositories"`
+			LastCheck    time.Time `json:"last_check"`
+			CheckCount   int       `json:"check_count"`
+		}
+		output := struct {
+			Count int          `json:"count"`
+			Users []storedUser `json:"users"`
+		}{
+			Count: len(usernames),
+			Users: make([]storedUser, len(usernames)),
+		}
+		for i, username := range usernames {
+			output.Users[i] = storedUser{username, states[i].TotalCount, states[i].LastCheck, states[i].CheckCount}
+		}
+
+		encoder := json.NewEncoder(f.writer)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(output)
+	}
+
+	if len(usernames) == 0 {
+		fmt.Fprintf(f.writer, "No stored users found.\n")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(f.writer, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "USER\tREPOSITORIES\tLAST CHECK\tCHECKS")
+	for i, username := range usernames {
+		fmt.Fprintf(w, "%s\t%d\t%s\t%d\n", username, states[i].TotalCount,
+			states[i].LastCheck.Local().Format("2006-01-02 15:04"), states[i].CheckCount)
+	}
+	return w.Flush()
+}
+
+// FormatSnapshots formats the snapshots of a user's state as a table or JSON
+func (f *OutputFormatter) FormatSnapshots(username string, snapshots []storage.Snapshot) error {
+	if f.format == "json" {
+		output := struct {
+			Username  string             `json:"username"`
+			Count     int                `json:"count"`
+			Snapshots []storage.Snapshot `json:"snapshots"`
+		}{username, len(snapshots), snapshots}
+		if output.Snapshots == nil {
+			output.Snapshots = []storage.Snapshot{}
+		}
+
+		encoder := json.NewEncoder(f.writer)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(output)
+	}
+
+	if len(snapshots) == 0 {
+		fmt.Fprintf(f.writer, "No snapshots found for user: %s\n", username)
+		return nil
+	}
+
+	w := tabwriter.NewWriter(f.writer, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "SNAPSHOT\tTAKEN\tREPOSITORIES\tLAST CHECK\tCHECKS")
+	for _, snapshot := range snapshots {
+		fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%d\n", snapshot.ID,
+			snapshot.TakenAt.Local().Format("2006-01-02 15:04:05"), snapshot.Repositories,
+			snapshot.LastCheck.Local().Format("2006-01-02 15:04"), snapshot.CheckCount)
+	}
+	return w.Flush()
+}
@@ -4,17 +4,23 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
 
+	"github.com/akme/gh-stars-watcher/internal/auth"
+	"github.com/akme/gh-stars-watcher/internal/config"
+	"github.com/akme/gh-stars-watcher/internal/storage"
 	"github.com/spf13/cobra"
 )
 
 var (
 	// Global flags
-	verbose   bool
-	quiet     bool
-	stateFile string
-	output    string
-	authToken bool
+	verbose    bool
+	quiet      bool
+	stateFile  string
+	output     string
+	authToken  bool
+	configFile string
+	storageURL string
 )
 
 // rootCmd represents the base command when called without any subcommands
@@ -40,12 +46,22 @@ func init() {
 	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "verbose output (detailed logging)")
 	rootCmd.PersistentFlags().BoolVarP(&quiet, "quiet", "q", false, "quiet output (errors only)")
 	rootCmd.PersistentFlags().StringVar(&stateFile, "state-file", "", "custom state file path (default: ~/.star-watcher/{username}.json)")
-	rootCmd.PersistentFlags().StringVarP(&output, "output", "o", "text", "output format: text, json")
+	rootCmd.PersistentFlags().StringVarP(&output, "output", "o", "text", "output format: text, json (history also supports table)")
 	rootCmd.PersistentFlags().BoolVarP(&authToken, "auth", "a", false, "prompt for GitHub token for authenticated requests (higher rate limits)")
+	rootCmd.PersistentFlags().StringVar(&configFile, "config", "", "config file path (default: ~/.config/star-watcher/config.yaml)")
+	rootCmd.PersistentFlags().StringVar(&storageURL, "storage", "", "state storage: json or sqlite://path (default: storage.url from config)")
 
 	// Add subcommands
 	rootCmd.AddCommand(monitorCmd)
+	rootCmd.AddCommand(watchCmd)
 	rootCmd.AddCommand(cleanupCmd)
+	rootCmd.AddCommand(configCmd)
+	rootCmd.AddCommand(scheduleCmd)
+	rootCmd.AddCommand(historyCmd)
+	rootCmd.AddCommand(notifyCmd)
+	rootCmd.AddCommand(storageCmd)
+	rootCmd.AddCommand(stateCmd)
+	rootCmd.AddCommand(usersCmd)
 }
 
 // setupLogging configures logging based on verbosity flags
@@ -70,22 +86,109 @@ func setupLogging(cmd *cobra.Command, args []string) {
 	}
 }
 
-// getStateFilePath returns the state file path for a username
-func getStateFilePath(username string) string {
+// getStateDir returns the directory holding state files, creating it if needed.
+// It returns an empty string when the default directory cannot be used.
+func getStateDir() string {
 	if stateFile != "" {
-		return stateFile
+		return filepath.Dir(stateFile)
 	}
 
-	// Default: ~/.star-watcher/{username}.json
+	// Default: ~/.star-watcher
 	homeDir, err := os.UserHomeDir()
 	if err != nil {
-		return fmt.Sprintf(".star-watcher-%s.json", username)
+		return ""
 	}
 
 	stateDir := fmt.Sprintf("%s/.star-watcher", homeDir)
 	if err := os.MkdirAll(stateDir, 0o755); err != nil {
-		return fmt.Sprintf(".star-watcher-%s.json", username)
+		return ""
 	}
 
-	return fmt.Sprintf("%s/%s.json", stateDir, username)
+	return stateDir
+}
+
+// openStorage opens the state store selected by --storage or the storage.url setting.
+// --state-file keeps JSON state in that file instead of the state directory.
+func openStorage(cfg *config.Config) (storage.Store, error) {
+	url := cfg.Storage.URL
+	if storageURL != "" {
+		url = storageURL
+	}
+
+	compression, err := storage.ParseCompression(cfg.Storage.Compression)
+	if err != nil {
+		return nil, err
+	}
+	options := storage.Options{
+		Snapshots:   storage.SnapshotPolicy{Count: cfg.Storage.Snapshots, MaxAge: cfg.Storage.SnapshotMaxAge},
+		Compression: compression,
+	}
+
+	if stateFile != "" && (url == "" || url == "json") {
+		if verbose {
+			log.Printf("Using state file: %s", stateFile)
+		}
+		store := storage.NewJSONFileStore(stateFile)
+		store.SetSnapshotPolicy(options.Snapshots)
+		store.SetCompression(options.Compression)
+		return encryptStore(store, cfg), nil
+	}
+
+	store, err := storage.Open(url, getStateDir(), options)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open storage: %w", err)
+	}
+
+	if verbose {
+		log.Printf("Using storage: %s", url)
+	}
+
+	return encryptStore(store, cfg), nil
+}
+
+// encryptStore wraps store so encrypted state is opened, and saved state is sealed
+// when storage.encrypt is set. The key is only looked up once sealed state is met.
+func encryptStore(store storage.Store, cfg *config.Config) *storage.EncryptedStore {
+	return storage.NewEncryptedStore(store, stateCipher, cfg.Storage.Encrypt)
+}
+
+// stateCipher builds the state cipher from the configured key and, while a rekey is
+// unfinished, its pending key. It returns nil when no key is configured.
+func stateCipher() (*storage.StateCipher, error) {
+	current, source := auth.GetStateKey()
+	encoded := []struct{ key, source string }{{current, source}, {auth.GetPendingStateKey(), "keychain (pending rekey)"}}
+
+	var keys [][]byte
+	for _, e := range encoded {
+		if e.key == "" {
+			continue
+		}
+		key, err := storage.ParseStateKey(e.key)
+		if err != nil {
+			return nil, fmt.Errorf("state key from %s: %w", e.source, err)
+		}
+		keys = append(keys, key)
+	}
+
+	if len(keys) == 0 {
+		return nil, nil
+	}
+	if verbose {
+		log.Printf("Using state key %s", storage.StateKeyID(keys[0]))
+	}
+	return storage.NewStateCipher(keys...)
+}
+
+// loadConfig loads the effective configuration from the config file and environment
+func loadConfig() (*config.Loaded, error) {
+	loaded, err := config.Load(configFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load configuration: %w", err)
+	}
+
+	if verbose && loaded.Path != "" {
+		log.Printf("Using config file: %s", loaded.Path)
+	}
+
+	return loaded, nil
 }
@@ -0,0 +1,76 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// scheduleCmd groups the watch schedule subcommands
+var scheduleCmd = &cobra.Command{
+	Use:   "schedule",
+	Short: "Inspect the watch schedule",
+	Long: `Inspect when the watch command will check each user next.
+
+The schedule is built from watch.schedule and watch.schedules in the config file and the
+next-run times saved by a running or previous watch command.
+
+Examples:
+  star-watcher schedule list
+  star-watcher schedule list octocat,github --output json`,
+}
+
+var scheduleListCmd = &cobra.Command{
+	Use:   "list [username or usernames]",
+	Short: "List the next run of every watched user",
+	Args:  cobra.MaximumNArgs(1),
+	RunE:  runScheduleList,
+}
+
+func init() {
+	scheduleCmd.AddCommand(scheduleListCmd)
+}
+
+func runScheduleList(cmd *cobra.Command, args []string) error {
+	cfg, err := loadMonitorConfig()
+	if err != nil {
+		return err
+	}
+
+	usernames, err := watchUsernames(args, cfg)
+	if err != nil {
+		return err
+	}
+
+	scheduler, err := newWatchScheduler(usernames, cfg)
+	if err != nil {
+		return err
+	}
+
+	entries := scheduler.Entries()
+	if output == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(entries)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "USER\tSCHEDULE\tPRIORITY\tNEXT RUN\tLAST RUN")
+	for _, entry := range entries {
+		fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%s\n",
+			entry.Username, entry.Spec, entry.Priority, formatScheduleTime(entry.NextRun), formatScheduleTime(entry.LastRun))
+	}
+	return w.Flush()
+}
+
+// formatScheduleTime formats a schedule timestamp, showing never for the zero time
+func formatScheduleTime(t time.Time) string {
+	if t.IsZero() {
+		return "never"
+	}
+	return t.Local().Format("2006-01-02 15:04:05")
+}
@@ -0,0 +1,246 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/akme/gh-stars-watcher/internal/auth"
+	"github.com/akme/gh-stars-watcher/internal/config"
+	"github.com/akme/gh-stars-watcher/internal/notify"
+	"github.com/akme/gh-stars-watcher/internal/storage"
+	"github.com/spf13/cobra"
+)
+
+// stateCmd groups the commands that inspect and roll back stored user state
+var stateCmd = &cobra.Command{
+	Use:   "state",
+	Short: "Inspect, restore and rekey stored user state",
+	Long: `Inspect, restore and rekey the stored state of a user.
+
+Every time a run replaces the state of a user, the previous state is kept as a
+timestamped snapshot. storage.snapshots sets how many are kept per user (default 5)
+and storage.snapshot_max_age removes older ones; the newest snapshot is always kept.
+
+Examples:
+  star-watcher state snapshots octocat
+  star-watcher state restore octocat 20260301T120000.000000000Z
+  star-watcher state rekey`,
+}
+
+var stateSnapshotsCmd = &cobra.Command{
+	Use:   "snapshots <username>",
+	Short: "List the snapshots of a user's state",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runStateSnapshots,
+}
+
+var stateRestoreCmd = &cobra.Command{
+	Use:   "restore <username> <snapshot>",
+	Short: "Replace a user's state with one of its snapshots",
+	Long: `Replace the stored state of a user with one of its snapshots, e.g. to undo a run
+that saved a truncated starred list.
+
+The state being replaced is itself kept as a new snapshot, so a restore can be undone.
+The event history is left untouched.`,
+	Args: cobra.ExactArgs(2),
+	RunE: runStateRestore,
+}
+
+var stateRekeyCmd = &cobra.Command{
+	Use:   "rekey",
+	Short: "Encrypt all stored state with a new key",
+	Long: `Seal the state, snapshots and events of every user with a new key. State that is
+not encrypted yet is encrypted too, so this also creates the first key.
+
+With the key in the keychain, a new key is generated and kept as a pending key
+until every user is rekeyed, so an interrupted rekey can simply be run again.
+With the key in STAR_WATCHER_STATE_KEY, set STAR_WATCHER_NEW_STATE_KEY to the new
+key (e.g. from "openssl rand -base64 32") and move it to STAR_WATCHER_STATE_KEY
+afterwards.
+
+Set storage.encrypt to keep saved state encrypted.`,
+	Args: cobra.NoArgs,
+	RunE: runStateRekey,
+}
+
+func init() {
+	stateCmd.AddCommand(stateSnapshotsCmd)
+	stateCmd.AddCommand(stateRestoreCmd)
+	stateCmd.AddCommand(stateRekeyCmd)
+}
+
+func runStateSnapshots(cmd *cobra.Command, args []string) error {
+	username := args[0]
+	if !githubUsernamePattern.MatchString(username) {
+		return fmt.Errorf("invalid GitHub username format: %s", username)
+	}
+
+	loaded, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	store, err := openStorage(loaded.Config)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	snapshots, err := store.Snapshots(username)
+	if err != nil {
+		return err
+	}
+
+	return NewOutputFormatter(os.Stdout, output).FormatSnapshots(username, snapshots)
+}
+
+func runStateRestore(cmd *cobra.Command, args []string) error {
+	username, id := args[0], args[1]
+	if !githubUsernamePattern.MatchString(username) {
+		return fmt.Errorf("invalid GitHub username format: %s", username)
+	}
+
+	loaded, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	cfg := loaded.Config
+	store, err := openStorage(cfg)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	// Hold the lock so a concurrent run cannot overwrite the restored state
+	lock, err := store.Lock(context.Background(), username, cfg.Storage.LockTimeout)
+	if err != nil {
+		return fmt.Errorf("failed to lock state: %w", err)
+	}
+	defer lock.Unlock()
+
+	state, err := store.LoadSnapshot(username, id)
+	if err != nil {
+		return err
+	}
+	if err := store.Save(username, state, nil); err != nil {
+		return fmt.Errorf("failed to restore snapshot %s: %w", id, err)
+	}
+
+	if !quiet {
+		fmt.Printf("Restored state of %s from snapshot %s: %d repositories, last checked %s\n",
+			username, id, len(state.Repositories), state.LastCheck.Local().Format("2006-01-02 15:04"))
+		if snapshots, err := store.Snapshots(username); err == nil && len(snapshots) > 0 && snapshots[0].ID != id {
+			fmt.Printf("The replaced state was kept as snapshot %s.\n", snapshots[0].ID)
+		}
+	}
+	return nil
+}
+
+func runStateRekey(cmd *cobra.Command, args []string) error {
+	loaded, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	cfg := loaded.Config
+	store, err := openStorage(cfg)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+	encrypted := store.(*storage.EncryptedStore)
+
+	// Pick the new key; with the keychain it is saved as pending before any state uses it
+	current, source := auth.GetStateKey()
+	pending := auth.GetPendingStateKey()
+	var encodedNext string
+	switch {
+	case source == "environment":
+		encodedNext = os.Getenv(auth.NewStateKeyEnv)
+		if encodedNext == "" {
+			return fmt.Errorf("the state key is set by %s: set %s to the new key, e.g. from \"openssl rand -base64 32\"", auth.StateKeyEnv, auth.NewStateKeyEnv)
+		}
+	case pending != "":
+		encodedNext = pending
+	default:
+		key, err := storage.GenerateStateKey()
+		if err != nil {
+			return err
+		}
+		encodedNext = storage.FormatStateKey(key)
+		if err := auth.StorePendingStateKey(encodedNext); err != nil {
+			return err
+		}
+	}
+
+	keys := [][]byte{}
+	for _, encoded := range []string{encodedNext, current, pending} {
+		if encoded == "" {
+			continue
+		}
+		key, err := storage.ParseStateKey(encoded)
+		if err != nil {
+			return err
+		}
+		keys = append(keys, key)
+	}
+	next, err := storage.NewStateCipher(keys...)
+	if err != nil {
+		return err
+	}
+
+	usernames, err := store.List()
+	if err != nil {
+		return err
+	}
+	for _, username := range usernames {
+		if err := rekeyUser(encrypted, username, next, cfg); err != nil {
+			return fmt.Errorf("failed to rekey %s: %w", username, err)
+		}
+		if !quiet {
+			fmt.Printf("Rekeyed %s\n", username)
+		}
+	}
+
+	// The pending email digest lists repository names too
+	if stateDir := getStateDir(); stateDir != "" {
+		digest := notify.NewDigestStore(filepath.Join(stateDir, notify.DigestFileName), 0)
+		digest.SetEncryption(stateCipher, cfg.Storage.Encrypt)
+		if err := digest.Rekey(next); err != nil {
+			return fmt.Errorf("failed to rekey the pending email digest: %w", err)
+		}
+	}
+
+	if source == "environment" {
+		if !quiet {
+			fmt.Printf("Rekeyed %d users with key %s. Set %s to the value of %s now.\n",
+				len(usernames), next.KeyID(), auth.StateKeyEnv, auth.NewStateKeyEnv)
+		}
+	} else {
+		if err := auth.StoreStateKey(encodedNext); err != nil {
+			return err
+		}
+		if err := auth.RemovePendingStateKey(); err != nil {
+			return err
+		}
+		if !quiet {
+			fmt.Printf("Rekeyed %d users with key %s, stored in the keychain.\n", len(usernames), next.KeyID())
+		}
+	}
+
+	if !quiet && !cfg.Storage.Encrypt {
+		fmt.Println("storage.encrypt is off, so state saved from now on is not encrypted.")
+	}
+	return nil
+}
+
+// rekeyUser seals everything stored for a user with next, holding the user's lock
+func rekeyUser(store *storage.EncryptedStore, username string, next *storage.StateCipher, cfg *config.Config) error {
+	lock, err := store.Lock(context.Background(), username, cfg.Storage.LockTimeout)
+	if err != nil {
+		return fmt.Errorf("failed to lock state: %w", err)
+	}
+	defer lock.Unlock()
+
+	return store.Rekey(username, next)
+}
@@ -0,0 +1,157 @@
+package cli
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/akme/gh-stars-watcher/internal/storage"
+	"github.com/spf13/cobra"
+)
+
+// storageCmd groups the state storage subcommands
+var storageCmd = &cobra.Command{
+	Use:   "storage",
+	Short: "Manage the state storage",
+	Long: `Manage where monitoring state is kept.
+
+State is stored as one JSON file per user in ~/.star-watcher by default. Set storage.url
+in the config file or pass --storage to keep all users in a single SQLite database
+instead, e.g. --storage sqlite://~/.star-watcher/star-watcher.db.
+
+Examples:
+  star-watcher storage list
+  star-watcher storage import --storage sqlite://
+  star-watcher storage import --from ./old-state --storage sqlite:///var/lib/star-watcher.db`,
+}
+
+var storageListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the users with stored state",
+	Args:  cobra.NoArgs,
+	RunE:  runStorageList,
+}
+
+var storageImportCmd = &cobra.Command{
+	Use:   "import",
+	Short: "Import JSON state files into the configured SQLite storage",
+	Long: `Import the JSON state files and event logs of a state directory into the storage
+selected by --storage or storage.url, which must be a SQLite database.
+
+Users that already have state in the database are skipped, so the import can be
+repeated safely. The JSON files are left untouched.`,
+	Args: cobra.NoArgs,
+	RunE: runStorageImport,
+}
+
+// importDir is the directory searched for JSON state files
+var importDir string
+
+func init() {
+	storageImportCmd.Flags().StringVar(&importDir, "from", "", "directory with JSON state files (default: ~/.star-watcher)")
+	storageCmd.AddCommand(storageListCmd)
+	storageCmd.AddCommand(storageImportCmd)
+}
+
+func runStorageImport(cmd *cobra.Command, args []string) error {
+	cfg, err := loadMonitorConfig()
+	if err != nil {
+		return err
+	}
+
+	store, err := openStorage(cfg)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+	backend := store
+	if encrypted, ok := backend.(*storage.EncryptedStore); ok {
+		backend = encrypted.Unwrap()
+	}
+	database, ok := backend.(*storage.SQLiteStorage)
+	if !ok {
+		return fmt.Errorf("import needs a SQLite storage: pass --storage sqlite://path or set storage.url")
+	}
+	target := store
+
+	dir := importDir
+	if dir == "" {
+		dir = getStateDir()
+	}
+	if _, err := os.Stat(dir); err != nil {
+		return fmt.Errorf("failed to read state directory: %v", err)
+	}
+
+	files := storage.NewJSONStore(dir)
+	source := encryptStore(files, cfg)
+	usernames, err := source.List()
+	if err != nil {
+		return err
+	}
+
+	imported := 0
+	for _, username := range usernames {
+		if exists, err := target.Exists(username); err != nil {
+			return err
+		} else if exists {
+			if !quiet {
+				fmt.Printf("Skipped %s: already imported\n", username)
+			}
+			continue
+		}
+
+		state, err := source.Load(username)
+		if err != nil {
+			if verbose {
+				log.Printf("Skipping %s: %v", files.Path(username), err)
+			}
+			continue
+		}
+		events, err := source.Events(username, storage.EventFilter{})
+		if err != nil {
+			return fmt.Errorf("failed to read history of %s: %w", username, err)
+		}
+
+		if err := target.Save(username, state, events); err != nil {
+			return fmt.Errorf("failed to import %s: %w", username, err)
+		}
+		imported++
+
+		if !quiet {
+			fmt.Printf("Imported %s: %d repositories, %d events\n", username, len(state.Repositories), len(events))
+		}
+	}
+
+	if !quiet {
+		fmt.Printf("Imported %d of %d users into %s.\n", imported, len(usernames), database.Path())
+	}
+	return nil
+}
+
+func runStorageList(cmd *cobra.Command, args []string) error {
+	loaded, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	store, err := openStorage(loaded.Config)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	usernames, err := store.List()
+	if err != nil {
+		return err
+	}
+
+	states := make([]*storage.UserState, 0, len(usernames))
+	for _, username := range usernames {
+		state, err := store.Load(username)
+		if err != nil {
+			return fmt.Errorf("failed to load state of %s: %w", username, err)
+		}
+		states = append(states, state)
+	}
+
+	return NewOutputFormatter(os.Stdout, output).FormatStoredUsers(usernames, states)
+}
@@ -0,0 +1,212 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/akme/gh-stars-watcher/internal/notify"
+	"github.com/akme/gh-stars-watcher/internal/watchlist"
+	"github.com/spf13/cobra"
+)
+
+// usersCmd groups the commands that maintain the watchlist
+var usersCmd = &cobra.Command{
+	Use:   "users",
+	Short: "Maintain the list of watched users",
+	Long: `Maintain the watchlist: the users that monitor and watch check when no usernames are
+given. It is kept in watchlist.json in the state directory.
+
+Each user can carry labels for grouping, the notification sinks that receive their
+changes (webhook, slack, discord, email, exec; all configured sinks by default) and
+their own full sync interval in hours, overriding incremental.full_sync_interval.
+
+Examples:
+  star-watcher users add octocat torvalds --label friends
+  star-watcher users add gaearon --notify slack,email --full-sync-interval 6
+  star-watcher users list --label friends
+  star-watcher users remove torvalds`,
+}
+
+var usersAddCmd = &cobra.Command{
+	Use:   "add <username>...",
+	Short: "Add users to the watchlist or update their settings",
+	Long: `Add users to the watchlist. Adding a user who is already watched updates only the
+settings given as flags.
+
+Pass --notify "" to send a user's changes to every sink again and
+--full-sync-interval -1 to use the configured interval again.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: runUsersAdd,
+}
+
+var usersRemoveCmd = &cobra.Command{
+	Use:   "remove <username>...",
+	Short: "Remove users from the watchlist",
+	Long: `Remove users from the watchlist. Their stored state is kept; use the cleanup
+command to delete it.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: runUsersRemove,
+}
+
+var usersListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the watched users and their settings",
+	Args:  cobra.NoArgs,
+	RunE:  runUsersList,
+}
+
+var (
+	usersLabels           []string
+	usersNotify           []string
+	usersFullSyncInterval int
+	usersListLabel        string
+)
+
+func init() {
+	usersAddCmd.Flags().StringSliceVar(&usersLabels, "label", nil, "label the users (repeatable or comma-separated; replaces existing labels)")
+	usersAddCmd.Flags().StringSliceVar(&usersNotify, "notify", nil, "notification sinks for the users' changes: "+strings.Join(notify.SinkNames, ", ")+` ("" for all)`)
+	usersAddCmd.Flags().IntVar(&usersFullSyncInterval, "full-sync-interval", 0, "hours between full syncs of the users (0 = every run, -1 = use the config)")
+	usersListCmd.Flags().StringVar(&usersListLabel, "label", "", "only list users with this label")
+
+	usersCmd.AddCommand(usersAddCmd)
+	usersCmd.AddCommand(usersRemoveCmd)
+	usersCmd.AddCommand(usersListCmd)
+}
+
+// loadWatchlist loads the watchlist from the state directory
+func loadWatchlist() (*watchlist.Watchlist, error) {
+	stateDir := getStateDir()
+	if stateDir == "" {
+		return nil, fmt.Errorf("failed to determine state directory for the watchlist")
+	}
+	return watchlist.Load(filepath.Join(stateDir, watchlist.FileName))
+}
+
+func runUsersAdd(cmd *cobra.Command, args []string) error {
+	usernames, err := parseUsernames(strings.Join(args, ","))
+	if err != nil {
+		return err
+	}
+
+	for _, sink := range usersNotify {
+		if !notify.IsSinkName(sink) {
+			return fmt.Errorf("invalid --notify sink %q: must be one of %s", sink, strings.Join(notify.SinkNames, ", "))
+		}
+	}
+	if usersFullSyncInterval < -1 {
+		return fmt.Errorf("invalid --full-sync-interval %d: must be a number of hours, or -1 to use the config", usersFullSyncInterval)
+	}
+
+	list, err := loadWatchlist()
+	if err != nil {
+		return err
+	}
+
+	flags := cmd.Flags()
+	for _, username := range usernames {
+		entry, exists := list.Get(username)
+		if !exists {
+			entry = watchlist.Entry{Username: username, AddedAt: time.Now()}
+		}
+
+		if flags.Changed("label") {
+			entry.Labels = usersLabels
+		}
+		if flags.Changed("notify") {
+			entry.Notify = usersNotify
+		}
+		if flags.Changed("full-sync-interval") {
+			entry.FullSyncInterval = nil
+			if usersFullSyncInterval >= 0 {
+				interval := usersFullSyncInterval
+				entry.FullSyncInterval = &interval
+			}
+		}
+
+		list.Put(entry)
+		if !quiet {
+			if exists {
+				fmt.Printf("Updated %s in the watchlist\n", entry.Username)
+			} else {
+				fmt.Printf("Added %s to the watchlist\n", entry.Username)
+			}
+		}
+	}
+
+	return list.Save()
+}
+
+func runUsersRemove(cmd *cobra.Command, args []string) error {
+	list, err := loadWatchlist()
+	if err != nil {
+		return err
+	}
+
+	// Check every user first so a typo leaves the watchlist unchanged
+	for _, username := range args {
+		if _, ok := list.Get(username); !ok {
+			return fmt.Errorf("user %s is not in the watchlist", username)
+		}
+	}
+
+	for _, username := range args {
+		entry, _ := list.Get(username)
+		list.Remove(username)
+		if !quiet {
+			fmt.Printf("Removed %s from the watchlist\n", entry.Username)
+		}
+	}
+
+	return list.Save()
+}
+
+func runUsersList(cmd *cobra.Command, args []string) error {
+	list, err := loadWatchlist()
+	if err != nil {
+		return err
+	}
+
+	entries := []watchlist.Entry{}
+	for _, entry := range list.Entries() {
+		if usersListLabel == "" || entry.HasLabel(usersListLabel) {
+			entries = append(entries, entry)
+		}
+	}
+
+	if output == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(entries)
+	}
+
+	if len(entries) == 0 {
+		fmt.Println("No watched users. Add one with: star-watcher users add <username>")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "USER\tLABELS\tNOTIFY\tFULL SYNC\tADDED")
+	for _, entry := range entries {
+		labels := strings.Join(entry.Labels, ",")
+		if labels == "" {
+			labels = "-"
+		}
+		sinks := strings.Join(entry.Notify, ",")
+		if sinks == "" {
+			sinks = "all"
+		}
+		fullSync := "config"
+		if entry.FullSyncInterval != nil {
+			fullSync = strconv.Itoa(*entry.FullSyncInterval) + "h"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n",
+			entry.Username, labels, sinks, fullSync, entry.AddedAt.Local().Format("2006-01-02 15:04"))
+	}
+	return w.Flush()
+}
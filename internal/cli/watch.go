@@ -0,0 +1,201 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/akme/gh-stars-watcher/internal/config"
+	"github.com/akme/gh-stars-watcher/internal/monitor"
+	"github.com/spf13/cobra"
+)
+
+// watchCmd represents the watch command
+var watchCmd = &cobra.Command{
+	Use:   "watch [username or usernames]",
+	Short: "Continuously monitor GitHub user(s) on a schedule",
+	Long: `Keep running and monitor one or more GitHub users' starred repositories on an interval.
+
+Each cycle runs the same check as the monitor command for every user that is due and
+prints the results. Users run every --interval, on the cron expression in watch.schedule,
+or on their own entry in watch.schedules. A random jitter is added to every run, and when
+the GitHub rate limit is nearly exhausted lower-priority users wait until the limit resets.
+Next-run times are saved in schedule.json in the state directory, so a restart resumes the
+schedule instead of checking every user at once.
+
+Users are taken from the argument (comma-separated) and --users-file, or else from
+watch.users and watch.schedules in the config file and the watchlist (see the users command).
+Press Ctrl+C (or send SIGTERM) once to stop after the current cycle has saved its state,
+twice to abort immediately.
+
+Examples:
+  star-watcher watch octocat
+  star-watcher watch octocat,github --interval 30m --jitter 2m
+  star-watcher watch --output json`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runWatch,
+}
+
+var (
+	watchInterval time.Duration
+	watchJitter   time.Duration
+)
+
+func init() {
+	watchCmd.Flags().DurationVar(&watchInterval, "interval", 0, "time between cycles (default: watch.interval from config, 15m)")
+	watchCmd.Flags().DurationVar(&watchJitter, "jitter", 0, "maximum random delay added to each interval (default: watch.jitter from config, 1m)")
+}
+
+func runWatch(cmd *cobra.Command, args []string) error {
+	cfg, err := loadMonitorConfig()
+	if err != nil {
+		return err
+	}
+
+	if cmd.Flags().Changed("interval") {
+		cfg.Watch.Interval = watchInterval
+	}
+	if cmd.Flags().Changed("jitter") {
+		cfg.Watch.Jitter = watchJitter
+	}
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+
+	usernames, err := watchUsernames(args, cfg)
+	if err != nil {
+		return err
+	}
+
+	scheduler, err := newWatchScheduler(usernames, cfg)
+	if err != nil {
+		return err
+	}
+
+	formatter, err := newMonitorFormatter(cfg)
+	if err != nil {
+		return err
+	}
+
+	service, err := createMonitoringService(cmd.Context(), cfg)
+	if err != nil {
+		return fmt.Errorf("failed to create monitoring service: %w", err)
+	}
+
+	watcher := monitor.NewWatcher(service, scheduler)
+	dispatcher := newNotificationDispatcher(cfg, service.Logger())
+
+	// First signal stops after the current cycle, second aborts it
+	ctx, cancel := context.WithCancel(cmd.Context())
+	defer cancel()
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+	go func() {
+		select {
+		case <-signals:
+		case <-ctx.Done():
+			return
+		}
+		if !quiet {
+			log.Printf("Stopping after the current cycle (press Ctrl+C again to abort)")
+		}
+		watcher.Stop()
+
+		select {
+		case <-signals:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	if !quiet {
+		log.Printf("Watching %d user(s) on schedule %q (jitter up to %s): %s",
+			len(usernames), defaultScheduleSpec(cfg), cfg.Watch.Jitter, strings.Join(usernames, ", "))
+	}
+
+	err = watcher.Run(ctx, func(cycle *monitor.CycleResult) {
+		if !quiet && output != "json" {
+			fmt.Print("\r\033[K") // Clear the progress line before results
+		}
+
+		if err := formatter.FormatCycleResult(cycle); err != nil {
+			log.Printf("Warning: failed to format cycle results: %v", err)
+		}
+		dispatchNotifications(ctx, dispatcher, cycle.Results)
+
+		if !quiet {
+			if cycle.RateLimit != nil {
+				log.Printf("Rate limit nearly exhausted (%d remaining, resets at %s), next cycle at %s",
+					cycle.RateLimit.Remaining, cycle.RateLimit.ResetTime.Format("2006-01-02 15:04:05"),
+					cycle.NextRun.Format("2006-01-02 15:04:05"))
+			} else {
+				log.Printf("Next cycle at %s", cycle.NextRun.Format("2006-01-02 15:04:05"))
+			}
+		}
+	})
+	if err != nil && err != context.Canceled {
+		return err
+	}
+
+	if !quiet {
+		log.Printf("Watch stopped")
+	}
+	return nil
+}
+
+// watchUsernames returns the users to watch: those given as argument and in --users-file,
+// or watch.users, every user with an entry in watch.schedules and the watchlist
+func watchUsernames(args []string, cfg *config.Config) ([]string, error) {
+	usernames, err := argumentUsernames(args)
+	if err != nil {
+		return nil, fmt.Errorf("no users to watch: %w", err)
+	}
+	if len(usernames) > 0 {
+		return usernames, nil
+	}
+
+	candidates := append([]string{}, cfg.Watch.Users...)
+	for _, schedule := range cfg.Watch.Schedules {
+		candidates = append(candidates, schedule.User)
+	}
+	list, err := loadWatchlist()
+	if err != nil {
+		return nil, err
+	}
+	candidates = append(candidates, list.Usernames()...)
+
+	usernames, err = parseUsernames(strings.Join(uniqueUsernames(candidates), ","))
+	if err != nil {
+		return nil, fmt.Errorf("no users to watch: %w", err)
+	}
+	return usernames, nil
+}
+
+// newWatchScheduler creates the scheduler for the watched users, persisted in the state directory
+func newWatchScheduler(usernames []string, cfg *config.Config) (*monitor.Scheduler, error) {
+	schedulePath := ""
+	if stateDir := getStateDir(); stateDir != "" {
+		schedulePath = filepath.Join(stateDir, "schedule.json")
+	}
+
+	scheduler, err := monitor.NewScheduler(usernames, cfg.Watch, schedulePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create schedule: %w", err)
+	}
+	return scheduler, nil
+}
+
+// defaultScheduleSpec describes the schedule used by users without their own entry
+func defaultScheduleSpec(cfg *config.Config) string {
+	if cfg.Watch.Schedule != "" {
+		return cfg.Watch.Schedule
+	}
+	return "@every " + cfg.Watch.Interval.String()
+}
@@ -2,7 +2,11 @@ package config
 
 import (
 	"fmt"
+	"net/url"
+	"strings"
 	"time"
+
+	"github.com/robfig/cron/v3"
 )
 
 // IncrementalConfig contains configuration options for incremental fetching
@@ -11,7 +15,7 @@ type IncrementalConfig struct {
 	Enabled bool `json:"enabled" yaml:"enabled"`
 
 	// FullSyncInterval specifies hours between full synchronizations
-	// 0 means never perform full sync after initial fetch
+	// 0 means perform a full sync on every run
 	// 24 means perform full sync every 24 hours
 	FullSyncInterval int `json:"full_sync_interval" yaml:"full_sync_interval"`
 
@@ -69,11 +73,189 @@ type LoggingConfig struct {
 	LogAPICallsSaved bool `json:"log_api_calls_saved" yaml:"log_api_calls_saved"`
 }
 
+// OutputConfig contains configuration for the terminal output
+type OutputConfig struct {
+	// Template is a Go template file rendering each monitor result in text output;
+	// empty uses the built-in layout
+	Template string `json:"template" yaml:"template"`
+}
+
+// StorageConfig selects where monitoring state is kept
+type StorageConfig struct {
+	// URL is "json" for one JSON file per user in the state directory, or
+	// "sqlite://path" for a single SQLite database; "sqlite://" uses star-watcher.db
+	// in the state directory
+	URL string `json:"url" yaml:"url"`
+
+	// LockTimeout is how long a run waits for another run of the same user to finish
+	// before failing; 0 fails at once
+	LockTimeout time.Duration `json:"lock_timeout" yaml:"lock_timeout"`
+
+	// Snapshots is how many replaced states are kept per user for `state restore`;
+	// 0 disables snapshots
+	Snapshots int `json:"snapshots" yaml:"snapshots"`
+
+	// SnapshotMaxAge removes older snapshots, except the newest one; 0 keeps them
+	// regardless of age
+	SnapshotMaxAge time.Duration `json:"snapshot_max_age" yaml:"snapshot_max_age"`
+
+	// Compression of JSON state files: none, gzip or zstd. Existing files are read
+	// whatever their compression; SQLite storage ignores it.
+	Compression string `json:"compression" yaml:"compression"`
+
+	// Encrypt seals the starred repositories of saved state, snapshots and events with
+	// the key from STAR_WATCHER_STATE_KEY or the keychain; see `state rekey`
+	Encrypt bool `json:"encrypt" yaml:"encrypt"`
+}
+
+// SafetyConfig guards the stored state against truncated fetches that would report
+// most starred repositories as unstarred
+type SafetyConfig struct {
+	// MaxDropPercent is the largest drop in starred repositories since the previous run,
+	// in percent, that is saved without --accept-large-changes; 0 disables the guard
+	MaxDropPercent int `json:"max_drop_percent" yaml:"max_drop_percent"`
+
+	// MinDrop is the smallest drop, in repositories, that is checked at all, so users
+	// with few stars can unstar freely
+	MinDrop int `json:"min_drop" yaml:"min_drop"`
+}
+
+// MonitorConfig limits how hard a run of several users hits the GitHub API
+type MonitorConfig struct {
+	// Concurrency is the number of users monitored at the same time
+	Concurrency int `json:"concurrency" yaml:"concurrency"`
+
+	// RateLimitReserve is the number of API requests left unused: when the remaining
+	// quota drops to it, every worker pauses until the rate limit resets
+	RateLimitReserve int `json:"rate_limit_reserve" yaml:"rate_limit_reserve"`
+
+	// MemberRefreshInterval is how long the resolved members of an organization or team
+	// are reused before they are looked up again (0 = every run)
+	MemberRefreshInterval time.Duration `json:"member_refresh_interval" yaml:"member_refresh_interval"`
+}
+
+// WatchConfig contains configuration for the long-running watch mode
+type WatchConfig struct {
+	// Interval is the time between monitoring cycles
+	Interval time.Duration `json:"interval" yaml:"interval"`
+
+	// Jitter is the maximum random delay added to each interval to spread load
+	Jitter time.Duration `json:"jitter" yaml:"jitter"`
+
+	// Users lists the GitHub users to watch when none are given on the command line
+	Users []string `json:"users" yaml:"users"`
+
+	// Schedule is the default cron expression for users without their own schedule.
+	// When empty, users are checked every Interval.
+	Schedule string `json:"schedule" yaml:"schedule"`
+
+	// Schedules assigns individual cron schedules and priorities to users
+	Schedules []UserSchedule `json:"schedules" yaml:"schedules"`
+}
+
+// UserSchedule is the schedule of a single watched user
+type UserSchedule struct {
+	// User is the GitHub username
+	User string `json:"user" yaml:"user"`
+
+	// Cron is a standard 5-field cron expression or descriptor such as @hourly or @every 2h
+	Cron string `json:"cron" yaml:"cron"`
+
+	// Priority orders users that are due at the same time; higher runs first and is
+	// the last to be deferred when the rate limit runs low
+	Priority int `json:"priority" yaml:"priority"`
+}
+
+// NotifyConfig contains the notification sinks that receive detected star changes
+type NotifyConfig struct {
+	Webhook WebhookConfig `json:"webhook" yaml:"webhook"`
+	Slack   ChatConfig    `json:"slack" yaml:"slack"`
+	Discord ChatConfig    `json:"discord" yaml:"discord"`
+	Email   EmailConfig   `json:"email" yaml:"email"`
+	Exec    ExecConfig    `json:"exec" yaml:"exec"`
+}
+
+// WebhookConfig configures the generic HTTP webhook sink
+type WebhookConfig struct {
+	// URL receives a JSON POST for every result with changes; empty disables the webhook
+	URL string `json:"url" yaml:"url"`
+
+	// Secret signs each payload with HMAC-SHA256 in the X-Star-Watcher-Signature header
+	Secret string `json:"secret" yaml:"secret" secret:"true"`
+
+	// Timeout limits a single delivery attempt
+	Timeout time.Duration `json:"timeout" yaml:"timeout"`
+}
+
+// ChatConfig configures a chat incoming webhook (Slack or Discord)
+type ChatConfig struct {
+	// WebhookURL is the incoming webhook of the channel; empty disables the sink
+	WebhookURL string `json:"webhook_url" yaml:"webhook_url" secret:"true"`
+
+	// Template is a Go template file rendering each user's changes; empty uses the built-in layout
+	Template string `json:"template" yaml:"template"`
+
+	// Timeout limits a single delivery attempt
+	Timeout time.Duration `json:"timeout" yaml:"timeout"`
+}
+
+// EmailConfig configures the SMTP email digest
+type EmailConfig struct {
+	// Host is the SMTP server; empty disables the email digest
+	Host string `json:"host" yaml:"host"`
+
+	// Port is the SMTP server port
+	Port int `json:"port" yaml:"port"`
+
+	// Security is one of starttls, tls (implicit TLS, usually port 465) or none
+	Security string `json:"security" yaml:"security"`
+
+	// Username and Password enable SMTP PLAIN authentication when Username is set
+	Username string `json:"username" yaml:"username"`
+	Password string `json:"password" yaml:"password" secret:"true"`
+
+	// From is the sender address
+	From string `json:"from" yaml:"from"`
+
+	// To lists the recipient addresses
+	To []string `json:"to" yaml:"to"`
+
+	// Digest is how often accumulated changes are sent: daily or weekly
+	Digest string `json:"digest" yaml:"digest"`
+
+	// Timeout limits a single delivery attempt
+	Timeout time.Duration `json:"timeout" yaml:"timeout"`
+}
+
+// ExecConfig configures the hook that runs an external command for detected changes
+type ExecConfig struct {
+	// Command is run with "sh -c"; empty disables the hook
+	Command string `json:"command" yaml:"command"`
+
+	// Per is "result" to run the command once per user with changes, or "repository"
+	// to run it once per changed repository
+	Per string `json:"per" yaml:"per"`
+
+	// Timeout limits a single run of the command
+	Timeout time.Duration `json:"timeout" yaml:"timeout"`
+}
+
+// ParseSchedule parses a cron expression or descriptor (@hourly, @every 30m, ...)
+func ParseSchedule(spec string) (cron.Schedule, error) {
+	return cron.ParseStandard(spec)
+}
+
 // Config contains all configuration options for the star watcher
 type Config struct {
 	Incremental IncrementalConfig `json:"incremental" yaml:"incremental"`
 	Retry       RetryConfig       `json:"retry" yaml:"retry"`
 	Logging     LoggingConfig     `json:"logging" yaml:"logging"`
+	Output      OutputConfig      `json:"output" yaml:"output"`
+	Storage     StorageConfig     `json:"storage" yaml:"storage"`
+	Safety      SafetyConfig      `json:"safety" yaml:"safety"`
+	Monitor     MonitorConfig     `json:"monitor" yaml:"monitor"`
+	Watch       WatchConfig       `json:"watch" yaml:"watch"`
+	Notify      NotifyConfig      `json:"notify" yaml:"notify"`
 }
 
 // DefaultConfig returns a configuration with sensible defaults
@@ -102,39 +284,114 @@ func DefaultConfig() *Config {
 			EnablePerformanceMetrics: true,
 			LogAPICallsSaved:         true,
 		},
+		Storage: StorageConfig{
+			URL:         "json",
+			LockTimeout: 30 * time.Second,
+			Snapshots:   5,
+			Compression: "none",
+		},
+		Safety: SafetyConfig{
+			MaxDropPercent: 50,
+			MinDrop:        10,
+		},
+		Monitor: MonitorConfig{
+			Concurrency:           4,
+			RateLimitReserve:      20,
+			MemberRefreshInterval: 6 * time.Hour,
+		},
+		Watch: WatchConfig{
+			Interval: 15 * time.Minute,
+			Jitter:   1 * time.Minute,
+		},
+		Notify: NotifyConfig{
+			Webhook: WebhookConfig{
+				Timeout: 10 * time.Second,
+			},
+			Slack: ChatConfig{
+				Timeout: 10 * time.Second,
+			},
+			Discord: ChatConfig{
+				Timeout: 10 * time.Second,
+			},
+			Email: EmailConfig{
+				Port:     587,
+				Security: "starttls",
+				Digest:   "daily",
+				Timeout:  30 * time.Second,
+			},
+			Exec: ExecConfig{
+				Per:     "result",
+				Timeout: 30 * time.Second,
+			},
+		},
+	}
+}
+
+// FieldError describes a single invalid configuration value
+type FieldError struct {
+	Field   string // Dotted configuration key, e.g. "retry.max_delay"
+	Message string
+}
+
+func (e *FieldError) Error() string {
+	return e.Field + ": " + e.Message
+}
+
+// ValidationError collects every invalid field found by Validate
+type ValidationError struct {
+	Errors []*FieldError
+}
+
+func (e *ValidationError) Error() string {
+	messages := make([]string, len(e.Errors))
+	for i, fieldErr := range e.Errors {
+		messages[i] = fieldErr.Error()
 	}
+	return "invalid configuration: " + strings.Join(messages, "; ")
 }
 
-// Validate checks if the configuration values are valid
+// Validate checks if the configuration values are valid.
+// It never modifies the configuration; all problems are reported as a *ValidationError.
 func (c *Config) Validate() error {
+	var errs []*FieldError
+	invalid := func(field, format string, args ...interface{}) {
+		errs = append(errs, &FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+	}
+
 	// Validate incremental config
 	if c.Incremental.FullSyncInterval < 0 {
-		return fmt.Errorf("full_sync_interval must be non-negative")
+		invalid("incremental.full_sync_interval", "must be non-negative, got %d", c.Incremental.FullSyncInterval)
 	}
 
 	if c.Incremental.MaxIncrementalPages <= 0 {
-		c.Incremental.MaxIncrementalPages = 10 // Set default
+		invalid("incremental.max_incremental_pages", "must be positive, got %d", c.Incremental.MaxIncrementalPages)
 	}
 
 	if c.Incremental.TimestampTolerance < 0 {
-		c.Incremental.TimestampTolerance = 1 * time.Minute // Set default
+		invalid("incremental.timestamp_tolerance", "must be non-negative, got %s", c.Incremental.TimestampTolerance)
 	}
 
 	// Validate retry config
 	if c.Retry.MaxRetries < 0 {
-		c.Retry.MaxRetries = 3 // Set to default
+		invalid("retry.max_retries", "must be non-negative, got %d", c.Retry.MaxRetries)
 	}
 
 	if c.Retry.InitialDelay <= 0 {
-		c.Retry.InitialDelay = 1 * time.Second
+		invalid("retry.initial_delay", "must be positive, got %s", c.Retry.InitialDelay)
 	}
 
 	if c.Retry.MaxDelay <= 0 {
-		c.Retry.MaxDelay = 30 * time.Second
+		invalid("retry.max_delay", "must be positive, got %s", c.Retry.MaxDelay)
+	} else if c.Retry.MaxDelay < c.Retry.InitialDelay {
+		invalid("retry.max_delay", "must not be less than retry.initial_delay (%s), got %s", c.Retry.InitialDelay, c.Retry.MaxDelay)
 	}
 
 	if c.Retry.BackoffMultiplier <= 1.0 {
-		c.Retry.BackoffMultiplier = 2.0
+		invalid("retry.backoff_multiplier", "must be greater than 1.0, got %g", c.Retry.BackoffMultiplier)
+	}
+
+	if c.Retry.RateLimitBuffer < 0 {
+		invalid("retry.rate_limit_buffer", "must be non-negative, got %s", c.Retry.RateLimitBuffer)
 	}
 
 	// Validate logging config
@@ -146,7 +403,7 @@ func (c *Config) Validate() error {
 	}
 
 	if !validLogLevels[c.Logging.LogLevel] {
-		c.Logging.LogLevel = "info"
+		invalid("logging.log_level", "must be one of error, warn, info, debug, got %q", c.Logging.LogLevel)
 	}
 
 	validLogFormats := map[string]bool{
@@ -155,7 +412,158 @@ func (c *Config) Validate() error {
 	}
 
 	if !validLogFormats[c.Logging.LogFormat] {
-		c.Logging.LogFormat = "text"
+		invalid("logging.log_format", "must be one of json, text, got %q", c.Logging.LogFormat)
+	}
+
+	// Validate storage config
+	if c.Storage.URL != "json" && !strings.HasPrefix(c.Storage.URL, "sqlite://") {
+		invalid("storage.url", "must be json or sqlite://path, got %q", c.Storage.URL)
+	}
+
+	if c.Storage.LockTimeout < 0 {
+		invalid("storage.lock_timeout", "must be non-negative, got %s", c.Storage.LockTimeout)
+	}
+
+	validCompressions := map[string]bool{
+		"none": true,
+		"gzip": true,
+		"zstd": true,
+	}
+
+	if !validCompressions[c.Storage.Compression] {
+		invalid("storage.compression", "must be one of none, gzip, zstd, got %q", c.Storage.Compression)
+	}
+
+	if c.Storage.Snapshots < 0 {
+		invalid("storage.snapshots", "must be non-negative, got %d", c.Storage.Snapshots)
+	}
+
+	if c.Storage.SnapshotMaxAge < 0 {
+		invalid("storage.snapshot_max_age", "must be non-negative, got %s", c.Storage.SnapshotMaxAge)
+	}
+
+	// Validate safety config
+	if c.Safety.MaxDropPercent < 0 || c.Safety.MaxDropPercent > 100 {
+		invalid("safety.max_drop_percent", "must be between 0 and 100, got %d", c.Safety.MaxDropPercent)
+	}
+
+	if c.Safety.MinDrop < 0 {
+		invalid("safety.min_drop", "must be non-negative, got %d", c.Safety.MinDrop)
+	}
+
+	// Validate monitor config
+	if c.Monitor.Concurrency < 1 {
+		invalid("monitor.concurrency", "must be at least 1, got %d", c.Monitor.Concurrency)
+	}
+
+	if c.Monitor.RateLimitReserve < 0 {
+		invalid("monitor.rate_limit_reserve", "must be non-negative, got %d", c.Monitor.RateLimitReserve)
+	}
+
+	if c.Monitor.MemberRefreshInterval < 0 {
+		invalid("monitor.member_refresh_interval", "must be non-negative, got %s", c.Monitor.MemberRefreshInterval)
+	}
+
+	// Validate watch config
+	if c.Watch.Interval < time.Minute {
+		invalid("watch.interval", "must be at least 1m, got %s", c.Watch.Interval)
+	}
+
+	if c.Watch.Jitter < 0 {
+		invalid("watch.jitter", "must be non-negative, got %s", c.Watch.Jitter)
+	}
+
+	if c.Watch.Schedule != "" {
+		if _, err := ParseSchedule(c.Watch.Schedule); err != nil {
+			invalid("watch.schedule", "invalid cron expression %q: %v", c.Watch.Schedule, err)
+		}
+	}
+
+	scheduledUsers := make(map[string]bool)
+	for i, schedule := range c.Watch.Schedules {
+		field := fmt.Sprintf("watch.schedules[%d]", i)
+		if schedule.User == "" {
+			invalid(field+".user", "must not be empty")
+		} else if scheduledUsers[strings.ToLower(schedule.User)] {
+			invalid(field+".user", "duplicate schedule for %s", schedule.User)
+		}
+		scheduledUsers[strings.ToLower(schedule.User)] = true
+
+		if schedule.Cron != "" {
+			if _, err := ParseSchedule(schedule.Cron); err != nil {
+				invalid(field+".cron", "invalid cron expression %q: %v", schedule.Cron, err)
+			}
+		}
+	}
+
+	// Validate notify config
+	validateURL := func(field, value string, secret bool) {
+		if value == "" {
+			return
+		}
+		if u, err := url.Parse(value); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			if secret {
+				invalid(field, "must be an absolute http or https URL")
+			} else {
+				invalid(field, "must be an absolute http or https URL, got %q", value)
+			}
+		}
+	}
+
+	validateURL("notify.webhook.url", c.Notify.Webhook.URL, false)
+	validateURL("notify.slack.webhook_url", c.Notify.Slack.WebhookURL, true)
+	validateURL("notify.discord.webhook_url", c.Notify.Discord.WebhookURL, true)
+
+	validateTimeout := func(field string, timeout time.Duration) {
+		if timeout <= 0 {
+			invalid(field, "must be positive, got %s", timeout)
+		}
+	}
+
+	validateTimeout("notify.webhook.timeout", c.Notify.Webhook.Timeout)
+	validateTimeout("notify.slack.timeout", c.Notify.Slack.Timeout)
+	validateTimeout("notify.discord.timeout", c.Notify.Discord.Timeout)
+	validateTimeout("notify.email.timeout", c.Notify.Email.Timeout)
+	validateTimeout("notify.exec.timeout", c.Notify.Exec.Timeout)
+
+	if c.Notify.Exec.Per != "result" && c.Notify.Exec.Per != "repository" {
+		invalid("notify.exec.per", "must be one of result, repository, got %q", c.Notify.Exec.Per)
+	}
+
+	if c.Notify.Email.Port <= 0 || c.Notify.Email.Port > 65535 {
+		invalid("notify.email.port", "must be between 1 and 65535, got %d", c.Notify.Email.Port)
+	}
+
+	validSecurity := map[string]bool{
+		"starttls": true,
+		"tls":      true,
+		"none":     true,
+	}
+
+	if !validSecurity[c.Notify.Email.Security] {
+		invalid("notify.email.security", "must be one of starttls, tls, none, got %q", c.Notify.Email.Security)
+	}
+
+	validDigests := map[string]bool{
+		"daily":  true,
+		"weekly": true,
+	}
+
+	if !validDigests[c.Notify.Email.Digest] {
+		invalid("notify.email.digest", "must be one of daily, weekly, got %q", c.Notify.Email.Digest)
+	}
+
+	if c.Notify.Email.Host != "" {
+		if c.Notify.Email.From == "" {
+			invalid("notify.email.from", "must be set when notify.email.host is set")
+		}
+		if len(c.Notify.Email.To) == 0 {
+			invalid("notify.email.to", "must list at least one recipient when notify.email.host is set")
+		}
+	}
+
+	if len(errs) > 0 {
+		return &ValidationError{Errors: errs}
 	}
 
 	return nil
@@ -0,0 +1,187 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// field is a single leaf configuration value addressable by its dotted key
+type field struct {
+	Key    string        // Dotted key built from yaml tags, e.g. "retry.max_delay"
+	Value  reflect.Value // Settable value inside a Config
+	Secret bool          // Tagged secret:"true"; hidden when displayed
+}
+
+// fields returns every leaf value of the configuration in declaration order
+func (c *Config) fields() []field {
+	return collectFields(reflect.ValueOf(c).Elem(), "")
+}
+
+// collectFields walks nested structs and builds dotted keys from their yaml tags
+func collectFields(v reflect.Value, prefix string) []field {
+	var result []field
+	t := v.Type()
+
+	for i := 0; i < t.NumField(); i++ {
+		structField := t.Field(i)
+		name := strings.Split(structField.Tag.Get("yaml"), ",")[0]
+		if name == "" || name == "-" {
+			continue
+		}
+
+		key := name
+		if prefix != "" {
+			key = prefix + "." + name
+		}
+
+		value := v.Field(i)
+		if value.Kind() == reflect.Struct && value.Type() != durationType {
+			result = append(result, collectFields(value, key)...)
+			continue
+		}
+		// Lists of structs, such as watch.schedules, have no single-line form and are
+		// only set in the config file
+		if value.Kind() == reflect.Slice && value.Type().Elem().Kind() == reflect.Struct {
+			continue
+		}
+
+		result = append(result, field{Key: key, Value: value, Secret: structField.Tag.Get("secret") == "true"})
+	}
+
+	return result
+}
+
+// EnvName returns the environment variable that overrides a dotted key
+func EnvName(key string) string {
+	return EnvPrefix + strings.ToUpper(strings.ReplaceAll(key, ".", "_"))
+}
+
+// setFromString parses raw according to the kind of v and stores the result
+func setFromString(v reflect.Value, raw string) error {
+	raw = strings.TrimSpace(raw)
+
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q", raw)
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q", raw)
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(raw, 10, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", raw)
+		}
+		v.SetInt(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(raw, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("invalid number %q", raw)
+		}
+		v.SetFloat(f)
+	case reflect.String:
+		v.SetString(raw)
+	case reflect.Slice:
+		if v.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("unsupported list type %s", v.Type())
+		}
+		var items []string
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		v.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+
+	return nil
+}
+
+// formatValue renders a leaf value the way it would be written in a config file
+func formatValue(v reflect.Value) string {
+	if v.Type() == durationType {
+		return time.Duration(v.Int()).String()
+	}
+
+	if v.Kind() == reflect.Slice {
+		if v.Len() == 0 {
+			return ""
+		}
+		items := make([]string, v.Len())
+		for i := range items {
+			items[i] = fmt.Sprint(v.Index(i).Interface())
+		}
+		return strings.Join(items, ",")
+	}
+
+	return fmt.Sprint(v.Interface())
+}
+
+// Keys returns the dotted keys of all configuration values in declaration order
+func (c *Config) Keys() []string {
+	fields := c.fields()
+	keys := make([]string, len(fields))
+	for i, f := range fields {
+		keys[i] = f.Key
+	}
+	return keys
+}
+
+// Get returns the value of a dotted key formatted as it would appear in a config file
+func (c *Config) Get(key string) (string, error) {
+	for _, f := range c.fields() {
+		if f.Key == key {
+			return formatValue(f.Value), nil
+		}
+	}
+	return "", &UnknownKeyError{Key: key}
+}
+
+// IsSecret reports whether the dotted key holds a credential that should not be displayed
+func (c *Config) IsSecret(key string) bool {
+	for _, f := range c.fields() {
+		if f.Key == key {
+			return f.Secret
+		}
+	}
+	return false
+}
+
+// Set parses value according to the type of the dotted key and stores it.
+// The configuration is not validated; call Validate afterwards.
+func (c *Config) Set(key, value string) error {
+	for _, f := range c.fields() {
+		if f.Key == key {
+			if err := setFromString(f.Value, value); err != nil {
+				return &FieldError{Field: key, Message: err.Error()}
+			}
+			return nil
+		}
+	}
+	return &UnknownKeyError{Key: key}
+}
+
+// UnknownKeyError is returned when a dotted key does not name a configuration value
+type UnknownKeyError struct {
+	Key string
+}
+
+func (e *UnknownKeyError) Error() string {
+	return "unknown configuration key: " + e.Key
+}
@@ -0,0 +1,305 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// defaultFileContent is the commented config file written by `config init`.
+// Its values must match DefaultConfig.
+const defaultFileContent = `# star-watcher configuration
+#
+# Every value can be overridden with an environment variable named after its key,
+# e.g. STAR_WATCHER_RETRY_MAX_DELAY=45s overrides retry.max_delay.
+
+incremental:
+  # Fetch only newly starred repositories between full syncs
+  enabled: true
+  # Hours between full synchronizations (0 = every run)
+  full_sync_interval: 24
+  # Fall back to a full sync when an incremental fetch fails
+  fallback_on_error: true
+  # Maximum pages (100 repositories each) fetched incrementally per run
+  max_incremental_pages: 10
+  # Report repositories that are no longer starred (requires full syncs)
+  detect_unstars: true
+  # Report repositories that were unstarred and starred again
+  detect_re_stars: true
+  # Allowed clock skew when comparing starred_at timestamps
+  timestamp_tolerance: 1m0s
+
+retry:
+  # Maximum number of retry attempts for failed API calls
+  max_retries: 3
+  # Delay before the first retry
+  initial_delay: 1s
+  # Upper bound for the exponential backoff delay
+  max_delay: 30s
+  # Multiplier applied to the delay after each attempt (must be > 1)
+  backoff_multiplier: 2
+  # Wait for the rate limit to reset instead of failing
+  retry_on_rate_limit: true
+  # Extra time added when waiting for a rate limit reset
+  rate_limit_buffer: 30s
+
+logging:
+  # One of: error, warn, info, debug
+  log_level: info
+  # One of: text, json
+  log_format: text
+  # Log timing information for each monitor run
+  enable_performance_metrics: true
+  # Log how many API calls incremental fetching saved
+  log_api_calls_saved: true
+
+output:
+  # Go template file for text output, rendered once per user; empty uses the built-in
+  # layout. Files ending in .html use html/template.
+  template: ""
+
+storage:
+  # Where state is kept: json (one file per user in ~/.star-watcher) or
+  # sqlite://path for a single database; sqlite:// uses ~/.star-watcher/star-watcher.db
+  url: json
+  # How long a run waits for another run of the same user to finish (0 = fail at once)
+  lock_timeout: 30s
+  # Replaced states kept per user for "state restore" (0 = no snapshots)
+  snapshots: 5
+  # Remove snapshots older than this, except the newest (0 = keep regardless of age)
+  snapshot_max_age: 0s
+  # Compression of JSON state files: none, gzip or zstd (existing files are read
+  # whatever their compression; ignored by SQLite)
+  compression: none
+  # Encrypt the starred repositories in state, snapshots and events with the key from
+  # STAR_WATCHER_STATE_KEY or the keychain ("state rekey" creates one)
+  encrypt: false
+
+safety:
+  # Refuse to save a run whose starred repositories dropped by more than this percentage
+  # since the previous run, unless GitHub's starred count confirms it or
+  # --accept-large-changes is given (0 = no check)
+  max_drop_percent: 50
+  # Drops of fewer repositories are never checked
+  min_drop: 10
+
+monitor:
+  # Users monitored at the same time when several are given
+  concurrency: 4
+  # API requests kept unused; all users pause when the remaining rate limit drops to
+  # this many, until it resets
+  rate_limit_reserve: 20
+  # How long the members of an organization or team (--org, --team) are reused before
+  # they are looked up again (0s = every run)
+  member_refresh_interval: 6h0m0s
+
+watch:
+  # Time between monitoring cycles in watch mode
+  interval: 15m0s
+  # Maximum random delay added to each interval
+  jitter: 1m0s
+  # Users to watch when none are given on the command line
+  users: []
+  # Default cron expression (e.g. "*/30 * * * *" or "@hourly"); empty = every interval
+  schedule: ""
+  # Per-user cron schedules and priorities, e.g.
+  #   - user: octocat
+  #     cron: "*/10 * * * *"
+  #     priority: 10
+  schedules: []
+
+notify:
+  webhook:
+    # POST a JSON payload for every run with changes; empty disables the webhook
+    url: ""
+    # Sign payloads with HMAC-SHA256 (X-Star-Watcher-Signature header); prefer
+    # STAR_WATCHER_NOTIFY_WEBHOOK_SECRET over storing it here
+    secret: ""
+    # Timeout for a single delivery attempt
+    timeout: 10s
+  slack:
+    # Incoming webhook URL (https://hooks.slack.com/services/...); empty disables Slack
+    webhook_url: ""
+    # Go template file (same as output.template) rendered as mrkdwn; empty = built-in layout
+    template: ""
+    timeout: 10s
+  discord:
+    # Webhook URL (https://discord.com/api/webhooks/...); empty disables Discord
+    webhook_url: ""
+    # Go template file (same as output.template) rendered as markdown; empty = built-in layout
+    template: ""
+    timeout: 10s
+  email:
+    # SMTP server; empty disables the email digest
+    host: ""
+    port: 587
+    # One of: starttls, tls (implicit TLS, usually port 465), none
+    security: starttls
+    # SMTP authentication (prefer STAR_WATCHER_NOTIFY_EMAIL_PASSWORD for the password)
+    username: ""
+    password: ""
+    from: ""
+    # Recipient addresses
+    to: []
+    # How often accumulated changes are mailed: daily or weekly
+    digest: daily
+    # Timeout for a single delivery attempt
+    timeout: 30s
+  exec:
+    # Command run with "sh -c" for detected changes; empty disables the hook.
+    # The change is passed as JSON on stdin and as STAR_* environment variables.
+    command: ""
+    # Run the command once per user with changes (result) or per changed repository
+    per: result
+    # Timeout for a single run of the command
+    timeout: 30s
+`
+
+// DefaultFileContent returns a commented config file containing the default values
+func DefaultFileContent() []byte {
+	return []byte(defaultFileContent)
+}
+
+// WriteDefaultFile writes the commented default config file to path.
+// An existing file is only replaced when overwrite is true.
+func WriteDefaultFile(path string, overwrite bool) error {
+	if _, err := os.Stat(path); err == nil && !overwrite {
+		return fmt.Errorf("config file already exists: %s", path)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create config directory: %v", err)
+	}
+
+	return writeFileAtomic(path, DefaultFileContent())
+}
+
+// SetFileValue sets a single dotted key in the config file at path, keeping comments
+// and unrelated values intact. The file is created from the default template when missing.
+// The change is validated before anything is written.
+func SetFileValue(path, key, value string) error {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		data = DefaultFileContent()
+	} else if err != nil {
+		return fmt.Errorf("failed to read config file: %v", err)
+	}
+
+	// Validate the resulting configuration before touching the file
+	cfg := DefaultConfig()
+	if err := yaml.Unmarshal(data, cfg); err != nil {
+		return fmt.Errorf("failed to parse config file %s: %v", path, err)
+	}
+	if err := cfg.Set(key, value); err != nil {
+		return err
+	}
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return fmt.Errorf("failed to parse config file %s: %v", path, err)
+	}
+	if root.Kind == 0 {
+		root = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode}}}
+	}
+
+	formatted, _ := cfg.Get(key)
+	fieldValue, _ := cfg.lookupField(key)
+	setNodeValue(root.Content[0], strings.Split(key, "."), formatted, fieldValue.Kind())
+
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&root); err != nil {
+		return fmt.Errorf("failed to encode config file: %v", err)
+	}
+	if err := encoder.Close(); err != nil {
+		return fmt.Errorf("failed to encode config file: %v", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create config directory: %v", err)
+	}
+
+	return writeFileAtomic(path, buf.Bytes())
+}
+
+// lookupField finds the leaf value for a dotted key
+func (c *Config) lookupField(key string) (reflect.Value, bool) {
+	for _, f := range c.fields() {
+		if f.Key == key {
+			return f.Value, true
+		}
+	}
+	return reflect.Value{}, false
+}
+
+// setNodeValue stores value under the key path of a mapping node, creating sections as needed
+func setNodeValue(mapping *yaml.Node, path []string, value string, kind reflect.Kind) {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value != path[0] {
+			continue
+		}
+		child := mapping.Content[i+1]
+		if len(path) > 1 {
+			if child.Kind != yaml.MappingNode {
+				*child = yaml.Node{Kind: yaml.MappingNode}
+			}
+			setNodeValue(child, path[1:], value, kind)
+			return
+		}
+		replaceNodeValue(child, value, kind)
+		return
+	}
+
+	// Key not present yet - append it
+	keyNode := &yaml.Node{Kind: yaml.ScalarNode, Value: path[0]}
+	child := &yaml.Node{Kind: yaml.MappingNode}
+	if len(path) > 1 {
+		setNodeValue(child, path[1:], value, kind)
+	} else {
+		replaceNodeValue(child, value, kind)
+	}
+	mapping.Content = append(mapping.Content, keyNode, child)
+}
+
+// replaceNodeValue overwrites a value node while keeping its comments
+func replaceNodeValue(node *yaml.Node, value string, kind reflect.Kind) {
+	headComment, lineComment, footComment := node.HeadComment, node.LineComment, node.FootComment
+
+	switch {
+	case kind == reflect.Slice:
+		*node = yaml.Node{Kind: yaml.SequenceNode, Style: yaml.FlowStyle}
+		if value != "" {
+			for _, item := range strings.Split(value, ",") {
+				node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: item})
+			}
+		}
+	case kind == reflect.String && value == "":
+		*node = yaml.Node{Kind: yaml.ScalarNode, Value: value, Style: yaml.DoubleQuotedStyle}
+	default:
+		*node = yaml.Node{Kind: yaml.ScalarNode, Value: value}
+	}
+
+	node.HeadComment, node.LineComment, node.FootComment = headComment, lineComment, footComment
+}
+
+// writeFileAtomic writes data to a temporary file and renames it into place
+func writeFileAtomic(path string, data []byte) error {
+	tempFile := path + ".tmp"
+	if err := os.WriteFile(tempFile, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write config file: %v", err)
+	}
+	if err := os.Rename(tempFile, path); err != nil {
+		os.Remove(tempFile)
+		return fmt.Errorf("failed to rename config file: %v", err)
+	}
+	return nil
+}
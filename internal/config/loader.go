@@ -0,0 +1,184 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// EnvPrefix is the prefix of environment variables that override configuration values.
+	// The rest of the name is the dotted key in upper case with dots replaced by underscores,
+	// e.g. STAR_WATCHER_RETRY_MAX_DELAY overrides retry.max_delay.
+	EnvPrefix = "STAR_WATCHER_"
+
+	// ConfigPathEnv points to a config file when --config is not given
+	ConfigPathEnv = "STAR_WATCHER_CONFIG"
+)
+
+// Source identifies where a configuration value came from
+type Source string
+
+const (
+	SourceDefault Source = "default"
+	SourceFile    Source = "file"
+	SourceEnv     Source = "env"
+)
+
+// Loaded is the effective configuration together with the origin of each value
+type Loaded struct {
+	Config  *Config
+	Path    string            // Config file that was read, empty when none was found
+	Sources map[string]Source // Origin of each value keyed by dotted key
+}
+
+// IsSet reports whether a value was set explicitly by a config file or environment variable
+func (l *Loaded) IsSet(key string) bool {
+	source, ok := l.Sources[key]
+	return ok && source != SourceDefault
+}
+
+// DefaultPath returns the default config file location (~/.config/star-watcher/config.yaml).
+// XDG_CONFIG_HOME is honoured when set.
+func DefaultPath() (string, error) {
+	configHome := os.Getenv("XDG_CONFIG_HOME")
+	if configHome == "" {
+		homeDir, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("failed to get home directory: %v", err)
+		}
+		configHome = filepath.Join(homeDir, ".config")
+	}
+	return filepath.Join(configHome, "star-watcher", "config.yaml"), nil
+}
+
+// Load builds the effective configuration: defaults, then the config file, then
+// STAR_WATCHER_* environment overrides. The result is validated before it is returned.
+//
+// When path is empty the file named by STAR_WATCHER_CONFIG or the default location
+// is used; a missing default file is not an error.
+func Load(path string) (*Loaded, error) {
+	loaded, err := Read(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := loaded.Config.Validate(); err != nil {
+		return nil, err
+	}
+
+	return loaded, nil
+}
+
+// ResolvePath returns the config file Load would read and whether it was requested explicitly
+func ResolvePath(path string) (string, bool, error) {
+	if path != "" {
+		return path, true, nil
+	}
+	if path = os.Getenv(ConfigPathEnv); path != "" {
+		return path, true, nil
+	}
+	defaultPath, err := DefaultPath()
+	return defaultPath, false, err
+}
+
+// Read is like Load but does not validate the result
+func Read(path string) (*Loaded, error) {
+	path, explicit, err := ResolvePath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	loaded := &Loaded{
+		Config:  DefaultConfig(),
+		Sources: make(map[string]Source),
+	}
+	for _, f := range loaded.Config.fields() {
+		loaded.Sources[f.Key] = SourceDefault
+	}
+
+	data, err := os.ReadFile(path)
+	switch {
+	case err == nil:
+		if err := loaded.applyFile(path, data); err != nil {
+			return nil, err
+		}
+		loaded.Path = path
+	case errors.Is(err, os.ErrNotExist) && !explicit:
+		// No config file at the default location - defaults apply
+	default:
+		return nil, fmt.Errorf("failed to read config file: %v", err)
+	}
+
+	if err := loaded.applyEnv(); err != nil {
+		return nil, err
+	}
+
+	return loaded, nil
+}
+
+// applyFile merges a YAML (or JSON) document over the current configuration
+func (l *Loaded) applyFile(path string, data []byte) error {
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(l.Config); err != nil && err != io.EOF {
+		return fmt.Errorf("failed to parse config file %s: %v", path, err)
+	}
+
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return fmt.Errorf("failed to parse config file %s: %v", path, err)
+	}
+	for _, key := range documentKeys(&root, "") {
+		if _, known := l.Sources[key]; known {
+			l.Sources[key] = SourceFile
+		}
+	}
+
+	return nil
+}
+
+// applyEnv applies STAR_WATCHER_* overrides for every known key
+func (l *Loaded) applyEnv() error {
+	for _, f := range l.Config.fields() {
+		name := EnvName(f.Key)
+		raw, ok := os.LookupEnv(name)
+		if !ok {
+			continue
+		}
+		if err := setFromString(f.Value, raw); err != nil {
+			return fmt.Errorf("invalid value for %s: %v", name, err)
+		}
+		l.Sources[f.Key] = SourceEnv
+	}
+	return nil
+}
+
+// documentKeys returns the dotted keys of all leaf values present in a YAML document
+func documentKeys(node *yaml.Node, prefix string) []string {
+	if node.Kind == yaml.DocumentNode {
+		if len(node.Content) == 0 {
+			return nil
+		}
+		return documentKeys(node.Content[0], prefix)
+	}
+
+	if node.Kind != yaml.MappingNode {
+		return []string{prefix}
+	}
+
+	var keys []string
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key := node.Content[i].Value
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+		keys = append(keys, documentKeys(node.Content[i+1], key)...)
+	}
+	return keys
+}
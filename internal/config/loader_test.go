@@ -0,0 +1,200 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeConfigFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+	return path
+}
+
+func TestLoad_MergesFileAndEnvOverDefaults(t *testing.T) {
+	path := writeConfigFile(t, `
+incremental:
+  max_incremental_pages: 25
+retry:
+  initial_delay: 2s
+  max_delay: 1m
+`)
+	t.Setenv("STAR_WATCHER_RETRY_MAX_RETRIES", "7")
+	t.Setenv("STAR_WATCHER_INCREMENTAL_FULL_SYNC_INTERVAL", "6")
+
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	cfg := loaded.Config
+	if cfg.Incremental.MaxIncrementalPages != 25 {
+		t.Errorf("MaxIncrementalPages = %d, want 25", cfg.Incremental.MaxIncrementalPages)
+	}
+	if cfg.Retry.InitialDelay != 2*time.Second || cfg.Retry.MaxDelay != time.Minute {
+		t.Errorf("retry delays = %s/%s, want 2s/1m", cfg.Retry.InitialDelay, cfg.Retry.MaxDelay)
+	}
+	if cfg.Retry.MaxRetries != 7 {
+		t.Errorf("MaxRetries = %d, want 7 from environment", cfg.Retry.MaxRetries)
+	}
+	if cfg.Incremental.FullSyncInterval != 6 {
+		t.Errorf("FullSyncInterval = %d, want 6 from environment", cfg.Incremental.FullSyncInterval)
+	}
+	if cfg.Retry.BackoffMultiplier != 2.0 {
+		t.Errorf("BackoffMultiplier = %g, want default 2.0", cfg.Retry.BackoffMultiplier)
+	}
+
+	wantSources := map[string]Source{
+		"incremental.max_incremental_pages": SourceFile,
+		"retry.max_retries":                 SourceEnv,
+		"retry.backoff_multiplier":          SourceDefault,
+	}
+	for key, want := range wantSources {
+		if got := loaded.Sources[key]; got != want {
+			t.Errorf("Sources[%q] = %q, want %q", key, got, want)
+		}
+	}
+	if loaded.Path != path {
+		t.Errorf("Path = %q, want %q", loaded.Path, path)
+	}
+}
+
+func TestLoad_ReportsFieldErrors(t *testing.T) {
+	path := writeConfigFile(t, `
+incremental:
+  max_incremental_pages: 0
+retry:
+  backoff_multiplier: 0.5
+`)
+
+	_, err := Load(path)
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("Load() error = %v, want *ValidationError", err)
+	}
+
+	fields := make(map[string]bool)
+	for _, fieldErr := range validationErr.Errors {
+		fields[fieldErr.Field] = true
+	}
+	for _, want := range []string{"incremental.max_incremental_pages", "retry.backoff_multiplier"} {
+		if !fields[want] {
+			t.Errorf("expected field error for %s, got %v", want, validationErr)
+		}
+	}
+}
+
+func TestLoad_RejectsUnknownKeysAndBadEnv(t *testing.T) {
+	if _, err := Load(writeConfigFile(t, "retry:\n  max_retires: 3\n")); err == nil {
+		t.Error("expected error for misspelled key")
+	}
+
+	t.Setenv("STAR_WATCHER_RETRY_MAX_DELAY", "soon")
+	if _, err := Load(writeConfigFile(t, "")); err == nil {
+		t.Error("expected error for unparsable environment override")
+	}
+}
+
+func TestLoad_ListsOfStructsAreFileOnly(t *testing.T) {
+	t.Setenv("STAR_WATCHER_WATCH_SCHEDULES", "ignored")
+	loaded, err := Load(writeConfigFile(t, `
+watch:
+  schedules:
+    - user: alice
+      priority: 5
+`))
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if schedules := loaded.Config.Watch.Schedules; len(schedules) != 1 || schedules[0].User != "alice" {
+		t.Errorf("Schedules = %+v, want alice from the file", schedules)
+	}
+
+	for _, key := range loaded.Config.Keys() {
+		if key == "watch.schedules" {
+			t.Error("Keys() includes watch.schedules, want it left to the config file")
+		}
+	}
+	var unknown *UnknownKeyError
+	if err := loaded.Config.Set("watch.schedules", "alice"); !errors.As(err, &unknown) {
+		t.Errorf("Set(watch.schedules) error = %v, want *UnknownKeyError", err)
+	}
+}
+
+func TestLoad_MissingFiles(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	loaded, err := Load("")
+	if err != nil {
+		t.Fatalf("Load() with no default file error = %v", err)
+	}
+	if loaded.Path != "" {
+		t.Errorf("Path = %q, want empty when no file exists", loaded.Path)
+	}
+
+	if _, err := Load(filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Error("expected error for explicitly requested missing file")
+	}
+}
+
+func TestDefaultFileContent_MatchesDefaultConfig(t *testing.T) {
+	loaded, err := Load(writeConfigFile(t, string(DefaultFileContent())))
+	if err != nil {
+		t.Fatalf("Load() of default file error = %v", err)
+	}
+
+	defaults := DefaultConfig()
+	for _, key := range defaults.Keys() {
+		want, _ := defaults.Get(key)
+		got, _ := loaded.Config.Get(key)
+		if got != want {
+			t.Errorf("%s = %s in default file, want %s", key, got, want)
+		}
+		if loaded.Sources[key] != SourceFile {
+			t.Errorf("%s missing from default file", key)
+		}
+	}
+}
+
+func TestSetFileValue_KeepsCommentsAndValidates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := WriteDefaultFile(path, false); err != nil {
+		t.Fatalf("WriteDefaultFile() error = %v", err)
+	}
+
+	if err := SetFileValue(path, "retry.max_delay", "2m"); err != nil {
+		t.Fatalf("SetFileValue() error = %v", err)
+	}
+	if err := SetFileValue(path, "retry.backoff_multiplier", "1"); err == nil {
+		t.Error("expected validation error for backoff multiplier of 1")
+	}
+	if err := SetFileValue(path, "retry.no_such_key", "1"); err == nil {
+		t.Error("expected error for unknown key")
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read config file: %v", err)
+	}
+	if !strings.Contains(string(data), "# Upper bound for the exponential backoff delay") {
+		t.Error("expected comments to be preserved")
+	}
+
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if loaded.Config.Retry.MaxDelay != 2*time.Minute {
+		t.Errorf("MaxDelay = %s, want 2m", loaded.Config.Retry.MaxDelay)
+	}
+	if loaded.Config.Retry.BackoffMultiplier != 2.0 {
+		t.Errorf("BackoffMultiplier = %g, want unchanged 2.0", loaded.Config.Retry.BackoffMultiplier)
+	}
+}
@@ -2,7 +2,9 @@ package github
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net/http"
 	"strconv"
 	"strings"
 	"time"
@@ -82,16 +84,8 @@ func (a *APIClient) GetStarredRepositories(ctx context.Context, username string,
 	// Make API call
 	starred, resp, err := a.client.Activity.ListStarred(ctx, username, listOpts)
 	if err != nil {
-		// Handle specific GitHub API errors
-		if strings.Contains(err.Error(), "404") {
-			return nil, &UserNotFoundError{Username: username}
-		}
-		if strings.Contains(err.Error(), "403") && strings.Contains(err.Error(), "rate limit") {
-			return nil, &RateLimitError{
-				ResetTime: time.Now().Add(time.Hour).Format(time.RFC3339),
-				Limit:     5000,
-				Used:      5000,
-			}
+		if apiErr := apiError(err, &UserNotFoundError{Username: username}); apiErr != nil {
+			return nil, apiErr
 		}
 		return nil, fmt.Errorf("GitHub API error: %v", err)
 	}
@@ -101,6 +95,8 @@ func (a *APIClient) GetStarredRepositories(ctx context.Context, username string,
 	for i, star := range starred {
 		repo := star.GetRepository()
 		repositories[i] = storage.Repository{
+			ID:          repo.GetID(),
+			NodeID:      repo.GetNodeID(),
 			FullName:    repo.GetFullName(),
 			Description: repo.GetDescription(),
 			StarCount:   repo.GetStargazersCount(),
@@ -137,6 +133,26 @@ func (a *APIClient) GetStarredRepositories(ctx context.Context, username string,
 	return response, nil
 }
 
+// GetStarredCount returns the number of repositories a user has starred publicly.
+// GitHub has no count field for stars, so it lists one star per page and reads the
+// number of the last page.
+func (a *APIClient) GetStarredCount(ctx context.Context, username string) (int, error) {
+	starred, resp, err := a.client.Activity.ListStarred(ctx, username, &github.ActivityListStarredOptions{
+		ListOptions: github.ListOptions{PerPage: 1},
+	})
+	if err != nil {
+		if apiErr := apiError(err, &UserNotFoundError{Username: username}); apiErr != nil {
+			return 0, apiErr
+		}
+		return 0, fmt.Errorf("GitHub API error: %v", err)
+	}
+
+	if resp.LastPage > 0 {
+		return resp.LastPage, nil
+	}
+	return len(starred), nil
+}
+
 // GetRateLimit returns current rate limit status
 func (a *APIClient) GetRateLimit(ctx context.Context) (*RateLimitInfo, error) {
 	rateLimits, _, err := a.client.RateLimits(ctx)
@@ -165,6 +181,66 @@ func (a *APIClient) ValidateUser(ctx context.Context, username string) error {
 	return nil
 }
 
+// GetMembers returns the logins of the members of an organization, or of one of its teams
+// when team is set. Without a token only public organization members are listed, and
+// teams need a token that can read the organization.
+func (a *APIClient) GetMembers(ctx context.Context, org, team string) ([]string, error) {
+	name := org
+	if team != "" {
+		name = org + "/" + team
+	}
+
+	var members []string
+	listOpts := github.ListOptions{PerPage: 100}
+	for {
+		var users []*github.User
+		var resp *github.Response
+		var err error
+		if team != "" {
+			users, resp, err = a.client.Teams.ListTeamMembersBySlug(ctx, org, team, &github.TeamListTeamMembersOptions{ListOptions: listOpts})
+		} else {
+			users, resp, err = a.client.Organizations.ListMembers(ctx, org, &github.ListMembersOptions{ListOptions: listOpts})
+		}
+		if err != nil {
+			if apiErr := apiError(err, &OrganizationNotFoundError{Name: name}); apiErr != nil {
+				return nil, apiErr
+			}
+			return nil, fmt.Errorf("failed to list members of %s: %v", name, err)
+		}
+
+		for _, user := range users {
+			members = append(members, user.GetLogin())
+		}
+		if resp.NextPage == 0 {
+			return members, nil
+		}
+		listOpts.Page = resp.NextPage
+	}
+}
+
+// GetFollowing returns the logins of the accounts a user follows
+func (a *APIClient) GetFollowing(ctx context.Context, username string) ([]string, error) {
+	var following []string
+	listOpts := &github.ListOptions{PerPage: 100}
+	for {
+		users, resp, err := a.client.Users.ListFollowing(ctx, username, listOpts)
+		if err != nil {
+			if apiErr := apiError(err, &UserNotFoundError{Username: username}); apiErr != nil {
+				return nil, apiErr
+			}
+			return nil, fmt.Errorf("failed to list accounts followed by %s: %v", username, err)
+		}
+
+		for _, user := range users {
+			following = append(following, user.GetLogin())
+		}
+		if resp.NextPage == 0 {
+			return following, nil
+		}
+		listOpts.Page = resp.NextPage
+	}
+}
+
 // ValidateToken validates a GitHub personal access token
 func (a *APIClient) ValidateToken(ctx context.Context, token string) (bool, error) {
 	// Create a temporary client with the token
@@ -182,3 +258,33 @@ func (a *APIClient) ValidateToken(ctx context.Context, token string) (bool, erro
 	}
 	return true, nil
 }
+
+// apiError translates a go-github error into the errors of this package: a RateLimitError
+// when the rate limit is exhausted, or notFound when the resource does not exist. Other
+// errors yield nil.
+func apiError(err error, notFound error) error {
+	var rateLimitErr *github.RateLimitError
+	if errors.As(err, &rateLimitErr) {
+		return &RateLimitError{
+			ResetTime: rateLimitErr.Rate.Reset.Time.Format(time.RFC3339),
+			Limit:     rateLimitErr.Rate.Limit,
+			Used:      rateLimitErr.Rate.Limit - rateLimitErr.Rate.Remaining,
+		}
+	}
+
+	// Secondary rate limits only tell how long to wait
+	var abuseErr *github.AbuseRateLimitError
+	if errors.As(err, &abuseErr) {
+		wait := time.Minute
+		if abuseErr.RetryAfter != nil {
+			wait = *abuseErr.RetryAfter
+		}
+		return &RateLimitError{ResetTime: time.Now().Add(wait).Format(time.RFC3339)}
+	}
+
+	var errResp *github.ErrorResponse
+	if errors.As(err, &errResp) && errResp.Response != nil && errResp.Response.StatusCode == http.StatusNotFound {
+		return notFound
+	}
+	return nil
+}
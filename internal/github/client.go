@@ -10,11 +10,21 @@ type GitHubClient interface {
 	// Returns paginated results with rate limit information
 	GetStarredRepositories(ctx context.Context, username string, opts *StarredOptions) (*StarredResponse, error)
 
+	// GetStarredCount returns the number of repositories a user has starred publicly
+	GetStarredCount(ctx context.Context, username string) (int, error)
+
 	// GetRateLimit returns current rate limit status
 	GetRateLimit(ctx context.Context) (*RateLimitInfo, error)
 
 	// ValidateUser checks if a GitHub username exists
 	ValidateUser(ctx context.Context, username string) error
+
+	// GetMembers returns the logins of the members of an organization, or of one of its
+	// teams when team is set (all pages)
+	GetMembers(ctx context.Context, org, team string) ([]string, error)
+
+	// GetFollowing returns the logins of the accounts a user follows (all pages)
+	GetFollowing(ctx context.Context, username string) ([]string, error)
 }
 
 // UserNotFoundError represents an error when a GitHub user doesn't exist
@@ -26,6 +36,16 @@ func (e *UserNotFoundError) Error() string {
 	return "GitHub user not found: " + e.Username
 }
 
+// OrganizationNotFoundError represents an error when a GitHub organization or team doesn't
+// exist or isn't visible with the current token
+type OrganizationNotFoundError struct {
+	Name string // Organization, or organization/team
+}
+
+func (e *OrganizationNotFoundError) Error() string {
+	return "GitHub organization or team not found: " + e.Name
+}
+
 // RateLimitError represents an error when API rate limit is exceeded
 type RateLimitError struct {
 	ResetTime string
@@ -0,0 +1,234 @@
+package monitor
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/akme/gh-stars-watcher/internal/config"
+	"github.com/akme/gh-stars-watcher/internal/github"
+	"github.com/akme/gh-stars-watcher/internal/storage"
+)
+
+// perUserGitHubClient serves each user their own starred repositories and followed
+// accounts, and each organization or team, keyed as org or org/team, its members. It is
+// only read once set up, so it is safe for concurrent runs.
+type perUserGitHubClient struct {
+	starred   map[string][]storage.Repository
+	members   map[string][]string
+	following map[string][]string
+}
+
+func (c *perUserGitHubClient) GetStarredRepositories(ctx context.Context, username string, opts *github.StarredOptions) (*github.StarredResponse, error) {
+	repos, ok := c.starred[username]
+	if !ok {
+		return nil, fmt.Errorf("unknown user %s", username)
+	}
+	return &github.StarredResponse{Repositories: repos}, nil
+}
+
+func (c *perUserGitHubClient) GetStarredCount(ctx context.Context, username string) (int, error) {
+	return len(c.starred[username]), nil
+}
+
+func (c *perUserGitHubClient) GetRateLimit(ctx context.Context) (*github.RateLimitInfo, error) {
+	return &github.RateLimitInfo{}, nil
+}
+
+func (c *perUserGitHubClient) ValidateUser(ctx context.Context, username string) error {
+	if _, ok := c.starred[username]; !ok {
+		return fmt.Errorf("user %s not found", username)
+	}
+	return nil
+}
+
+func (c *perUserGitHubClient) GetMembers(ctx context.Context, org, team string) ([]string, error) {
+	source := MemberSource{Org: org, Team: team}.String()
+	members, ok := c.members[source]
+	if !ok {
+		return nil, &github.OrganizationNotFoundError{Name: source}
+	}
+	return append([]string(nil), members...), nil
+}
+
+func (c *perUserGitHubClient) GetFollowing(ctx context.Context, username string) ([]string, error) {
+	following, ok := c.following[username]
+	if !ok {
+		return nil, &github.UserNotFoundError{Username: username}
+	}
+	return append([]string(nil), following...), nil
+}
+
+// TestService_MonitorUsers_Concurrent runs many users in parallel on one Service. Run
+// with -race (make test-race) to check that runs share no unsynchronized state.
+func TestService_MonitorUsers_Concurrent(t *testing.T) {
+	const users = 32
+
+	cfg := config.DefaultConfig()
+	cfg.Logging.LogLevel = "error"
+
+	client := &perUserGitHubClient{starred: make(map[string][]storage.Repository)}
+	var usernames []string
+	for i := 0; i < users; i++ {
+		username := fmt.Sprintf("user-%d", i)
+		usernames = append(usernames, username)
+		// Every user starts with a different number of repositories
+		client.starred[username] = starredRepositories(i + 2)[1:]
+	}
+
+	store := storage.NewJSONStore(t.TempDir())
+	service := NewService(client, store, cfg)
+
+	var mu sync.Mutex
+	progressCalls := 0
+	service.SetProgressCallback(func(message string) {
+		mu.Lock()
+		progressCalls++
+		mu.Unlock()
+	})
+
+	ctx := context.Background()
+	results, errs := service.MonitorUsers(ctx, usernames)
+	if len(errs) > 0 || len(results) != users {
+		t.Fatalf("baseline runs: %d results, errors %v; want %d results", len(results), errs, users)
+	}
+	for _, username := range usernames {
+		if !results[username].IsFirstRun {
+			t.Errorf("%s: baseline run is not a first run", username)
+		}
+	}
+
+	// Every user stars their own repository before the second round
+	for i, username := range usernames {
+		repos := starredRepositories(i + 2)
+		repos[0].FullName = username + "/own"
+		repos[0].StarredAt = repos[0].StarredAt.Add(time.Hour)
+		client.starred[username] = repos
+	}
+
+	results, errs = service.MonitorUsers(ctx, usernames)
+	if len(errs) > 0 || len(results) != users {
+		t.Fatalf("second runs: %d results, errors %v; want %d results", len(results), errs, users)
+	}
+	for i, username := range usernames {
+		result := results[username]
+		if result.Username != username || result.TotalRepositories != i+2 {
+			t.Errorf("%s: result of %s with %d repositories, want its own with %d", username, result.Username, result.TotalRepositories, i+2)
+		}
+		if len(result.Changes.NewStars) != 1 || result.Changes.NewStars[0].FullName != username+"/own" {
+			t.Errorf("%s: new stars %v, want only %s/own", username, repositoryNames(result.Changes.NewStars), username)
+		}
+
+		state, err := store.Load(username)
+		if err != nil {
+			t.Fatalf("%s: Load failed: %v", username, err)
+		}
+		if state.CheckCount != 2 || state.TotalCount != i+2 {
+			t.Errorf("%s: stored %d repositories after %d checks, want %d after 2", username, state.TotalCount, state.CheckCount, i+2)
+		}
+	}
+
+	if progressCalls == 0 {
+		t.Error("progress callback was never called")
+	}
+}
+
+// slowGitHubClient records how many users fetch their starred repositories at once
+type slowGitHubClient struct {
+	perUserGitHubClient
+	mu          sync.Mutex
+	inFlight    int
+	maxInFlight int
+}
+
+func (c *slowGitHubClient) GetStarredRepositories(ctx context.Context, username string, opts *github.StarredOptions) (*github.StarredResponse, error) {
+	c.mu.Lock()
+	c.inFlight++
+	if c.inFlight > c.maxInFlight {
+		c.maxInFlight = c.inFlight
+	}
+	c.mu.Unlock()
+
+	time.Sleep(10 * time.Millisecond)
+
+	c.mu.Lock()
+	c.inFlight--
+	c.mu.Unlock()
+	return c.perUserGitHubClient.GetStarredRepositories(ctx, username, opts)
+}
+
+func TestService_MonitorUsers_Concurrency(t *testing.T) {
+	const users = 12
+
+	cfg := config.DefaultConfig()
+	cfg.Logging.LogLevel = "error"
+	cfg.Monitor.Concurrency = 3
+
+	client := &slowGitHubClient{perUserGitHubClient: perUserGitHubClient{starred: make(map[string][]storage.Repository)}}
+	var usernames []string
+	for i := 0; i < users; i++ {
+		username := fmt.Sprintf("user-%d", i)
+		usernames = append(usernames, username)
+		client.starred[username] = starredRepositories(3)
+	}
+
+	service := NewService(client, storage.NewJSONStore(t.TempDir()), cfg)
+	var mu sync.Mutex
+	var progress []string
+	service.SetProgressCallback(func(message string) {
+		mu.Lock()
+		defer mu.Unlock()
+		if strings.HasPrefix(message, "Users:") {
+			progress = append(progress, message)
+		}
+	})
+
+	results, errs := service.MonitorUsers(context.Background(), usernames)
+	if len(errs) > 0 || len(results) != users {
+		t.Fatalf("%d results, errors %v; want %d results", len(results), errs, users)
+	}
+	if client.maxInFlight > 3 {
+		t.Errorf("%d users fetched at once, want at most the concurrency of 3", client.maxInFlight)
+	}
+
+	// Every user is reported starting and finishing
+	if len(progress) != 2*users {
+		t.Fatalf("got %d pool progress messages, want %d: %v", len(progress), 2*users, progress)
+	}
+	if progress[0] != "Users: 11 queued, 1 running, 0 done" {
+		t.Errorf("first progress = %q, want the first user running", progress[0])
+	}
+	if last := progress[len(progress)-1]; last != "Users: 0 queued, 0 running, 12 done" {
+		t.Errorf("last progress = %q, want every user done", last)
+	}
+}
+
+func TestService_MonitorUsers_PerUserFullSyncInterval(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Logging.LogLevel = "error"
+
+	client := &perUserGitHubClient{starred: map[string][]storage.Repository{
+		"Octocat":  starredRepositories(2),
+		"torvalds": starredRepositories(2),
+	}}
+	store := storage.NewJSONStore(t.TempDir())
+	service := NewService(client, store, cfg)
+	service.SetFullSyncIntervals(map[string]int{"octocat": 6})
+
+	if _, errs := service.MonitorUsers(context.Background(), []string{"Octocat", "torvalds"}); len(errs) > 0 {
+		t.Fatalf("MonitorUsers errors: %v", errs)
+	}
+
+	for username, want := range map[string]int{"Octocat": 6, "torvalds": cfg.Incremental.FullSyncInterval} {
+		state, err := store.Load(username)
+		if err != nil {
+			t.Fatalf("%s: Load failed: %v", username, err)
+		}
+		if state.FullSyncInterval != want {
+			t.Errorf("%s: full sync interval %d, want %d", username, state.FullSyncInterval, want)
+		}
+	}
+}
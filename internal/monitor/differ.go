@@ -16,40 +16,38 @@ func NewDiffer() *Differ {
 	return &Differ{}
 }
 
-// CompareRepositories compares two sets of repositories and returns the differences
+// CompareRepositories compares two sets of repositories and returns the differences.
+// Repositories are matched by GitHub ID first, so renames and transfers are reported as such.
 func (d *Differ) CompareRepositories(previous, current []storage.Repository) *ComparisonResult {
-	// Create maps for efficient lookup
-	prevMap := make(map[string]storage.Repository)
-	currMap := make(map[string]storage.Repository)
-
-	for _, repo := range previous {
-		prevMap[repo.FullName] = repo
-	}
-	for _, repo := range current {
-		currMap[repo.FullName] = repo
-	}
+	// Index both sets for efficient lookup
+	prevIndex := newRepositoryIndex(previous)
+	currIndex := newRepositoryIndex(current)
 
 	var added []storage.Repository
 	var removed []storage.Repository
 	var updated []RepositoryUpdate
+	var renamed []RenamedRepository
 
 	// Find added repositories (in current but not in previous)
 	for _, repo := range current {
-		if _, exists := prevMap[repo.FullName]; !exists {
+		if _, exists := prevIndex.find(repo); !exists {
 			added = append(added, repo)
 		}
 	}
 
 	// Find removed repositories (in previous but not in current)
 	for _, repo := range previous {
-		if _, exists := currMap[repo.FullName]; !exists {
+		if _, exists := currIndex.find(repo); !exists {
 			removed = append(removed, repo)
 		}
 	}
 
-	// Find updated repositories (in both but with changes)
+	// Find renamed and updated repositories (in both but with changes)
 	for _, currRepo := range current {
-		if prevRepo, exists := prevMap[currRepo.FullName]; exists {
+		if prevRepo, exists := prevIndex.find(currRepo); exists {
+			if prevRepo.FullName != currRepo.FullName {
+				renamed = append(renamed, RenamedRepository{PreviousFullName: prevRepo.FullName, Repository: currRepo})
+			}
 			if d.hasRepositoryChanged(prevRepo, currRepo) {
 				updated = append(updated, RepositoryUpdate{
 					Previous: prevRepo,
@@ -70,11 +68,15 @@ func (d *Differ) CompareRepositories(previous, current []storage.Repository) *Co
 	sort.Slice(updated, func(i, j int) bool {
 		return updated[i].Current.FullName < updated[j].Current.FullName
 	})
+	sort.Slice(renamed, func(i, j int) bool {
+		return renamed[i].Repository.FullName < renamed[j].Repository.FullName
+	})
 
 	return &ComparisonResult{
 		Added:   added,
 		Removed: removed,
 		Updated: updated,
+		Renamed: renamed,
 	}
 }
 
@@ -133,6 +135,7 @@ type ComparisonResult struct {
 	Added   []storage.Repository `json:"added"`
 	Removed []storage.Repository `json:"removed"`
 	Updated []RepositoryUpdate   `json:"updated"`
+	Renamed []RenamedRepository  `json:"renamed"`
 }
 
 // RepositoryUpdate represents a repository that has been updated
@@ -144,6 +147,10 @@ type RepositoryUpdate struct {
 
 // Summary returns a summary of the comparison results
 func (r *ComparisonResult) Summary() string {
-	return fmt.Sprintf("Added: %d, Removed: %d, Updated: %d",
+	summary := fmt.Sprintf("Added: %d, Removed: %d, Updated: %d",
 		len(r.Added), len(r.Removed), len(r.Updated))
+	if len(r.Renamed) > 0 {
+		summary += fmt.Sprintf(", Renamed: %d", len(r.Renamed))
+	}
+	return summary
 }
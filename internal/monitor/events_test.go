@@ -0,0 +1,72 @@
+package monitor
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/akme/gh-stars-watcher/internal/config"
+	"github.com/akme/gh-stars-watcher/internal/storage"
+)
+
+func TestService_changeEvents(t *testing.T) {
+	service := NewService(nil, nil, config.DefaultConfig())
+	checkTime := time.Date(2026, 3, 16, 12, 0, 0, 0, time.UTC)
+
+	previous := []storage.Repository{
+		{FullName: "kept/repo", StarCount: 10, Language: "Go"},
+		{FullName: "gone/repo"},
+	}
+	current := []storage.Repository{
+		{FullName: "kept/repo", StarCount: 12, Language: "Rust"},
+		{FullName: "new/repo"},
+	}
+	state := &storage.UserState{
+		Username:     "octocat",
+		LastCheck:    checkTime,
+		LastRunID:    newRunID(checkTime),
+		Repositories: current,
+	}
+
+	t.Run("FirstRunRecordsBaseline", func(t *testing.T) {
+		events := service.changeEvents(state, nil, service.findRepositoryChanges(nil, current), true)
+		if len(events) != 2 {
+			t.Fatalf("got %d events, want one baseline event per repository", len(events))
+		}
+		for _, event := range events {
+			if event.Type != storage.EventBaseline || event.RunID != state.LastRunID || !event.Timestamp.Equal(checkTime) {
+				t.Errorf("event = %+v, want baseline event of run %s", event, state.LastRunID)
+			}
+		}
+	})
+
+	t.Run("LaterRunRecordsChanges", func(t *testing.T) {
+		events := service.changeEvents(state, previous, service.findRepositoryChanges(previous, current), false)
+
+		types := map[string]storage.Event{}
+		for _, event := range events {
+			types[event.Type+" "+event.Repository.FullName] = event
+		}
+		for _, want := range []string{"star new/repo", "unstar gone/repo", "update kept/repo"} {
+			if _, ok := types[want]; !ok {
+				t.Errorf("events %v missing %q", types, want)
+			}
+		}
+		if got := types["update kept/repo"].Changes; !reflect.DeepEqual(got, []string{"star_count", "language"}) {
+			t.Errorf("update changes = %v, want [star_count language]", got)
+		}
+	})
+}
+
+func TestNewRunID_IsTimeOrdered(t *testing.T) {
+	first := newRunID(time.Date(2026, 3, 16, 12, 0, 0, 0, time.UTC))
+	second := newRunID(time.Date(2026, 3, 16, 12, 0, 1, 0, time.UTC))
+
+	if !strings.HasPrefix(first, "20260316T120000Z-") {
+		t.Errorf("run ID = %q, want timestamp prefix", first)
+	}
+	if first >= second {
+		t.Errorf("run IDs %q and %q are not ordered by time", first, second)
+	}
+}
@@ -0,0 +1,188 @@
+package monitor
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/akme/gh-stars-watcher/internal/github"
+)
+
+// RateGovernor shares one GitHub rate limit between concurrent runs. Every API call
+// waits for it; once the remaining quota seen in the latest responses drops to the
+// reserve, every call pauses until the rate limit resets.
+type RateGovernor struct {
+	reserve int           // Requests left unused
+	buffer  time.Duration // Extra wait after the reset
+	onPause func(until time.Time)
+	now     func() time.Time
+
+	mu          sync.Mutex
+	known       bool      // Whether remaining and resetTime describe the current window
+	remaining   int       // Requests left, minus those started since the last response
+	resetTime   time.Time // When the current window ends
+	pausedUntil time.Time // End of the pause last reported to onPause
+}
+
+// NewRateGovernor creates a governor keeping reserve requests unused and waiting buffer
+// past each reset
+func NewRateGovernor(reserve int, buffer time.Duration) *RateGovernor {
+	return &RateGovernor{
+		reserve: reserve,
+		buffer:  buffer,
+		now:     time.Now,
+	}
+}
+
+// SetPauseCallback sets a function called once whenever the governor starts pausing
+// calls, with the time they resume
+func (g *RateGovernor) SetPauseCallback(callback func(until time.Time)) {
+	g.onPause = callback
+}
+
+// Wait blocks until an API call may be made and counts it against the remaining quota
+func (g *RateGovernor) Wait(ctx context.Context) error {
+	for {
+		until, paused := g.acquire()
+		if until.IsZero() {
+			return nil
+		}
+		if paused && g.onPause != nil {
+			g.onPause(until)
+		}
+
+		timer := time.NewTimer(until.Sub(g.now()))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// acquire counts a call against the quota, or returns when to try again and whether
+// this starts a new pause
+func (g *RateGovernor) acquire() (time.Time, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	resume := g.resetTime.Add(g.buffer)
+	if !g.known || !g.now().Before(resume) {
+		// The window has reset; the next response tells the new quota
+		g.known = false
+		return time.Time{}, false
+	}
+	if g.remaining > g.reserve {
+		g.remaining--
+		return time.Time{}, false
+	}
+
+	paused := !resume.Equal(g.pausedUntil)
+	g.pausedUntil = resume
+	return resume, paused
+}
+
+// Observe records the rate limit reported by a response. Responses of concurrent calls
+// arrive in any order, so the lowest remaining quota of a window wins.
+func (g *RateGovernor) Observe(info github.RateLimitInfo) {
+	if info.Limit == 0 || info.ResetTime.IsZero() {
+		return
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	switch {
+	case !g.known || info.ResetTime.After(g.resetTime):
+		g.known = true
+		g.remaining = info.Remaining
+		g.resetTime = info.ResetTime
+	case info.ResetTime.Equal(g.resetTime) && info.Remaining < g.remaining:
+		g.remaining = info.Remaining
+	}
+}
+
+// observeError records an exhausted rate limit reported by a failed call
+func (g *RateGovernor) observeError(err error) {
+	var rateLimitErr *github.RateLimitError
+	if !errors.As(err, &rateLimitErr) {
+		return
+	}
+	resetTime, parseErr := time.Parse(time.RFC3339, rateLimitErr.ResetTime)
+	if parseErr != nil {
+		return
+	}
+	g.Observe(github.RateLimitInfo{
+		Limit:     rateLimitErr.Limit,
+		Remaining: 0,
+		ResetTime: resetTime,
+		Used:      rateLimitErr.Used,
+	})
+}
+
+// governedClient makes every call of a GitHubClient wait for a RateGovernor and reports
+// the rate limits it sees back to it
+type governedClient struct {
+	client   github.GitHubClient
+	governor *RateGovernor
+}
+
+func (c *governedClient) GetStarredRepositories(ctx context.Context, username string, opts *github.StarredOptions) (*github.StarredResponse, error) {
+	if err := c.governor.Wait(ctx); err != nil {
+		return nil, err
+	}
+	response, err := c.client.GetStarredRepositories(ctx, username, opts)
+	if err != nil {
+		c.governor.observeError(err)
+		return nil, err
+	}
+	c.governor.Observe(response.RateLimit)
+	return response, nil
+}
+
+func (c *governedClient) GetStarredCount(ctx context.Context, username string) (int, error) {
+	if err := c.governor.Wait(ctx); err != nil {
+		return 0, err
+	}
+	count, err := c.client.GetStarredCount(ctx, username)
+	c.governor.observeError(err)
+	return count, err
+}
+
+// GetRateLimit is not counted against the quota by GitHub and never waits
+func (c *governedClient) GetRateLimit(ctx context.Context) (*github.RateLimitInfo, error) {
+	info, err := c.client.GetRateLimit(ctx)
+	if err == nil {
+		c.governor.Observe(*info)
+	}
+	return info, err
+}
+
+func (c *governedClient) ValidateUser(ctx context.Context, username string) error {
+	if err := c.governor.Wait(ctx); err != nil {
+		return err
+	}
+	err := c.client.ValidateUser(ctx, username)
+	c.governor.observeError(err)
+	return err
+}
+
+func (c *governedClient) GetMembers(ctx context.Context, org, team string) ([]string, error) {
+	if err := c.governor.Wait(ctx); err != nil {
+		return nil, err
+	}
+	members, err := c.client.GetMembers(ctx, org, team)
+	c.governor.observeError(err)
+	return members, err
+}
+
+func (c *governedClient) GetFollowing(ctx context.Context, username string) ([]string, error) {
+	if err := c.governor.Wait(ctx); err != nil {
+		return nil, err
+	}
+	following, err := c.client.GetFollowing(ctx, username)
+	c.governor.observeError(err)
+	return following, err
+}
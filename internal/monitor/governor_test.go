@@ -0,0 +1,147 @@
+package monitor
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/akme/gh-stars-watcher/internal/github"
+)
+
+func TestRateGovernor_PausesAtReserveUntilReset(t *testing.T) {
+	governor := NewRateGovernor(2, 0)
+	var pauses []time.Time
+	governor.SetPauseCallback(func(until time.Time) {
+		pauses = append(pauses, until)
+	})
+	ctx := context.Background()
+
+	// Nothing is known before the first response
+	if err := governor.Wait(ctx); err != nil {
+		t.Fatalf("Wait without a known rate limit = %v", err)
+	}
+
+	reset := time.Now().Add(150 * time.Millisecond)
+	governor.Observe(github.RateLimitInfo{Limit: 5000, Remaining: 4, ResetTime: reset})
+
+	// Two calls fit above the reserve of two
+	for i := 0; i < 2; i++ {
+		start := time.Now()
+		if err := governor.Wait(ctx); err != nil {
+			t.Fatalf("Wait %d = %v", i, err)
+		}
+		if waited := time.Since(start); waited > 50*time.Millisecond {
+			t.Errorf("Wait %d took %s, want no pause above the reserve", i, waited)
+		}
+	}
+
+	// The next call waits for the reset
+	start := time.Now()
+	if err := governor.Wait(ctx); err != nil {
+		t.Fatalf("Wait at the reserve = %v", err)
+	}
+	if time.Now().Before(reset) {
+		t.Errorf("Wait at the reserve returned after %s, before the reset", time.Since(start))
+	}
+	if len(pauses) != 1 || !pauses[0].Equal(reset) {
+		t.Errorf("pauses = %v, want one until %v", pauses, reset)
+	}
+}
+
+func TestRateGovernor_LowestRemainingOfAWindowWins(t *testing.T) {
+	governor := NewRateGovernor(10, 0)
+	reset := time.Now().Add(time.Hour)
+
+	// A response that arrives late must not raise the quota again
+	governor.Observe(github.RateLimitInfo{Limit: 5000, Remaining: 5, ResetTime: reset})
+	governor.Observe(github.RateLimitInfo{Limit: 5000, Remaining: 900, ResetTime: reset})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := governor.Wait(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Wait below the reserve = %v, want to block until the context ends", err)
+	}
+
+	// A new window replaces the old one
+	governor.Observe(github.RateLimitInfo{Limit: 5000, Remaining: 4999, ResetTime: reset.Add(time.Hour)})
+	if err := governor.Wait(context.Background()); err != nil {
+		t.Errorf("Wait in a new window = %v", err)
+	}
+}
+
+func TestGovernedClient_RateLimitErrorPausesOtherCalls(t *testing.T) {
+	governor := NewRateGovernor(0, 0)
+	client := &governedClient{client: rateLimitedClient{}, governor: governor}
+
+	var err error
+	if _, err = client.GetStarredRepositories(context.Background(), "octocat", nil); err == nil {
+		t.Fatal("GetStarredRepositories succeeded, want the rate limit error")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := client.ValidateUser(ctx, "octocat"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("ValidateUser after an exhausted rate limit = %v, want it to wait for the reset", err)
+	}
+}
+
+// rateLimitedClient fails every call with an exhausted rate limit
+type rateLimitedClient struct{}
+
+func (rateLimitedClient) err() error {
+	return &github.RateLimitError{ResetTime: time.Now().Add(time.Hour).Format(time.RFC3339), Limit: 5000, Used: 5000}
+}
+
+func (c rateLimitedClient) GetStarredRepositories(ctx context.Context, username string, opts *github.StarredOptions) (*github.StarredResponse, error) {
+	return nil, c.err()
+}
+
+func (c rateLimitedClient) GetStarredCount(ctx context.Context, username string) (int, error) {
+	return 0, c.err()
+}
+
+func (c rateLimitedClient) GetRateLimit(ctx context.Context) (*github.RateLimitInfo, error) {
+	return nil, c.err()
+}
+
+func (c rateLimitedClient) ValidateUser(ctx context.Context, username string) error {
+	return c.err()
+}
+
+func (c rateLimitedClient) GetMembers(ctx context.Context, org, team string) ([]string, error) {
+	return nil, c.err()
+}
+
+func (c rateLimitedClient) GetFollowing(ctx context.Context, username string) ([]string, error) {
+	return nil, c.err()
+}
+
+func TestRateGovernor_ConcurrentCallsShareTheQuota(t *testing.T) {
+	governor := NewRateGovernor(0, 0)
+	governor.Observe(github.RateLimitInfo{Limit: 5000, Remaining: 10, ResetTime: time.Now().Add(time.Hour)})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	allowed := 0
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if governor.Wait(ctx) == nil {
+				mu.Lock()
+				allowed++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if allowed != 10 {
+		t.Errorf("%d concurrent calls allowed, want the 10 remaining", allowed)
+	}
+}
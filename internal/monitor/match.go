@@ -0,0 +1,94 @@
+package monitor
+
+import (
+	"strings"
+
+	"github.com/akme/gh-stars-watcher/internal/storage"
+)
+
+// RenamedRepository is a starred repository whose full name changed between two runs,
+// because it was renamed or transferred to another owner
+type RenamedRepository struct {
+	PreviousFullName string             `json:"previous_full_name"`
+	Repository       storage.Repository `json:"repository"` // Repository under its new name
+}
+
+// Transferred reports whether the repository moved to another owner rather than only
+// being renamed
+func (r RenamedRepository) Transferred() bool {
+	return repositoryOwner(r.PreviousFullName) != repositoryOwner(r.Repository.FullName)
+}
+
+// repositoryOwner returns the owner part of a full name
+func repositoryOwner(fullName string) string {
+	owner, _, _ := strings.Cut(fullName, "/")
+	return strings.ToLower(owner)
+}
+
+// repositoryIndex finds repositories by their GitHub ID. Repositories stored before IDs
+// were tracked have none and are found by full name until a full sync fills the ID in.
+type repositoryIndex struct {
+	byID   map[int64]storage.Repository
+	byName map[string]storage.Repository
+}
+
+// newRepositoryIndex indexes repos
+func newRepositoryIndex(repos []storage.Repository) *repositoryIndex {
+	index := &repositoryIndex{
+		byID:   make(map[int64]storage.Repository, len(repos)),
+		byName: make(map[string]storage.Repository, len(repos)),
+	}
+	for _, repo := range repos {
+		index.add(repo)
+	}
+	return index
+}
+
+// add indexes repo, replacing the indexed repository that is the same as repo and any
+// other one that had its name
+func (x *repositoryIndex) add(repo storage.Repository) {
+	if previous, ok := x.find(repo); ok {
+		x.remove(previous)
+	}
+	if other, ok := x.byName[repo.FullName]; ok {
+		x.remove(other)
+	}
+	if repo.ID != 0 {
+		x.byID[repo.ID] = repo
+	}
+	x.byName[repo.FullName] = repo
+}
+
+// remove drops repo from the index
+func (x *repositoryIndex) remove(repo storage.Repository) {
+	if repo.ID != 0 {
+		delete(x.byID, repo.ID)
+	}
+	if indexed, ok := x.byName[repo.FullName]; ok && indexed.ID == repo.ID {
+		delete(x.byName, repo.FullName)
+	}
+}
+
+// find returns the indexed repository that is the same as repo: the one with its ID, or
+// the one with its full name when either has no ID. Two repositories with different IDs
+// are never the same, even with the same name, e.g. a repository deleted and recreated.
+func (x *repositoryIndex) find(repo storage.Repository) (storage.Repository, bool) {
+	if repo.ID != 0 {
+		if found, ok := x.byID[repo.ID]; ok {
+			return found, true
+		}
+	}
+	if found, ok := x.byName[repo.FullName]; ok && (found.ID == 0 || repo.ID == 0) {
+		return found, true
+	}
+	return storage.Repository{}, false
+}
+
+// repositories returns the indexed repositories in no particular order
+func (x *repositoryIndex) repositories() []storage.Repository {
+	repos := make([]storage.Repository, 0, len(x.byName))
+	for _, repo := range x.byName {
+		repos = append(repos, repo)
+	}
+	return repos
+}
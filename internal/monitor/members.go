@@ -0,0 +1,310 @@
+package monitor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MembershipFileName is the name of the membership cache inside the state directory
+const MembershipFileName = "members.json"
+
+// MemberSource is a group of GitHub users that expands into the users to monitor: the
+// members of an organization or team, or the accounts a user follows
+type MemberSource struct {
+	Org       string // Organization login
+	Team      string // Team slug within Org; empty for every member of the organization
+	Following string // User whose followed accounts are the members, instead of Org and Team
+}
+
+// ParseTeam parses a team given as org/team
+func ParseTeam(value string) (MemberSource, error) {
+	org, team, ok := strings.Cut(value, "/")
+	if !ok || org == "" || team == "" || strings.Contains(team, "/") {
+		return MemberSource{}, fmt.Errorf("invalid team %q: must be org/team", value)
+	}
+	return MemberSource{Org: org, Team: team}, nil
+}
+
+// String returns the source as org, org/team or following:user
+func (m MemberSource) String() string {
+	if m.Following != "" {
+		return "following:" + m.Following
+	}
+	if m.Team != "" {
+		return m.Org + "/" + m.Team
+	}
+	return m.Org
+}
+
+// Membership is the resolved members of a source and how they changed since they were
+// previously resolved
+type Membership struct {
+	Source     string    `json:"source"`
+	Members    []string  `json:"members"`
+	Added      []string  `json:"added,omitempty"`   // Members that joined since the previous resolution
+	Removed    []string  `json:"removed,omitempty"` // Members that left since the previous resolution
+	ResolvedAt time.Time `json:"resolved_at"`       // When the members were looked up on GitHub
+	Cached     bool      `json:"cached"`            // Whether the members were reused from the cache
+}
+
+// MembershipCache keeps the resolved members of every source in a JSON file, so they are
+// only looked up again once the refresh interval has passed
+type MembershipCache struct {
+	path     string
+	interval time.Duration
+	now      func() time.Time
+	mu       sync.Mutex
+}
+
+// cachedMembers is the persisted form of one resolved source
+type cachedMembers struct {
+	Members    []string  `json:"members"`
+	ResolvedAt time.Time `json:"resolved_at"`
+}
+
+// membershipFile is the persisted form of the cache
+type membershipFile struct {
+	Sources map[string]cachedMembers `json:"sources"`
+
+	// PendingBaselines are the newcomers whose new baseline has not been saved yet
+	PendingBaselines []string `json:"pending_baselines,omitempty"`
+}
+
+// NewMembershipCache creates a cache persisted at path that reuses members for interval
+func NewMembershipCache(path string, interval time.Duration) *MembershipCache {
+	return &MembershipCache{
+		path:     path,
+		interval: interval,
+		now:      time.Now,
+	}
+}
+
+// ResolveMembers returns the members of source. Members in cache are reused until its
+// refresh interval has passed, and also when looking them up fails. The first resolution
+// of a source reports no added members. cache may be nil to always look members up.
+func (s *Service) ResolveMembers(ctx context.Context, source MemberSource, cache *MembershipCache) (*Membership, error) {
+	key := strings.ToLower(source.String())
+
+	var persisted *membershipFile
+	now := time.Now()
+	if cache != nil {
+		cache.mu.Lock()
+		defer cache.mu.Unlock()
+
+		var err error
+		if persisted, err = cache.load(); err != nil {
+			return nil, err
+		}
+		now = cache.now()
+	}
+
+	var previous cachedMembers
+	var known bool
+	if persisted != nil {
+		previous, known = persisted.Sources[key]
+	}
+	if known && now.Sub(previous.ResolvedAt) < cache.interval {
+		return &Membership{Source: source.String(), Members: previous.Members, ResolvedAt: previous.ResolvedAt, Cached: true}, nil
+	}
+
+	s.progress("Resolving members of " + source.String() + "...")
+	var members []string
+	err := s.retryManager.ExecuteWithRetry(ctx, func() error {
+		var err error
+		if source.Following != "" {
+			members, err = s.githubClient.GetFollowing(ctx, source.Following)
+		} else {
+			members, err = s.githubClient.GetMembers(ctx, source.Org, source.Team)
+		}
+		if err != nil && isRateLimitError(err) {
+			return WrapRetryableError(err, true, extractRetryAfter(err))
+		}
+		return err
+	})
+	if err != nil {
+		if known {
+			s.logger.Warn("Failed to resolve members, using cached members", "source", source.String(), "error", err)
+			return &Membership{Source: source.String(), Members: previous.Members, ResolvedAt: previous.ResolvedAt, Cached: true}, nil
+		}
+		return nil, fmt.Errorf("failed to resolve members of %s: %w", source.String(), err)
+	}
+	sortLogins(members)
+
+	membership := &Membership{Source: source.String(), Members: members, ResolvedAt: now}
+	if cache == nil {
+		return membership, nil
+	}
+	if known {
+		membership.Added = loginsNotIn(members, previous.Members)
+		membership.Removed = loginsNotIn(previous.Members, members)
+	}
+
+	persisted.Sources[key] = cachedMembers{Members: members, ResolvedAt: now}
+	if err := cache.save(persisted); err != nil {
+		return nil, err
+	}
+	return membership, nil
+}
+
+// Newcomers returns the members who joined a source since it was previously resolved and
+// were not already monitored through usernames or another source. Their stored state, if
+// any, predates the membership, so their runs should start a new baseline.
+func Newcomers(memberships []*Membership, usernames []string) []string {
+	tracked := make(map[string]bool)
+	for _, username := range usernames {
+		tracked[strings.ToLower(username)] = true
+	}
+	for _, membership := range memberships {
+		for _, member := range loginsNotIn(membership.Members, membership.Added) {
+			tracked[strings.ToLower(member)] = true
+		}
+	}
+
+	var newcomers []string
+	for _, membership := range memberships {
+		for _, member := range membership.Added {
+			if !tracked[strings.ToLower(member)] {
+				tracked[strings.ToLower(member)] = true
+				newcomers = append(newcomers, member)
+			}
+		}
+	}
+	return newcomers
+}
+
+// PendingBaselines records newcomers as waiting for a new baseline and returns the users
+// of usernames that are waiting, including newcomers of earlier runs whose baseline was
+// never saved. Users keep waiting until BaselinesSaved is called for them. Without a
+// cache only newcomers are returned.
+func (c *MembershipCache) PendingBaselines(newcomers, usernames []string) ([]string, error) {
+	if c == nil {
+		return newcomers, nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	persisted, err := c.load()
+	if err != nil {
+		return nil, err
+	}
+
+	if added := loginsNotIn(newcomers, persisted.PendingBaselines); len(added) > 0 {
+		persisted.PendingBaselines = append(persisted.PendingBaselines, added...)
+		sortLogins(persisted.PendingBaselines)
+		if err := c.save(persisted); err != nil {
+			return nil, err
+		}
+	}
+
+	return loginsIn(usernames, persisted.PendingBaselines), nil
+}
+
+// BaselinesSaved stops usernames from waiting for a new baseline once their runs have
+// saved one
+func (c *MembershipCache) BaselinesSaved(usernames []string) error {
+	if c == nil {
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	persisted, err := c.load()
+	if err != nil {
+		return err
+	}
+
+	remaining := loginsNotIn(persisted.PendingBaselines, usernames)
+	if len(remaining) == len(persisted.PendingBaselines) {
+		return nil
+	}
+	persisted.PendingBaselines = remaining
+	return c.save(persisted)
+}
+
+// load reads the cache file; a missing file yields an empty cache
+func (c *MembershipCache) load() (*membershipFile, error) {
+	persisted := &membershipFile{}
+
+	data, err := os.ReadFile(c.path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read membership cache: %v", err)
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, persisted); err != nil {
+			return nil, fmt.Errorf("failed to parse membership cache %s: %v", c.path, err)
+		}
+	}
+
+	if persisted.Sources == nil {
+		persisted.Sources = make(map[string]cachedMembers)
+	}
+	return persisted, nil
+}
+
+// save writes the cache file atomically
+func (c *MembershipCache) save(persisted *membershipFile) error {
+	data, err := json.MarshalIndent(persisted, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode membership cache: %v", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(c.path), 0o755); err != nil {
+		return fmt.Errorf("failed to create membership cache directory: %v", err)
+	}
+
+	tempFile := c.path + ".tmp"
+	if err := os.WriteFile(tempFile, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write membership cache: %v", err)
+	}
+	if err := os.Rename(tempFile, c.path); err != nil {
+		os.Remove(tempFile)
+		return fmt.Errorf("failed to rename membership cache file: %v", err)
+	}
+	return nil
+}
+
+// sortLogins orders logins alphabetically, ignoring case
+func sortLogins(logins []string) {
+	sort.Slice(logins, func(i, j int) bool {
+		return strings.ToLower(logins[i]) < strings.ToLower(logins[j])
+	})
+}
+
+// loginsNotIn returns the logins of a that are not in b, ignoring case
+func loginsNotIn(a, b []string) []string {
+	in := make(map[string]bool, len(b))
+	for _, login := range b {
+		in[strings.ToLower(login)] = true
+	}
+	var missing []string
+	for _, login := range a {
+		if !in[strings.ToLower(login)] {
+			missing = append(missing, login)
+		}
+	}
+	return missing
+}
+
+// loginsIn returns the logins of a that are also in b, ignoring case
+func loginsIn(a, b []string) []string {
+	in := make(map[string]bool, len(b))
+	for _, login := range b {
+		in[strings.ToLower(login)] = true
+	}
+	var found []string
+	for _, login := range a {
+		if in[strings.ToLower(login)] {
+			found = append(found, login)
+		}
+	}
+	return found
+}
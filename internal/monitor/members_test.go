@@ -0,0 +1,246 @@
+package monitor
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/akme/gh-stars-watcher/internal/config"
+	"github.com/akme/gh-stars-watcher/internal/github"
+	"github.com/akme/gh-stars-watcher/internal/storage"
+)
+
+func TestService_ResolveMembers_CachesAndReportsChanges(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Logging.LogLevel = "error"
+
+	client := &perUserGitHubClient{members: map[string][]string{
+		"acme":          {"carol", "Alice", "bob"},
+		"acme/platform": {"bob"},
+	}}
+	service := NewService(client, nil, cfg)
+	ctx := context.Background()
+
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	cache := NewMembershipCache(filepath.Join(t.TempDir(), "members.json"), time.Hour)
+	cache.now = func() time.Time { return now }
+
+	// The first resolution is the baseline
+	membership, err := service.ResolveMembers(ctx, MemberSource{Org: "acme"}, cache)
+	if err != nil {
+		t.Fatalf("ResolveMembers failed: %v", err)
+	}
+	if !equalStrings(membership.Members, []string{"Alice", "bob", "carol"}) || membership.Cached {
+		t.Errorf("first resolution = %+v, want Alice, bob and carol from GitHub", membership)
+	}
+	if len(membership.Added) != 0 || len(membership.Removed) != 0 {
+		t.Errorf("first resolution reports added %v and removed %v, want none", membership.Added, membership.Removed)
+	}
+
+	// Within the refresh interval the cache answers, even after the organization changed
+	client.members["acme"] = []string{"Alice", "carol", "dave"}
+	now = now.Add(30 * time.Minute)
+	membership, err = service.ResolveMembers(ctx, MemberSource{Org: "ACME"}, cache)
+	if err != nil {
+		t.Fatalf("ResolveMembers failed: %v", err)
+	}
+	if !membership.Cached || !equalStrings(membership.Members, []string{"Alice", "bob", "carol"}) {
+		t.Errorf("resolution within the interval = %+v, want the cached members", membership)
+	}
+
+	// Once the interval has passed the members are looked up again
+	now = now.Add(time.Hour)
+	membership, err = service.ResolveMembers(ctx, MemberSource{Org: "acme"}, cache)
+	if err != nil {
+		t.Fatalf("ResolveMembers failed: %v", err)
+	}
+	if membership.Cached || !equalStrings(membership.Added, []string{"dave"}) || !equalStrings(membership.Removed, []string{"bob"}) {
+		t.Errorf("resolution after the interval = %+v, want dave added and bob removed", membership)
+	}
+
+	// Teams are cached separately
+	membership, err = service.ResolveMembers(ctx, MemberSource{Org: "acme", Team: "platform"}, cache)
+	if err != nil || !equalStrings(membership.Members, []string{"bob"}) {
+		t.Errorf("team resolution = %+v, %v; want bob", membership, err)
+	}
+
+	// A failed lookup falls back to the cache
+	delete(client.members, "acme")
+	now = now.Add(2 * time.Hour)
+	membership, err = service.ResolveMembers(ctx, MemberSource{Org: "acme"}, cache)
+	if err != nil || !membership.Cached || !equalStrings(membership.Members, []string{"Alice", "carol", "dave"}) {
+		t.Errorf("failed resolution = %+v, %v; want the cached members", membership, err)
+	}
+
+	// Without cached members the failure is returned
+	var notFound *github.OrganizationNotFoundError
+	if _, err := service.ResolveMembers(ctx, MemberSource{Org: "other"}, cache); !errors.As(err, &notFound) {
+		t.Errorf("resolution of an unknown organization = %v, want OrganizationNotFoundError", err)
+	}
+}
+
+func TestService_ResolveMembers_FollowingNewcomersStartSilently(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Logging.LogLevel = "error"
+
+	client := &perUserGitHubClient{
+		starred: map[string][]storage.Repository{
+			"alice": starredRepositories(2),
+			"bob":   starredRepositories(2),
+		},
+		following: map[string][]string{"me": {"alice", "bob"}},
+	}
+	service := NewService(client, storage.NewJSONStore(t.TempDir()), cfg)
+	ctx := context.Background()
+
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	cache := NewMembershipCache(filepath.Join(t.TempDir(), MembershipFileName), 0)
+	cache.now = func() time.Time { return now }
+	following := MemberSource{Following: "me"}
+
+	// Baseline of everyone followed
+	membership, err := service.ResolveMembers(ctx, following, cache)
+	if err != nil {
+		t.Fatalf("ResolveMembers failed: %v", err)
+	}
+	if membership.Source != "following:me" || !equalStrings(membership.Members, []string{"alice", "bob"}) {
+		t.Fatalf("membership = %+v, want alice and bob followed by me", membership)
+	}
+	if _, errs := service.MonitorUsers(ctx, membership.Members); len(errs) > 0 {
+		t.Fatalf("MonitorUsers errors: %v", errs)
+	}
+
+	// alice is unfollowed and stars more while she is not tracked
+	client.following["me"] = []string{"bob"}
+	client.starred["alice"] = starredRepositories(5)
+	now = now.Add(time.Hour)
+	if membership, err = service.ResolveMembers(ctx, following, cache); err != nil || !equalStrings(membership.Removed, []string{"alice"}) {
+		t.Fatalf("membership after unfollowing = %+v, %v; want alice removed", membership, err)
+	}
+
+	// Followed again, her stale state must not turn into a burst of changes
+	client.following["me"] = []string{"alice", "bob"}
+	now = now.Add(time.Hour)
+	membership, err = service.ResolveMembers(ctx, following, cache)
+	if err != nil || !equalStrings(membership.Added, []string{"alice"}) {
+		t.Fatalf("membership after following again = %+v, %v; want alice added", membership, err)
+	}
+	service.SetFreshBaselines(Newcomers([]*Membership{membership}, nil))
+
+	results, errs := service.MonitorUsers(ctx, membership.Members)
+	if len(errs) > 0 {
+		t.Fatalf("MonitorUsers errors: %v", errs)
+	}
+	if alice := results["alice"]; !alice.IsFirstRun || alice.TotalRepositories != 5 {
+		t.Errorf("alice = first run %v with %d repositories, want a new baseline of 5", alice.IsFirstRun, alice.TotalRepositories)
+	}
+	if results["bob"].IsFirstRun {
+		t.Error("bob, followed all along, was given a new baseline")
+	}
+}
+
+func TestMembershipCache_PendingBaselinesSurviveFailedRuns(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Logging.LogLevel = "error"
+
+	client := &perUserGitHubClient{
+		starred: map[string][]storage.Repository{
+			"alice": starredRepositories(2),
+			"bob":   starredRepositories(2),
+		},
+		following: map[string][]string{"me": {"alice", "bob"}},
+	}
+	store := storage.NewJSONStore(t.TempDir())
+	ctx := context.Background()
+
+	cache := NewMembershipCache(filepath.Join(t.TempDir(), MembershipFileName), 0)
+	following := MemberSource{Following: "me"}
+
+	// run resolves the followed accounts and monitors them like one invocation of monitor
+	run := func() (map[string]*MonitorResult, map[string]error) {
+		t.Helper()
+		service := NewService(client, store, cfg)
+		membership, err := service.ResolveMembers(ctx, following, cache)
+		if err != nil {
+			t.Fatalf("ResolveMembers failed: %v", err)
+		}
+		pending, err := cache.PendingBaselines(Newcomers([]*Membership{membership}, nil), membership.Members)
+		if err != nil {
+			t.Fatalf("PendingBaselines failed: %v", err)
+		}
+		service.SetFreshBaselines(pending)
+
+		results, errs := service.MonitorUsers(ctx, membership.Members)
+		var baselined []string
+		for _, username := range pending {
+			if _, ok := results[username]; ok {
+				baselined = append(baselined, username)
+			}
+		}
+		if err := cache.BaselinesSaved(baselined); err != nil {
+			t.Fatalf("BaselinesSaved failed: %v", err)
+		}
+		return results, errs
+	}
+
+	if _, errs := run(); len(errs) > 0 {
+		t.Fatalf("baseline run errors: %v", errs)
+	}
+
+	// alice is unfollowed and stars more while she is not tracked
+	client.following["me"] = []string{"bob"}
+	run()
+	client.starred["alice"] = starredRepositories(5)
+
+	// Followed again, her first run fails
+	client.following["me"] = []string{"alice", "bob"}
+	aliceStars := client.starred["alice"]
+	delete(client.starred, "alice")
+	if _, errs := run(); errs["alice"] == nil {
+		t.Fatal("alice's run succeeded, want it to fail")
+	}
+
+	// The next run no longer sees her as added, but still records a new baseline
+	client.starred["alice"] = aliceStars
+	results, errs := run()
+	if len(errs) > 0 {
+		t.Fatalf("MonitorUsers errors: %v", errs)
+	}
+	if alice := results["alice"]; !alice.IsFirstRun || alice.TotalRepositories != 5 {
+		t.Errorf("alice = first run %v with %d repositories, want a new baseline of 5", alice.IsFirstRun, alice.TotalRepositories)
+	}
+
+	// Once saved, the baseline is not recorded again
+	if pending, err := cache.PendingBaselines(nil, []string{"alice", "bob"}); err != nil || len(pending) != 0 {
+		t.Errorf("PendingBaselines after the baseline = %v, %v; want none", pending, err)
+	}
+}
+
+func TestNewcomers(t *testing.T) {
+	memberships := []*Membership{
+		{Source: "acme", Members: []string{"alice", "Bob", "carol"}, Added: []string{"Bob", "carol"}},
+		{Source: "acme/platform", Members: []string{"bob", "dave"}, Added: []string{"dave"}},
+		{Source: "following:me", Members: []string{"carol", "erin"}, Added: []string{"carol", "erin"}},
+	}
+
+	// bob was already tracked through the team, erin is given explicitly
+	got := Newcomers(memberships, []string{"Erin"})
+	if want := []string{"carol", "dave"}; !equalStrings(got, want) {
+		t.Errorf("Newcomers = %v, want %v", got, want)
+	}
+}
+
+func TestParseTeam(t *testing.T) {
+	source, err := ParseTeam("acme/platform")
+	if err != nil || source != (MemberSource{Org: "acme", Team: "platform"}) || source.String() != "acme/platform" {
+		t.Errorf("ParseTeam(acme/platform) = %+v, %v", source, err)
+	}
+
+	for _, value := range []string{"acme", "acme/", "/platform", "acme/platform/extra"} {
+		if _, err := ParseTeam(value); err == nil {
+			t.Errorf("ParseTeam(%q) succeeded, want an error", value)
+		}
+	}
+}
@@ -0,0 +1,195 @@
+package monitor
+
+import (
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/akme/gh-stars-watcher/internal/config"
+	"github.com/akme/gh-stars-watcher/internal/storage"
+)
+
+func TestService_findRepositoryChanges_Renames(t *testing.T) {
+	service := NewService(nil, nil, config.DefaultConfig())
+	starredAt := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	repo := func(id int64, fullName string) storage.Repository {
+		return storage.Repository{ID: id, FullName: fullName, StarredAt: starredAt, StarCount: 10}
+	}
+
+	tests := []struct {
+		name         string
+		previous     []storage.Repository
+		current      []storage.Repository
+		wantNewStars []string
+		wantUnstars  []string
+		wantRenamed  []string // previous -> current, marked when transferred to another owner
+	}{
+		{
+			name:         "RenameDetectedByID",
+			previous:     []storage.Repository{repo(1, "octo/old-name")},
+			current:      []storage.Repository{repo(1, "octo/new-name")},
+			wantRenamed:  []string{"octo/old-name -> octo/new-name"},
+			wantNewStars: []string{},
+			wantUnstars:  []string{},
+		},
+		{
+			name:         "TransferDetectedByID",
+			previous:     []storage.Repository{repo(1, "octo/tool")},
+			current:      []storage.Repository{repo(1, "tool-org/tool")},
+			wantRenamed:  []string{"octo/tool -> tool-org/tool (transferred)"},
+			wantNewStars: []string{},
+			wantUnstars:  []string{},
+		},
+		{
+			name:         "RepositoryWithoutIDMatchedByName",
+			previous:     []storage.Repository{repo(0, "octo/repo")},
+			current:      []storage.Repository{repo(1, "octo/repo")},
+			wantRenamed:  []string{},
+			wantNewStars: []string{},
+			wantUnstars:  []string{},
+		},
+		{
+			name:         "RenameWithoutIDIsUnstarAndStar",
+			previous:     []storage.Repository{repo(0, "octo/old-name")},
+			current:      []storage.Repository{repo(1, "octo/new-name")},
+			wantRenamed:  []string{},
+			wantNewStars: []string{"octo/new-name"},
+			wantUnstars:  []string{"octo/old-name"},
+		},
+		{
+			name:         "RecreatedRepositoryIsUnstarAndStar",
+			previous:     []storage.Repository{repo(1, "octo/repo")},
+			current:      []storage.Repository{repo(2, "octo/repo")},
+			wantRenamed:  []string{},
+			wantNewStars: []string{"octo/repo"},
+			wantUnstars:  []string{"octo/repo"},
+		},
+		{
+			name:         "NameTakenOverByAnotherRepository",
+			previous:     []storage.Repository{repo(1, "octo/a"), repo(2, "octo/b")},
+			current:      []storage.Repository{repo(1, "octo/b"), repo(2, "octo/c")},
+			wantRenamed:  []string{"octo/a -> octo/b", "octo/b -> octo/c"},
+			wantNewStars: []string{},
+			wantUnstars:  []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			changes := service.findRepositoryChanges(tt.previous, tt.current)
+
+			if got := repositoryNames(changes.NewStars); !equalStrings(got, tt.wantNewStars) {
+				t.Errorf("NewStars = %v, want %v", got, tt.wantNewStars)
+			}
+			if got := repositoryNames(changes.Unstars); !equalStrings(got, tt.wantUnstars) {
+				t.Errorf("Unstars = %v, want %v", got, tt.wantUnstars)
+			}
+
+			renamed := []string{}
+			for _, r := range changes.Renamed {
+				rename := r.PreviousFullName + " -> " + r.Repository.FullName
+				if r.Transferred() {
+					rename += " (transferred)"
+				}
+				renamed = append(renamed, rename)
+			}
+			if !equalStrings(renamed, tt.wantRenamed) {
+				t.Errorf("Renamed = %v, want %v", renamed, tt.wantRenamed)
+			}
+
+			want := len(tt.wantNewStars) + len(tt.wantUnstars) + len(tt.wantRenamed)
+			if changes.TotalChanges != want {
+				t.Errorf("TotalChanges = %d, want %d", changes.TotalChanges, want)
+			}
+		})
+	}
+}
+
+func TestService_mergeRepositories_ByID(t *testing.T) {
+	service := NewService(nil, nil, config.DefaultConfig())
+	starredAt := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	existing := []storage.Repository{
+		{FullName: "octo/legacy", StarredAt: starredAt},
+		{ID: 2, FullName: "octo/old-name", StarredAt: starredAt},
+	}
+	fetched := []storage.Repository{
+		{ID: 1, FullName: "octo/legacy", StarredAt: starredAt},
+		{ID: 2, FullName: "octo/new-name", StarredAt: starredAt},
+	}
+
+	merged := service.mergeRepositories(existing, fetched)
+	if len(merged) != 2 {
+		t.Fatalf("merged %d repositories, want 2: %+v", len(merged), merged)
+	}
+	byName := map[string]storage.Repository{}
+	for _, repo := range merged {
+		byName[repo.FullName] = repo
+	}
+	if byName["octo/legacy"].ID != 1 {
+		t.Errorf("octo/legacy has ID %d, want it filled in as 1", byName["octo/legacy"].ID)
+	}
+	if _, ok := byName["octo/new-name"]; !ok {
+		t.Errorf("merged %v, want octo/old-name replaced by octo/new-name", repositoryNames(merged))
+	}
+}
+
+func TestService_changeEvents_Rename(t *testing.T) {
+	service := NewService(nil, nil, config.DefaultConfig())
+	checkTime := time.Date(2026, 3, 16, 12, 0, 0, 0, time.UTC)
+
+	previous := []storage.Repository{{ID: 7, FullName: "octo/tool", StarCount: 10}}
+	current := []storage.Repository{{ID: 7, FullName: "tool-org/tool", StarCount: 12}}
+	state := &storage.UserState{
+		Username:     "octocat",
+		LastCheck:    checkTime,
+		LastRunID:    newRunID(checkTime),
+		Repositories: current,
+	}
+
+	events := service.changeEvents(state, previous, service.findRepositoryChanges(previous, current), false)
+
+	types := map[string]storage.Event{}
+	for _, event := range events {
+		types[event.Type+" "+event.Repository.FullName] = event
+	}
+	rename, ok := types["rename tool-org/tool"]
+	if !ok {
+		t.Fatalf("events %v missing the rename of tool-org/tool", types)
+	}
+	if rename.PreviousFullName != "octo/tool" {
+		t.Errorf("rename PreviousFullName = %q, want octo/tool", rename.PreviousFullName)
+	}
+	if update, ok := types["update tool-org/tool"]; !ok || len(update.Changes) != 1 || update.Changes[0] != "star_count" {
+		t.Errorf("update event = %+v, want the star_count change of the renamed repository", update)
+	}
+	if len(events) != 2 {
+		t.Errorf("got %d events, want a rename and an update: %v", len(events), types)
+	}
+}
+
+// repositoryNames returns the sorted full names of repos
+func repositoryNames(repos []storage.Repository) []string {
+	names := make([]string, 0, len(repos))
+	for _, repo := range repos {
+		names = append(names, repo.FullName)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// equalStrings reports whether a and b hold the same strings, sorting both
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	sort.Strings(a)
+	sort.Strings(b)
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
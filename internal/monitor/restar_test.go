@@ -10,7 +10,7 @@ import (
 
 func TestService_findRepositoryChanges_ReStarDetection(t *testing.T) {
 	cfg := config.DefaultConfig()
-	service := NewService(nil, nil, nil, cfg)
+	service := NewService(nil, nil, cfg)
 
 	baseTime := time.Date(2025, 9, 29, 21, 12, 41, 0, time.UTC)
 
@@ -0,0 +1,74 @@
+package monitor
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/akme/gh-stars-watcher/internal/storage"
+)
+
+// SuspiciousResultError is returned instead of saving a run whose starred repositories
+// dropped further than safety.max_drop_percent allows. Such a drop usually means GitHub
+// returned a truncated list, not that the user unstarred most of their repositories.
+type SuspiciousResultError struct {
+	Username       string
+	Previous       int // Starred repositories in the stored state
+	Fetched        int // Starred repositories fetched by this run
+	StarredCount   int // Starred count reported by GitHub, or -1 when it could not be fetched
+	MaxDropPercent int
+}
+
+func (e *SuspiciousResultError) Error() string {
+	reported := "GitHub's starred count could not be checked"
+	if e.StarredCount >= 0 {
+		reported = fmt.Sprintf("GitHub reports %d", e.StarredCount)
+	}
+	return fmt.Sprintf("suspicious result for %s: fetched %d starred repositories, down from %d by more than %d%% (%s); "+
+		"the state was not saved, run again later or pass --accept-large-changes if the repositories were really unstarred",
+		e.Username, e.Fetched, e.Previous, e.MaxDropPercent, reported)
+}
+
+// SetAcceptLargeChanges saves runs even when their starred repositories dropped further
+// than safety.max_drop_percent allows
+func (s *Service) SetAcceptLargeChanges(accept bool) {
+	s.acceptLargeChanges = accept
+}
+
+// checkLargeDrop returns a *SuspiciousResultError when the fetched repositories dropped
+// too far below the previous state and the starred count reported by GitHub does not
+// confirm the drop
+func (s *Service) checkLargeDrop(ctx context.Context, username string, previous *storage.UserState, fetched int) error {
+	safety := s.config.Safety
+	if safety.MaxDropPercent == 0 || previous.CheckCount == 0 {
+		return nil
+	}
+
+	drop := previous.TotalCount - fetched
+	if drop <= 0 || drop < safety.MinDrop || drop*100 <= previous.TotalCount*safety.MaxDropPercent {
+		return nil
+	}
+
+	if s.acceptLargeChanges {
+		s.logInfo("Accepting large drop in starred repositories", "username", username, "previous", previous.TotalCount, "fetched", fetched)
+		return nil
+	}
+
+	// A real mass unstar shows up in GitHub's own count; a truncated fetch does not
+	s.progress("Verifying starred count...")
+	starredCount, err := s.githubClient.GetStarredCount(ctx, username)
+	if err != nil {
+		s.logError("Failed to fetch starred count", "username", username, "error", err)
+		starredCount = -1
+	} else if fetched >= starredCount-safety.MinDrop {
+		s.logInfo("Large drop in starred repositories confirmed by starred count", "username", username, "previous", previous.TotalCount, "fetched", fetched, "starred_count", starredCount)
+		return nil
+	}
+
+	return &SuspiciousResultError{
+		Username:       username,
+		Previous:       previous.TotalCount,
+		Fetched:        fetched,
+		StarredCount:   starredCount,
+		MaxDropPercent: safety.MaxDropPercent,
+	}
+}
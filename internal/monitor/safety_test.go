@@ -0,0 +1,129 @@
+package monitor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/akme/gh-stars-watcher/internal/config"
+	"github.com/akme/gh-stars-watcher/internal/github"
+	"github.com/akme/gh-stars-watcher/internal/storage"
+)
+
+// fakeGitHubClient serves a fixed list of starred repositories
+type fakeGitHubClient struct {
+	repositories []storage.Repository
+	starredCount int
+	countErr     error
+	countCalls   int
+}
+
+func (c *fakeGitHubClient) GetStarredRepositories(ctx context.Context, username string, opts *github.StarredOptions) (*github.StarredResponse, error) {
+	return &github.StarredResponse{Repositories: c.repositories}, nil
+}
+
+func (c *fakeGitHubClient) GetStarredCount(ctx context.Context, username string) (int, error) {
+	c.countCalls++
+	return c.starredCount, c.countErr
+}
+
+func (c *fakeGitHubClient) GetRateLimit(ctx context.Context) (*github.RateLimitInfo, error) {
+	return &github.RateLimitInfo{}, nil
+}
+
+func (c *fakeGitHubClient) ValidateUser(ctx context.Context, username string) error {
+	return nil
+}
+
+func (c *fakeGitHubClient) GetMembers(ctx context.Context, org, team string) ([]string, error) {
+	return nil, nil
+}
+
+func (c *fakeGitHubClient) GetFollowing(ctx context.Context, username string) ([]string, error) {
+	return nil, nil
+}
+
+// starredRepositories returns n valid starred repositories
+func starredRepositories(n int) []storage.Repository {
+	repos := make([]storage.Repository, n)
+	starredAt := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	for i := range repos {
+		name := fmt.Sprintf("owner/repo-%d", i)
+		repos[i] = storage.Repository{FullName: name, URL: "https://github.com/" + name, StarredAt: starredAt.Add(-time.Duration(i) * time.Minute)}
+	}
+	return repos
+}
+
+func TestService_MonitorUser_LargeDropGuard(t *testing.T) {
+	tests := []struct {
+		name         string
+		fetched      int
+		starredCount int
+		countErr     error
+		accept       bool
+		wantErr      bool
+		wantCount    bool // Whether GitHub's starred count is consulted
+	}{
+		{name: "SmallDropIsSaved", fetched: 95},
+		{name: "TruncatedFetchIsRejected", fetched: 0, starredCount: 100, wantErr: true, wantCount: true},
+		{name: "UnknownCountIsRejected", fetched: 10, countErr: errors.New("rate limited"), wantErr: true, wantCount: true},
+		{name: "ConfirmedDropIsSaved", fetched: 10, starredCount: 12, wantCount: true},
+		{name: "AcceptedDropIsSaved", fetched: 0, starredCount: 100, accept: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.DefaultConfig()
+			cfg.Incremental.Enabled = false
+			cfg.Logging.LogLevel = "error"
+			store := storage.NewJSONStore(t.TempDir())
+			client := &fakeGitHubClient{repositories: starredRepositories(100)}
+			service := NewService(client, store, cfg)
+
+			ctx := context.Background()
+			if _, err := service.MonitorUser(ctx, "octocat"); err != nil {
+				t.Fatalf("baseline run failed: %v", err)
+			}
+
+			client.repositories = client.repositories[:tt.fetched]
+			client.starredCount, client.countErr = tt.starredCount, tt.countErr
+			service.SetAcceptLargeChanges(tt.accept)
+			result, err := service.MonitorUser(ctx, "octocat")
+
+			if (client.countCalls > 0) != tt.wantCount {
+				t.Errorf("starred count calls = %d, want consulted %v", client.countCalls, tt.wantCount)
+			}
+
+			state, loadErr := store.Load("octocat")
+			if loadErr != nil {
+				t.Fatalf("Load failed: %v", loadErr)
+			}
+
+			if tt.wantErr {
+				var suspicious *SuspiciousResultError
+				if !errors.As(err, &suspicious) {
+					t.Fatalf("MonitorUser = %v, want *SuspiciousResultError", err)
+				}
+				if suspicious.Previous != 100 || suspicious.Fetched != tt.fetched {
+					t.Errorf("error = %+v, want drop from 100 to %d", suspicious, tt.fetched)
+				}
+				if state.TotalCount != 100 || state.CheckCount != 1 {
+					t.Errorf("stored state has %d repositories after %d checks, want the baseline kept", state.TotalCount, state.CheckCount)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("MonitorUser failed: %v", err)
+			}
+			if len(result.Changes.Unstars) != 100-tt.fetched {
+				t.Errorf("unstars = %d, want %d", len(result.Changes.Unstars), 100-tt.fetched)
+			}
+			if state.TotalCount != tt.fetched {
+				t.Errorf("stored state has %d repositories, want %d", state.TotalCount, tt.fetched)
+			}
+		})
+	}
+}
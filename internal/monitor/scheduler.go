@@ -0,0 +1,305 @@
+package monitor
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/akme/gh-stars-watcher/internal/config"
+	"github.com/robfig/cron/v3"
+)
+
+// ScheduledUser is the schedule and run bookkeeping of one watched user
+type ScheduledUser struct {
+	Username string    `json:"username"`
+	Spec     string    `json:"spec"` // Cron expression or descriptor the next run was computed from
+	Priority int       `json:"priority"`
+	NextRun  time.Time `json:"next_run"`
+	LastRun  time.Time `json:"last_run,omitempty"`
+}
+
+// scheduleFile is the persisted form of the scheduler state
+type scheduleFile struct {
+	UpdatedAt time.Time                `json:"updated_at"`
+	Users     map[string]ScheduledUser `json:"users"`
+}
+
+// scheduleEntry pairs a user's bookkeeping with its parsed schedule
+type scheduleEntry struct {
+	ScheduledUser
+	schedule cron.Schedule
+}
+
+// Scheduler decides when each watched user is due. Next-run times are persisted
+// so that a restart continues the existing schedule instead of checking everyone at once.
+type Scheduler struct {
+	mu      sync.Mutex
+	entries map[string]*scheduleEntry // Keyed by lowercase username
+	jitter  time.Duration
+	path    string // Persisted schedule file, empty to disable persistence
+}
+
+// NewScheduler builds a schedule for the given users from the watch configuration and
+// restores next-run times from path. Users without their own schedule use watch.schedule,
+// or run every watch.interval when that is empty.
+func NewScheduler(usernames []string, cfg config.WatchConfig, path string) (*Scheduler, error) {
+	defaultSpec := cfg.Schedule
+	if defaultSpec == "" {
+		defaultSpec = "@every " + cfg.Interval.String()
+	}
+
+	userSchedules := make(map[string]config.UserSchedule)
+	for _, schedule := range cfg.Schedules {
+		userSchedules[strings.ToLower(schedule.User)] = schedule
+	}
+
+	persisted, err := loadScheduleFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	s := &Scheduler{
+		entries: make(map[string]*scheduleEntry, len(usernames)),
+		jitter:  cfg.Jitter,
+		path:    path,
+	}
+
+	for _, username := range usernames {
+		spec, priority := defaultSpec, 0
+		if userSchedule, ok := userSchedules[strings.ToLower(username)]; ok {
+			if userSchedule.Cron != "" {
+				spec = userSchedule.Cron
+			}
+			priority = userSchedule.Priority
+		}
+
+		schedule, err := config.ParseSchedule(spec)
+		if err != nil {
+			return nil, fmt.Errorf("invalid schedule %q for %s: %w", spec, username, err)
+		}
+
+		entry := &scheduleEntry{
+			ScheduledUser: ScheduledUser{Username: username, Spec: spec, Priority: priority},
+			schedule:      schedule,
+		}
+
+		previous, known := persisted.Users[strings.ToLower(username)]
+		switch {
+		case !known:
+			// New user - check right away to establish the baseline
+			entry.NextRun = now
+		case previous.Spec != spec:
+			// Schedule changed - recompute from the last run
+			entry.LastRun = previous.LastRun
+			entry.NextRun = s.next(entry, maxTime(previous.LastRun, now))
+		case previous.NextRun.Before(now):
+			// Overdue after downtime - spread the catch-up runs over the jitter window
+			entry.LastRun = previous.LastRun
+			entry.NextRun = now.Add(s.randomJitter())
+		default:
+			entry.LastRun = previous.LastRun
+			entry.NextRun = previous.NextRun
+		}
+
+		s.entries[strings.ToLower(username)] = entry
+	}
+
+	return s, nil
+}
+
+// Due returns the users whose next run is at or before now, highest priority first
+func (s *Scheduler) Due(now time.Time) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var due []*scheduleEntry
+	for _, entry := range s.entries {
+		if !entry.NextRun.After(now) {
+			due = append(due, entry)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool {
+		if due[i].Priority != due[j].Priority {
+			return due[i].Priority > due[j].Priority
+		}
+		if !due[i].NextRun.Equal(due[j].NextRun) {
+			return due[i].NextRun.Before(due[j].NextRun)
+		}
+		return due[i].Username < due[j].Username
+	})
+
+	usernames := make([]string, len(due))
+	for i, entry := range due {
+		usernames[i] = entry.Username
+	}
+	return usernames
+}
+
+// NextWake returns the earliest next run across all users
+func (s *Scheduler) NextWake() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var earliest time.Time
+	for _, entry := range s.entries {
+		if earliest.IsZero() || entry.NextRun.Before(earliest) {
+			earliest = entry.NextRun
+		}
+	}
+	return earliest
+}
+
+// MarkRun records a completed run and schedules the next one, no earlier than notBefore
+func (s *Scheduler) MarkRun(username string, finishedAt, notBefore time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.entries[strings.ToLower(username)]
+	if !ok {
+		return
+	}
+
+	entry.LastRun = finishedAt
+	entry.NextRun = maxTime(s.next(entry, finishedAt), notBefore)
+}
+
+// Defer postpones a due user without recording a run
+func (s *Scheduler) Defer(username string, until time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if entry, ok := s.entries[strings.ToLower(username)]; ok && entry.NextRun.Before(until) {
+		entry.NextRun = until
+	}
+}
+
+// Entries returns the schedule of every user ordered by next run, then priority
+func (s *Scheduler) Entries() []ScheduledUser {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries := make([]*scheduleEntry, 0, len(s.entries))
+	for _, entry := range s.entries {
+		entries = append(entries, entry)
+	}
+	sortEntries(entries)
+
+	result := make([]ScheduledUser, len(entries))
+	for i, entry := range entries {
+		result[i] = entry.ScheduledUser
+	}
+	return result
+}
+
+// Save persists next-run times. Entries of users that are no longer scheduled are kept
+// so that re-adding a user resumes its schedule.
+func (s *Scheduler) Save() error {
+	if s.path == "" {
+		return nil
+	}
+
+	persisted, err := loadScheduleFile(s.path)
+	if err != nil {
+		persisted = &scheduleFile{Users: make(map[string]ScheduledUser)}
+	}
+
+	s.mu.Lock()
+	for key, entry := range s.entries {
+		persisted.Users[key] = entry.ScheduledUser
+	}
+	s.mu.Unlock()
+	persisted.UpdatedAt = time.Now()
+
+	data, err := json.MarshalIndent(persisted, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode schedule: %v", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return fmt.Errorf("failed to create schedule directory: %v", err)
+	}
+
+	tempFile := s.path + ".tmp"
+	if err := os.WriteFile(tempFile, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write schedule: %v", err)
+	}
+	if err := os.Rename(tempFile, s.path); err != nil {
+		os.Remove(tempFile)
+		return fmt.Errorf("failed to rename schedule file: %v", err)
+	}
+
+	return nil
+}
+
+// next computes the following run time after t including jitter
+func (s *Scheduler) next(entry *scheduleEntry, t time.Time) time.Time {
+	return entry.schedule.Next(t).Add(s.randomJitter())
+}
+
+// randomJitter returns a random delay up to the configured jitter
+func (s *Scheduler) randomJitter() time.Duration {
+	if s.jitter <= 0 {
+		return 0
+	}
+	return rand.N(s.jitter)
+}
+
+// loadScheduleFile reads the persisted schedule; a missing file yields an empty schedule
+func loadScheduleFile(path string) (*scheduleFile, error) {
+	persisted := &scheduleFile{Users: make(map[string]ScheduledUser)}
+	if path == "" {
+		return persisted, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return persisted, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schedule file: %v", err)
+	}
+
+	if err := json.Unmarshal(data, persisted); err != nil {
+		return nil, fmt.Errorf("failed to parse schedule file %s: %v", path, err)
+	}
+
+	// Users are keyed by lowercase username; older files used the username as given
+	users := make(map[string]ScheduledUser, len(persisted.Users))
+	for username, user := range persisted.Users {
+		key := strings.ToLower(username)
+		if existing, ok := users[key]; !ok || user.LastRun.After(existing.LastRun) {
+			users[key] = user
+		}
+	}
+	persisted.Users = users
+
+	return persisted, nil
+}
+
+// sortEntries orders entries by next run, then priority (highest first), then username
+func sortEntries(entries []*scheduleEntry) {
+	sort.Slice(entries, func(i, j int) bool {
+		if !entries[i].NextRun.Equal(entries[j].NextRun) {
+			return entries[i].NextRun.Before(entries[j].NextRun)
+		}
+		if entries[i].Priority != entries[j].Priority {
+			return entries[i].Priority > entries[j].Priority
+		}
+		return entries[i].Username < entries[j].Username
+	})
+}
+
+// maxTime returns the later of two timestamps
+func maxTime(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
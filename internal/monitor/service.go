@@ -2,14 +2,16 @@ package monitor
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
 	"log/slog"
 	"os"
 	"sort"
 	"strings"
+	"sync"
 	"time"
 
-	"github.com/akme/gh-stars-watcher/internal/auth"
 	"github.com/akme/gh-stars-watcher/internal/config"
 	"github.com/akme/gh-stars-watcher/internal/github"
 	"github.com/akme/gh-stars-watcher/internal/storage"
@@ -22,19 +24,26 @@ const (
 	reStarThreshold = 10 * time.Minute
 )
 
-// Service provides the core monitoring functionality
+// Service provides the core monitoring functionality. It is configured once through
+// NewService and its setters, after which MonitorUser is safe for concurrent use: runs
+// share the GitHub client and store but keep all per-user state to themselves.
 type Service struct {
-	githubClient github.GitHubClient
-	storage      storage.StateStorage
-	tokenManager auth.TokenManager
-	progressFunc func(message string) // Optional progress callback
+	githubClient github.GitHubClient // Authenticated once at setup, never replaced
+	governor     *RateGovernor       // Rate limit shared by all runs
+	storage      storage.Store
+	progressFunc func(message string) // Optional progress callback, called from concurrent runs
 	config       *config.Config       // Configuration for incremental fetching
 	retryManager *RetryManager        // Retry logic manager
 	logger       *slog.Logger         // Structured logger
+
+	acceptLargeChanges bool            // Save runs that the safety guard finds suspicious
+	fullSyncIntervals  map[string]int  // Per-user full sync intervals in hours, keyed by lowercase username
+	freshBaselines     map[string]bool // Users whose stored state is ignored, keyed by lowercase username
 }
 
-// NewService creates a new monitoring service
-func NewService(githubClient github.GitHubClient, storage storage.StateStorage, tokenManager auth.TokenManager, cfg *config.Config) *Service {
+// NewService creates a new monitoring service. githubClient is used for every user, so
+// it must already carry the token the runs should use.
+func NewService(githubClient github.GitHubClient, storage storage.Store, cfg *config.Config) *Service {
 	if cfg == nil {
 		cfg = config.DefaultConfig()
 	}
@@ -52,14 +61,25 @@ func NewService(githubClient github.GitHubClient, storage storage.StateStorage,
 
 	retryManager := NewRetryManager(&cfg.Retry)
 
-	return &Service{
+	// Every call of every run goes through the shared rate governor
+	governor := NewRateGovernor(cfg.Monitor.RateLimitReserve, cfg.Retry.RateLimitBuffer)
+	if githubClient != nil {
+		githubClient = &governedClient{client: githubClient, governor: governor}
+	}
+
+	s := &Service{
 		githubClient: githubClient,
+		governor:     governor,
 		storage:      storage,
-		tokenManager: tokenManager,
 		config:       cfg,
 		retryManager: retryManager,
 		logger:       logger,
 	}
+	governor.SetPauseCallback(func(until time.Time) {
+		s.logInfo("Pausing all users until rate limit reset", "reserve", cfg.Monitor.RateLimitReserve, "until", until)
+		s.progress("Rate limit nearly exhausted, pausing all users until " + until.Local().Format("15:04:05"))
+	})
+	return s
 }
 
 // createLogger creates a structured logger based on configuration
@@ -95,7 +115,13 @@ func createLogger(cfg *config.Config) *slog.Logger {
 	return slog.New(handler)
 }
 
-// SetProgressCallback sets a callback function for progress updates
+// Logger returns the structured logger of the service
+func (s *Service) Logger() *slog.Logger {
+	return s.logger
+}
+
+// SetProgressCallback sets a callback function for progress updates. It must be set
+// before monitoring starts and be safe to call from several runs at once.
 func (s *Service) SetProgressCallback(callback func(message string)) {
 	s.progressFunc = callback
 	// Also configure retry manager to use the same progress function for logging
@@ -111,6 +137,22 @@ func (s *Service) SetProgressCallback(callback func(message string)) {
 	}
 }
 
+// SetFullSyncIntervals overrides the configured full sync interval of some users, keyed
+// by lowercase username. It must be set before monitoring starts.
+func (s *Service) SetFullSyncIntervals(intervals map[string]int) {
+	s.fullSyncIntervals = intervals
+}
+
+// SetFreshBaselines makes the runs of usernames ignore their stored state and record a new
+// baseline, like a first run, e.g. for accounts that were tracked before, dropped and are
+// now tracked again. It must be set before monitoring starts.
+func (s *Service) SetFreshBaselines(usernames []string) {
+	s.freshBaselines = make(map[string]bool, len(usernames))
+	for _, username := range usernames {
+		s.freshBaselines[strings.ToLower(username)] = true
+	}
+}
+
 // progress calls the progress callback if it's set
 func (s *Service) progress(message string) {
 	if s.progressFunc != nil {
@@ -150,29 +192,32 @@ func (s *Service) logPerformanceMetrics(msg string, args ...any) {
 }
 
 // MonitorUser monitors a GitHub user's starred repositories with enhanced incremental capabilities
-func (s *Service) MonitorUser(ctx context.Context, username, stateFilePath string) (*MonitorResult, error) {
+func (s *Service) MonitorUser(ctx context.Context, username string) (*MonitorResult, error) {
 	startTime := time.Now()
 	s.logPerformanceMetrics("Starting monitor", "username", username)
 	s.progress("Starting monitor for user: " + username)
 
-	// Try to get authentication token and create authenticated client if available
-	if token, source, err := s.tokenManager.GetToken(ctx); err == nil && token != "" {
-		s.progress("Using authentication from " + source)
-		// Create new authenticated GitHub client
-		s.githubClient = github.NewAPIClient(token)
-	} else {
-		s.progress("Using unauthenticated access (rate limits may apply)")
-	}
-
 	// Validate username
 	s.progress("Validating user exists...")
 	if err := s.githubClient.ValidateUser(ctx, username); err != nil {
 		return nil, fmt.Errorf("user validation failed: %w", err)
 	}
 
+	// Hold the user's lock from loading the state until the new state is saved, so runs
+	// in other processes cannot interleave and lose updates
+	lock, err := s.storage.Lock(ctx, username, s.config.Storage.LockTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock state: %w", err)
+	}
+	defer func() {
+		if err := lock.Unlock(); err != nil {
+			s.logError("Failed to release state lock", "username", username, "error", err)
+		}
+	}()
+
 	// Load previous state
 	s.progress("Loading previous state...")
-	previousState, err := s.loadPreviousState(stateFilePath, username)
+	previousState, err := s.loadPreviousState(username)
 	if err != nil {
 		return nil, fmt.Errorf("failed to load previous state: %w", err)
 	}
@@ -188,15 +233,22 @@ func (s *Service) MonitorUser(ctx context.Context, username, stateFilePath strin
 	s.progress("Analyzing repository changes...")
 	changes := s.findRepositoryChanges(previousState.Repositories, currentRepos)
 
+	// Keep the previous state when the fetch looks truncated
+	if err := s.checkLargeDrop(ctx, username, previousState, len(currentRepos)); err != nil {
+		return nil, err
+	}
+
 	// Update state with incremental fetch information
 	s.progress("Updating state...")
+	checkTime := time.Now()
 	updatedState := &storage.UserState{
 		Username:     username,
-		LastCheck:    time.Now(),
+		LastCheck:    checkTime,
 		Repositories: currentRepos,
 		TotalCount:   len(currentRepos),
-		StateVersion: "1.0.0",
+		StateVersion: storage.CurrentStateVersion,
 		CheckCount:   previousState.CheckCount + 1,
+		LastRunID:    newRunID(checkTime),
 
 		// Copy incremental fetch settings from previous state
 		LastStarredAt:      previousState.LastStarredAt,
@@ -229,7 +281,10 @@ func (s *Service) MonitorUser(ctx context.Context, username, stateFilePath strin
 		}
 	}
 
-	if err := s.storage.SaveUserState(stateFilePath, updatedState); err != nil {
+	// Record the changes in the event history together with the state
+	isFirstRun := previousState.CheckCount == 0
+	events := s.changeEvents(updatedState, previousState.Repositories, changes, isFirstRun)
+	if err := s.storage.Save(username, updatedState, events); err != nil {
 		return nil, fmt.Errorf("failed to save state: %w", err)
 	}
 
@@ -249,55 +304,107 @@ func (s *Service) MonitorUser(ctx context.Context, username, stateFilePath strin
 
 	return &MonitorResult{
 		Username:           username,
+		RunID:              updatedState.LastRunID,
 		Changes:            changes,
 		TotalRepositories:  len(currentRepos),
 		PreviousCheck:      previousState.LastCheck,
 		CurrentCheck:       updatedState.LastCheck,
 		RateLimit:          rateLimitInfo,
-		IsFirstRun:         previousState.CheckCount == 0,
+		IsFirstRun:         isFirstRun,
 		IsFullSync:         isFullSync,
 		APICallsSaved:      apiCallsSaved,
 		IncrementalEnabled: updatedState.IncrementalEnabled,
 	}, nil
 }
 
+// MonitorUsers monitors several users in parallel, at most monitor.concurrency at a time,
+// and collects results and errors per username. The queued, running and done counts are
+// reported through the progress callback as users start and finish.
+func (s *Service) MonitorUsers(ctx context.Context, usernames []string) (map[string]*MonitorResult, map[string]error) {
+	results := make(map[string]*MonitorResult)
+	errors := make(map[string]error)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	queue := make(chan string, len(usernames))
+	for _, username := range usernames {
+		queue <- username
+	}
+	close(queue)
+
+	queued, running, done := len(usernames), 0, 0
+	reportPool := func() {
+		s.progress(fmt.Sprintf("Users: %d queued, %d running, %d done", queued, running, done))
+	}
+
+	workers := s.config.Monitor.Concurrency
+	if workers > len(usernames) {
+		workers = len(usernames)
+	}
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for user := range queue {
+				mu.Lock()
+				queued, running = queued-1, running+1
+				reportPool()
+				mu.Unlock()
+
+				result, err := s.MonitorUser(ctx, user)
+
+				mu.Lock()
+				if err != nil {
+					errors[user] = err
+				} else {
+					results[user] = result
+				}
+				running, done = running-1, done+1
+				reportPool()
+				mu.Unlock()
+			}
+		}()
+	}
+
+	wg.Wait()
+	return results, errors
+}
+
 // loadPreviousState loads previous state or creates new state for first run
-func (s *Service) loadPreviousState(stateFilePath, username string) (*storage.UserState, error) {
-	state, err := s.storage.LoadUserState(stateFilePath)
+func (s *Service) loadPreviousState(username string) (*storage.UserState, error) {
+	if s.freshBaselines[strings.ToLower(username)] {
+		s.logInfo("Ignoring stored state to record a new baseline", "username", username)
+		state := storage.NewUserState(username)
+		state.FullSyncInterval = s.fullSyncInterval(username)
+		return state, nil
+	}
+
+	state, err := s.storage.Load(username)
 	if err != nil {
-		// Handle file not found - first run
-		if _, ok := err.(*storage.StateFileNotFoundError); ok {
-			return storage.NewUserState(username), nil
-		}
-		// Handle corruption - rebuild state
-		if _, ok := err.(*storage.StateCorruptionError); ok {
+		// Handle missing state - first run
+		if _, ok := err.(*storage.StateNotFoundError); ok {
+			state = storage.NewUserState(username)
+		} else if _, ok := err.(*storage.StateCorruptionError); ok {
+			// Handle corruption - rebuild state
 			s.logger.Warn("State file corrupted, rebuilding from current state")
-			return storage.NewUserState(username), nil
+			state = storage.NewUserState(username)
+		} else {
+			return nil, err
 		}
-		return nil, err
 	}
 
-	// Migrate old state files that don't have incremental fields set
-	s.migrateStateToIncrementalFields(state)
+	// The configured full sync interval takes precedence over the stored one
+	state.FullSyncInterval = s.fullSyncInterval(username)
 
 	return state, nil
 }
 
-// migrateStateToIncrementalFields ensures old state files have proper incremental defaults
-func (s *Service) migrateStateToIncrementalFields(state *storage.UserState) {
-	migrated := false
-
-	// If IncrementalEnabled is false and FullSyncInterval is 0, this is likely an old state file
-	if !state.IncrementalEnabled && state.FullSyncInterval == 0 {
-		state.IncrementalEnabled = true
-		state.FullSyncInterval = 24 // Default 24 hours
-		migrated = true
-		s.logInfo("Migrated state file to enable incremental fetching", "interval", "24h")
-	}
-
-	if migrated {
-		s.logInfo("State file migration completed", "username", state.Username)
+// fullSyncInterval returns the full sync interval of username: its own, or the configured one
+func (s *Service) fullSyncInterval(username string) int {
+	if interval, ok := s.fullSyncIntervals[strings.ToLower(username)]; ok {
+		return interval
 	}
+	return s.config.Incremental.FullSyncInterval
 }
 
 // fetchAllStarredRepos fetches all starred repositories with pagination
@@ -494,23 +601,15 @@ func (s *Service) fetchStarredReposWithFallback(ctx context.Context, username st
 	return allRepos, rateLimit, apiCallsSaved, isFullSync, err
 }
 
-// mergeRepositories merges new repositories with existing ones, handling duplicates
+// mergeRepositories merges new repositories with existing ones, handling duplicates.
+// A new repository replaces the existing one with the same ID, or the same name.
 func (s *Service) mergeRepositories(existing []storage.Repository, newRepos []storage.Repository) []storage.Repository {
-	repoMap := make(map[string]storage.Repository, len(existing)+len(newRepos))
-
-	for _, repo := range existing {
-		repoMap[repo.FullName] = repo
-	}
-
+	index := newRepositoryIndex(existing)
 	for _, repo := range newRepos {
-		repoMap[repo.FullName] = repo
-	}
-
-	merged := make([]storage.Repository, 0, len(repoMap))
-	for _, repo := range repoMap {
-		merged = append(merged, repo)
+		index.add(repo)
 	}
 
+	merged := index.repositories()
 	sort.Slice(merged, func(i, j int) bool {
 		if merged[i].StarredAt.Equal(merged[j].StarredAt) {
 			return merged[i].FullName < merged[j].FullName
@@ -527,37 +626,39 @@ type RepositoryChanges struct {
 	Unstars      []storage.Repository `json:"unstars"`       // Unstarred repositories
 	ReStars      []storage.Repository `json:"re_stars"`      // Re-starred repositories (starred, unstarred, then starred again)
 	Updated      []storage.Repository `json:"updated"`       // Repositories with updated metadata
+	Renamed      []RenamedRepository  `json:"renamed"`       // Repositories renamed or transferred since the last run
 	TotalChanges int                  `json:"total_changes"` // Total number of changes detected
 }
 
-// findRepositoryChanges compares current repositories with previous to find all types of changes
+// findRepositoryChanges compares current repositories with previous to find all types of changes.
+// Repositories are matched by GitHub ID, so a renamed or transferred repository is
+// reported as renamed instead of as an unstar and a new star.
 func (s *Service) findRepositoryChanges(previous, current []storage.Repository) *RepositoryChanges {
 	changes := &RepositoryChanges{
 		NewStars: make([]storage.Repository, 0),
 		Unstars:  make([]storage.Repository, 0),
 		ReStars:  make([]storage.Repository, 0),
 		Updated:  make([]storage.Repository, 0),
+		Renamed:  make([]RenamedRepository, 0),
 	}
 
-	// Create maps for efficient lookup
-	previousMap := make(map[string]storage.Repository)
-	currentMap := make(map[string]storage.Repository)
-
-	for _, repo := range previous {
-		previousMap[repo.FullName] = repo
-	}
-
-	for _, repo := range current {
-		currentMap[repo.FullName] = repo
-	}
+	// Index both sets for efficient lookup
+	previousIndex := newRepositoryIndex(previous)
+	currentIndex := newRepositoryIndex(current)
 
 	// Find new stars (in current but not in previous)
 	for _, currentRepo := range current {
-		if _, exists := previousMap[currentRepo.FullName]; !exists {
+		prevRepo, exists := previousIndex.find(currentRepo)
+		if !exists {
 			changes.NewStars = append(changes.NewStars, currentRepo)
 		} else {
+			// Check for renames and transfers (same repo under another name)
+			if prevRepo.FullName != currentRepo.FullName {
+				s.logDebug("Detected renamed repository", "from", prevRepo.FullName, "to", currentRepo.FullName)
+				changes.Renamed = append(changes.Renamed, RenamedRepository{PreviousFullName: prevRepo.FullName, Repository: currentRepo})
+			}
+
 			// Check for updates (same repo but different metadata)
-			prevRepo := previousMap[currentRepo.FullName]
 			if s.hasRepositoryChanged(prevRepo, currentRepo) {
 				changes.Updated = append(changes.Updated, currentRepo)
 			}
@@ -585,13 +686,13 @@ func (s *Service) findRepositoryChanges(previous, current []storage.Repository)
 	// Find unstars (in previous but not in current) - only if enabled in config
 	if s.config.Incremental.DetectUnstars {
 		for _, prevRepo := range previous {
-			if _, exists := currentMap[prevRepo.FullName]; !exists {
+			if _, exists := currentIndex.find(prevRepo); !exists {
 				changes.Unstars = append(changes.Unstars, prevRepo)
 			}
 		}
 	}
 
-	changes.TotalChanges = len(changes.NewStars) + len(changes.Unstars) + len(changes.ReStars) + len(changes.Updated)
+	changes.TotalChanges = len(changes.NewStars) + len(changes.Unstars) + len(changes.ReStars) + len(changes.Updated) + len(changes.Renamed)
 	return changes
 }
 
@@ -629,12 +730,81 @@ func extractRetryAfter(err error) time.Duration {
 
 // hasRepositoryChanged checks if repository metadata has changed
 func (s *Service) hasRepositoryChanged(prev, current storage.Repository) bool {
-	// Check significant metadata changes
-	return prev.Description != current.Description ||
-		prev.StarCount != current.StarCount ||
-		prev.Language != current.Language ||
-		prev.Private != current.Private ||
-		!prev.UpdatedAt.Equal(current.UpdatedAt)
+	return len(changedFields(prev, current)) > 0
+}
+
+// changedFields returns the JSON names of the significant metadata fields that differ
+func changedFields(prev, current storage.Repository) []string {
+	var fields []string
+	if prev.Description != current.Description {
+		fields = append(fields, "description")
+	}
+	if prev.StarCount != current.StarCount {
+		fields = append(fields, "star_count")
+	}
+	if prev.Language != current.Language {
+		fields = append(fields, "language")
+	}
+	if prev.Private != current.Private {
+		fields = append(fields, "private")
+	}
+	if !prev.UpdatedAt.Equal(current.UpdatedAt) {
+		fields = append(fields, "updated_at")
+	}
+	return fields
+}
+
+// changeEvents converts the changes of a run into event log entries. The first run of a
+// user records every repository as a baseline event instead of as new stars.
+func (s *Service) changeEvents(state *storage.UserState, previous []storage.Repository, changes *RepositoryChanges, isFirstRun bool) []storage.Event {
+	event := func(eventType string, repo storage.Repository) storage.Event {
+		return storage.Event{
+			RunID:      state.LastRunID,
+			Timestamp:  state.LastCheck,
+			Username:   state.Username,
+			Type:       eventType,
+			Repository: repo,
+		}
+	}
+
+	var events []storage.Event
+	if isFirstRun {
+		for _, repo := range state.Repositories {
+			events = append(events, event(storage.EventBaseline, repo))
+		}
+		return events
+	}
+
+	previousIndex := newRepositoryIndex(previous)
+
+	for _, repo := range changes.NewStars {
+		events = append(events, event(storage.EventStar, repo))
+	}
+	for _, repo := range changes.ReStars {
+		events = append(events, event(storage.EventReStar, repo))
+	}
+	for _, repo := range changes.Unstars {
+		events = append(events, event(storage.EventUnstar, repo))
+	}
+	for _, renamed := range changes.Renamed {
+		rename := event(storage.EventRename, renamed.Repository)
+		rename.PreviousFullName = renamed.PreviousFullName
+		events = append(events, rename)
+	}
+	for _, repo := range changes.Updated {
+		updated := event(storage.EventUpdate, repo)
+		previousRepo, _ := previousIndex.find(repo)
+		updated.Changes = changedFields(previousRepo, repo)
+		events = append(events, updated)
+	}
+	return events
+}
+
+// newRunID returns a unique, time-ordered ID for a monitor run, e.g. 20260316T120000Z-1a2b3c4d
+func newRunID(now time.Time) string {
+	suffix := make([]byte, 4)
+	rand.Read(suffix)
+	return now.UTC().Format("20060102T150405Z") + "-" + hex.EncodeToString(suffix)
 }
 
 // MonitorResult contains comprehensive results including incremental fetch information
@@ -643,6 +813,7 @@ type MonitorResult struct {
 	CurrentCheck       time.Time            `json:"current_check"`
 	RateLimit          github.RateLimitInfo `json:"rate_limit"`
 	Username           string               `json:"username"`
+	RunID              string               `json:"run_id"`  // Identifies the run in the event log
 	Changes            *RepositoryChanges   `json:"changes"` // Detailed change analysis
 	TotalRepositories  int                  `json:"total_repositories"`
 	APICallsSaved      int                  `json:"api_calls_saved"` // Estimated API calls saved by incremental fetch
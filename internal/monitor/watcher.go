@@ -0,0 +1,228 @@
+package monitor
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/akme/gh-stars-watcher/internal/github"
+)
+
+// estimatedCallsPerUser is a rough number of API calls one user costs per cycle
+// (user validation plus at least one page of starred repositories)
+const estimatedCallsPerUser = 2
+
+// CycleResult contains the outcome of one watch cycle
+type CycleResult struct {
+	Cycle      int                       `json:"cycle"`
+	StartedAt  time.Time                 `json:"started_at"`
+	FinishedAt time.Time                 `json:"finished_at"`
+	Results    map[string]*MonitorResult `json:"results"`
+	Errors     map[string]error          `json:"-"`
+	NextRun    time.Time                 `json:"next_run"`
+	RateLimit  *github.RateLimitInfo     `json:"rate_limit,omitempty"` // Set when runs wait for a rate limit reset
+}
+
+// Watcher keeps a Service alive and runs MonitorUsers for users as the scheduler makes them due
+type Watcher struct {
+	service   *Service
+	scheduler *Scheduler
+	buffer    time.Duration         // Extra wait after a rate limit reset
+	rateLimit *github.RateLimitInfo // Most restrictive rate limit seen in the last cycle
+
+	stopOnce sync.Once
+	stopCh   chan struct{}
+}
+
+// NewWatcher creates a watcher that runs users according to scheduler
+func NewWatcher(service *Service, scheduler *Scheduler) *Watcher {
+	return &Watcher{
+		service:   service,
+		scheduler: scheduler,
+		buffer:    service.config.Retry.RateLimitBuffer,
+		stopCh:    make(chan struct{}),
+	}
+}
+
+// Stop asks the watcher to exit once the current cycle has finished and its state is saved.
+// Cancel the context passed to Run to abort a cycle in progress instead.
+func (w *Watcher) Stop() {
+	w.stopOnce.Do(func() {
+		close(w.stopCh)
+	})
+}
+
+// Run executes monitoring cycles until Stop is called or ctx is cancelled.
+// A cycle monitors every user that is due; onCycle is called after every cycle with its results.
+// The schedule is saved before Run returns, including the runs that finished before ctx was cancelled.
+func (w *Watcher) Run(ctx context.Context, onCycle func(*CycleResult)) error {
+	cycle := 0
+	for {
+		if w.stopped() {
+			w.saveSchedule()
+			return nil
+		}
+
+		due := w.scheduler.Due(time.Now())
+		runnable, deferredUntil := w.applyRateBudget(due)
+
+		if len(runnable) > 0 {
+			cycle++
+			result := &CycleResult{
+				Cycle:     cycle,
+				StartedAt: time.Now(),
+			}
+			result.Results, result.Errors = w.service.MonitorUsers(ctx, runnable)
+			result.FinishedAt = time.Now()
+
+			if ctx.Err() != nil {
+				// Keep the runs that finished before the cancellation
+				for _, username := range runnable {
+					if err, failed := result.Errors[username]; !failed || !isContextError(err) {
+						w.scheduler.MarkRun(username, result.FinishedAt, time.Time{})
+					}
+				}
+				w.saveSchedule()
+				return ctx.Err()
+			}
+
+			w.rateLimit = lowestRateLimit(result)
+			notBefore := w.resetWait(len(runnable))
+			for _, username := range runnable {
+				w.scheduler.MarkRun(username, result.FinishedAt, notBefore)
+			}
+			w.saveSchedule()
+
+			result.NextRun = w.scheduler.NextWake()
+			if !notBefore.IsZero() || !deferredUntil.IsZero() {
+				result.RateLimit = w.rateLimit
+			}
+
+			if onCycle != nil {
+				onCycle(result)
+			}
+		}
+
+		wake := w.scheduler.NextWake()
+		if wake.IsZero() {
+			return errors.New("no users to watch")
+		}
+
+		// A past wake time makes the timer ready at once; select would pick it over Stop at random
+		if w.stopped() {
+			w.saveSchedule()
+			return nil
+		}
+
+		timer := time.NewTimer(time.Until(wake))
+		select {
+		case <-w.stopCh:
+			timer.Stop()
+			w.saveSchedule()
+			return nil
+		case <-ctx.Done():
+			timer.Stop()
+			w.saveSchedule()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// stopped reports whether Stop has been called
+func (w *Watcher) stopped() bool {
+	select {
+	case <-w.stopCh:
+		return true
+	default:
+		return false
+	}
+}
+
+// saveSchedule persists the schedule, logging a failure
+func (w *Watcher) saveSchedule() {
+	if err := w.scheduler.Save(); err != nil {
+		w.service.logError("Failed to save schedule", "error", err)
+	}
+}
+
+// isContextError reports whether err comes from a cancelled or expired context
+func isContextError(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
+
+// applyRateBudget limits the due users to what the remaining quota can cover.
+// Users that do not fit, lowest priority first, are deferred until the rate limit resets.
+func (w *Watcher) applyRateBudget(due []string) ([]string, time.Time) {
+	if w.rateLimit == nil || !w.rateLimit.ResetTime.After(time.Now()) {
+		return due, time.Time{}
+	}
+
+	budget := w.rateLimit.Remaining / estimatedCallsPerUser
+	if budget >= len(due) {
+		return due, time.Time{}
+	}
+
+	until := w.rateLimit.ResetTime.Add(w.buffer)
+	for _, username := range due[budget:] {
+		w.scheduler.Defer(username, until)
+	}
+	w.service.logInfo("Deferring users until rate limit reset",
+		"deferred", len(due)-budget, "remaining", w.rateLimit.Remaining, "reset_time", w.rateLimit.ResetTime)
+
+	return due[:budget], until
+}
+
+// resetWait returns the earliest time the users that just ran may run again: the rate limit
+// reset when the remaining quota cannot cover another run of them, otherwise zero
+func (w *Watcher) resetWait(users int) time.Time {
+	if w.rateLimit == nil || w.rateLimit.ResetTime.IsZero() {
+		return time.Time{}
+	}
+
+	if w.rateLimit.Remaining >= users*estimatedCallsPerUser {
+		return time.Time{}
+	}
+
+	w.service.logInfo("Delaying next runs until rate limit reset",
+		"remaining", w.rateLimit.Remaining, "reset_time", w.rateLimit.ResetTime)
+	return w.rateLimit.ResetTime.Add(w.buffer)
+}
+
+// lowestRateLimit returns the most restrictive rate limit seen during a cycle
+func lowestRateLimit(result *CycleResult) *github.RateLimitInfo {
+	var lowest *github.RateLimitInfo
+
+	consider := func(info github.RateLimitInfo) {
+		if lowest == nil || info.Remaining < lowest.Remaining ||
+			(info.Remaining == lowest.Remaining && info.ResetTime.After(lowest.ResetTime)) {
+			copied := info
+			lowest = &copied
+		}
+	}
+
+	for _, res := range result.Results {
+		if res.RateLimit.Limit > 0 {
+			consider(res.RateLimit)
+		}
+	}
+
+	for _, err := range result.Errors {
+		var rateLimitErr *github.RateLimitError
+		if errors.As(err, &rateLimitErr) {
+			resetTime, parseErr := time.Parse(time.RFC3339, rateLimitErr.ResetTime)
+			if parseErr != nil {
+				continue
+			}
+			consider(github.RateLimitInfo{
+				Limit:     rateLimitErr.Limit,
+				Remaining: 0,
+				ResetTime: resetTime,
+				Used:      rateLimitErr.Used,
+			})
+		}
+	}
+
+	return lowest
+}
@@ -0,0 +1,362 @@
+package monitor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/akme/gh-stars-watcher/internal/config"
+	"github.com/akme/gh-stars-watcher/internal/github"
+	"github.com/akme/gh-stars-watcher/internal/storage"
+)
+
+func TestScheduler_NewUsersAreDue(t *testing.T) {
+	cfg := config.DefaultConfig().Watch
+	scheduler, err := NewScheduler([]string{"alice", "bob"}, cfg, "")
+	if err != nil {
+		t.Fatalf("NewScheduler failed: %v", err)
+	}
+
+	due := scheduler.Due(time.Now())
+	if len(due) != 2 {
+		t.Errorf("Due() = %v, want both users", due)
+	}
+}
+
+func TestScheduler_PersistsNextRunAcrossRestarts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "schedule.json")
+	cfg := config.DefaultConfig().Watch
+	cfg.Interval = time.Hour
+	cfg.Jitter = 0
+
+	scheduler, err := NewScheduler([]string{"alice"}, cfg, path)
+	if err != nil {
+		t.Fatalf("NewScheduler failed: %v", err)
+	}
+
+	finished := time.Now()
+	scheduler.MarkRun("alice", finished, time.Time{})
+	if err := scheduler.Save(); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	want := scheduler.Entries()[0].NextRun
+
+	restarted, err := NewScheduler([]string{"alice", "bob"}, cfg, path)
+	if err != nil {
+		t.Fatalf("NewScheduler after restart failed: %v", err)
+	}
+
+	for _, entry := range restarted.Entries() {
+		switch entry.Username {
+		case "alice":
+			if !entry.NextRun.Equal(want) {
+				t.Errorf("alice NextRun = %s, want persisted %s", entry.NextRun, want)
+			}
+			if !entry.LastRun.Equal(finished) {
+				t.Errorf("alice LastRun = %s, want %s", entry.LastRun, finished)
+			}
+		case "bob":
+			if entry.NextRun.After(time.Now()) {
+				t.Errorf("bob NextRun = %s, want new user due now", entry.NextRun)
+			}
+		}
+	}
+
+	if due := restarted.Due(time.Now()); len(due) != 1 || due[0] != "bob" {
+		t.Errorf("Due() = %v, want [bob]", due)
+	}
+}
+
+func TestScheduler_UsernamesIgnoreCase(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "schedule.json")
+	cfg := config.DefaultConfig().Watch
+	cfg.Interval = time.Hour
+	cfg.Jitter = 0
+
+	scheduler, err := NewScheduler([]string{"Alice"}, cfg, path)
+	if err != nil {
+		t.Fatalf("NewScheduler failed: %v", err)
+	}
+	scheduler.MarkRun("alice", time.Now(), time.Time{})
+	if due := scheduler.Due(time.Now()); len(due) != 0 {
+		t.Errorf("Due() after MarkRun(alice) = %v, want Alice marked as run", due)
+	}
+	if err := scheduler.Save(); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	restarted, err := NewScheduler([]string{"ALICE"}, cfg, path)
+	if err != nil {
+		t.Fatalf("NewScheduler after restart failed: %v", err)
+	}
+	if due := restarted.Due(time.Now()); len(due) != 0 {
+		t.Errorf("Due() after restart = %v, want the persisted schedule of Alice", due)
+	}
+}
+
+func TestScheduler_SpreadsOverdueUsersAfterRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "schedule.json")
+	cfg := config.DefaultConfig().Watch
+	cfg.Interval = time.Minute
+	cfg.Jitter = 10 * time.Minute
+
+	scheduler, err := NewScheduler([]string{"alice", "bob", "carol"}, cfg, path)
+	if err != nil {
+		t.Fatalf("NewScheduler failed: %v", err)
+	}
+	for _, username := range []string{"alice", "bob", "carol"} {
+		scheduler.MarkRun(username, time.Now().Add(-time.Hour), time.Time{})
+	}
+	if err := scheduler.Save(); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	start := time.Now()
+	restarted, err := NewScheduler([]string{"alice", "bob", "carol"}, cfg, path)
+	if err != nil {
+		t.Fatalf("NewScheduler after restart failed: %v", err)
+	}
+
+	for _, entry := range restarted.Entries() {
+		if entry.NextRun.Before(start) || entry.NextRun.After(start.Add(cfg.Jitter+time.Second)) {
+			t.Errorf("%s NextRun = %s, want within jitter window after %s", entry.Username, entry.NextRun, start)
+		}
+	}
+}
+
+func TestScheduler_ScheduleChangeRecomputesNextRun(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "schedule.json")
+	cfg := config.DefaultConfig().Watch
+	cfg.Interval = 24 * time.Hour
+	cfg.Jitter = 0
+
+	scheduler, err := NewScheduler([]string{"alice"}, cfg, path)
+	if err != nil {
+		t.Fatalf("NewScheduler failed: %v", err)
+	}
+	scheduler.MarkRun("alice", time.Now(), time.Time{})
+	if err := scheduler.Save(); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	cfg.Schedules = []config.UserSchedule{{User: "alice", Cron: "@every 1h"}}
+	restarted, err := NewScheduler([]string{"alice"}, cfg, path)
+	if err != nil {
+		t.Fatalf("NewScheduler after restart failed: %v", err)
+	}
+
+	entry := restarted.Entries()[0]
+	if entry.Spec != "@every 1h" {
+		t.Errorf("Spec = %q, want %q", entry.Spec, "@every 1h")
+	}
+	if entry.NextRun.After(time.Now().Add(time.Hour + time.Second)) {
+		t.Errorf("NextRun = %s, want within the new 1h schedule", entry.NextRun)
+	}
+}
+
+func TestScheduler_DueOrdersByPriority(t *testing.T) {
+	cfg := config.DefaultConfig().Watch
+	cfg.Schedules = []config.UserSchedule{
+		{User: "low", Priority: -1},
+		{User: "high", Priority: 10},
+	}
+
+	scheduler, err := NewScheduler([]string{"low", "normal", "high"}, cfg, "")
+	if err != nil {
+		t.Fatalf("NewScheduler failed: %v", err)
+	}
+
+	due := scheduler.Due(time.Now())
+	want := []string{"high", "normal", "low"}
+	if fmt.Sprint(due) != fmt.Sprint(want) {
+		t.Errorf("Due() = %v, want %v", due, want)
+	}
+}
+
+func TestScheduler_InvalidUserSchedule(t *testing.T) {
+	cfg := config.DefaultConfig().Watch
+	cfg.Schedules = []config.UserSchedule{{User: "alice", Cron: "not a cron"}}
+
+	if _, err := NewScheduler([]string{"alice"}, cfg, ""); err == nil {
+		t.Error("expected error for invalid cron expression")
+	}
+}
+
+func TestWatcher_RateBudgetDefersLowPriorityUsers(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Retry.RateLimitBuffer = 30 * time.Second
+	cfg.Watch.Schedules = []config.UserSchedule{{User: "vip", Priority: 5}}
+
+	scheduler, err := NewScheduler([]string{"alice", "bob", "vip"}, cfg.Watch, "")
+	if err != nil {
+		t.Fatalf("NewScheduler failed: %v", err)
+	}
+	watcher := NewWatcher(NewService(nil, nil, cfg), scheduler)
+
+	reset := time.Now().Add(20 * time.Minute)
+	watcher.rateLimit = &github.RateLimitInfo{Limit: 60, Remaining: estimatedCallsPerUser, ResetTime: reset}
+
+	runnable, until := watcher.applyRateBudget(scheduler.Due(time.Now()))
+	if len(runnable) != 1 || runnable[0] != "vip" {
+		t.Errorf("runnable = %v, want [vip]", runnable)
+	}
+	if !until.Equal(reset.Add(30 * time.Second)) {
+		t.Errorf("deferred until %s, want %s", until, reset.Add(30*time.Second))
+	}
+
+	if due := scheduler.Due(time.Now()); len(due) != 1 || due[0] != "vip" {
+		t.Errorf("Due() after deferral = %v, want only [vip]", due)
+	}
+}
+
+func TestWatcher_ResetWait(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Retry.RateLimitBuffer = 30 * time.Second
+	scheduler, err := NewScheduler([]string{"alice"}, cfg.Watch, "")
+	if err != nil {
+		t.Fatalf("NewScheduler failed: %v", err)
+	}
+	watcher := NewWatcher(NewService(nil, nil, cfg), scheduler)
+
+	reset := time.Now().Add(40 * time.Minute)
+
+	tests := []struct {
+		name  string
+		cycle *CycleResult
+		want  time.Time
+	}{
+		{
+			name: "PlentyOfQuota",
+			cycle: &CycleResult{Results: map[string]*MonitorResult{
+				"alice": {RateLimit: github.RateLimitInfo{Limit: 5000, Remaining: 4000, ResetTime: reset}},
+			}},
+		},
+		{
+			name: "ExhaustedQuota",
+			cycle: &CycleResult{Results: map[string]*MonitorResult{
+				"alice": {RateLimit: github.RateLimitInfo{Limit: 60, Remaining: 1, ResetTime: reset}},
+			}},
+			want: reset.Add(30 * time.Second),
+		},
+		{
+			name: "RateLimitError",
+			cycle: &CycleResult{Errors: map[string]error{
+				"alice": fmt.Errorf("failed to fetch repositories: %w", &github.RateLimitError{
+					ResetTime: reset.Format(time.RFC3339),
+					Limit:     5000,
+					Used:      5000,
+				}),
+			}},
+			want: reset.Truncate(time.Second).Add(30 * time.Second),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			watcher.rateLimit = lowestRateLimit(tt.cycle)
+			got := watcher.resetWait(1)
+			if !got.Equal(tt.want) {
+				t.Errorf("resetWait() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWatcher_RunWithoutUsers(t *testing.T) {
+	scheduler, err := NewScheduler(nil, config.DefaultConfig().Watch, "")
+	if err != nil {
+		t.Fatalf("NewScheduler failed: %v", err)
+	}
+	watcher := NewWatcher(NewService(nil, nil, config.DefaultConfig()), scheduler)
+
+	if err := watcher.Run(context.Background(), nil); err == nil {
+		t.Error("Run without users returned nil, want an error")
+	}
+}
+
+// cancellingGitHubClient cancels the watch when a user is validated
+type cancellingGitHubClient struct {
+	*perUserGitHubClient
+	cancelOn string
+	cancel   context.CancelFunc
+}
+
+func (c *cancellingGitHubClient) ValidateUser(ctx context.Context, username string) error {
+	if username == c.cancelOn {
+		c.cancel()
+		return ctx.Err()
+	}
+	return c.perUserGitHubClient.ValidateUser(ctx, username)
+}
+
+func TestWatcher_CancelSavesFinishedRuns(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Logging.LogLevel = "error"
+	cfg.Monitor.Concurrency = 1
+	cfg.Watch.Jitter = 0
+	path := filepath.Join(t.TempDir(), "schedule.json")
+
+	scheduler, err := NewScheduler([]string{"alice", "bob"}, cfg.Watch, path)
+	if err != nil {
+		t.Fatalf("NewScheduler failed: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	client := &cancellingGitHubClient{
+		perUserGitHubClient: &perUserGitHubClient{starred: map[string][]storage.Repository{
+			"alice": starredRepositories(2),
+			"bob":   starredRepositories(2),
+		}},
+		cancelOn: "bob",
+		cancel:   cancel,
+	}
+	watcher := NewWatcher(NewService(client, storage.NewJSONStore(t.TempDir()), cfg), scheduler)
+
+	if err := watcher.Run(ctx, nil); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Run = %v, want context.Canceled", err)
+	}
+
+	restarted, err := NewScheduler([]string{"alice", "bob"}, cfg.Watch, path)
+	if err != nil {
+		t.Fatalf("NewScheduler after restart failed: %v", err)
+	}
+	for _, entry := range restarted.Entries() {
+		if ran := !entry.LastRun.IsZero(); ran != (entry.Username == "alice") {
+			t.Errorf("%s LastRun = %s after the cancelled cycle, want a run only for alice", entry.Username, entry.LastRun)
+		}
+	}
+}
+
+func TestWatcher_StopWithPastWakeTime(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Logging.LogLevel = "error"
+	cfg.Watch.Jitter = 0
+
+	// Stop and a ready timer race in select, so try several times
+	for i := 0; i < 20; i++ {
+		scheduler, err := NewScheduler([]string{"alice"}, cfg.Watch, "")
+		if err != nil {
+			t.Fatalf("NewScheduler failed: %v", err)
+		}
+		client := &perUserGitHubClient{starred: map[string][]storage.Repository{"alice": starredRepositories(2)}}
+		watcher := NewWatcher(NewService(client, storage.NewJSONStore(t.TempDir()), cfg), scheduler)
+
+		cycles := 0
+		err = watcher.Run(context.Background(), func(*CycleResult) {
+			cycles++
+			// The cycle overran the interval, and Ctrl+C arrived during it
+			scheduler.entries["alice"].NextRun = time.Now().Add(-time.Minute)
+			watcher.Stop()
+		})
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+		if cycles != 1 {
+			t.Fatalf("Run ran %d cycles after Stop, want 1", cycles)
+		}
+	}
+}
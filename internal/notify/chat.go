@@ -0,0 +1,126 @@
+package notify
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/akme/gh-stars-watcher/internal/monitor"
+	"github.com/akme/gh-stars-watcher/internal/storage"
+	"github.com/akme/gh-stars-watcher/internal/templates"
+)
+
+// maxDescriptionLength keeps repository descriptions short in chat messages
+const maxDescriptionLength = 200
+
+// chatChanges reports whether a payload contains changes the chat sinks render.
+// Metadata updates are left out of chat messages to keep channels quiet.
+func chatChanges(payload *Payload) bool {
+	changes := payload.Changes
+	return changes != nil && len(changes.NewStars)+len(changes.ReStars)+len(changes.Unstars)+len(changes.Renamed) > 0
+}
+
+// chatSummary describes the changes of a payload, e.g. "starred 2, re-starred 1, unstarred 1"
+func chatSummary(payload *Payload) string {
+	var parts []string
+	if n := len(payload.Changes.NewStars); n > 0 {
+		parts = append(parts, fmt.Sprintf("starred %d", n))
+	}
+	if n := len(payload.Changes.ReStars); n > 0 {
+		parts = append(parts, fmt.Sprintf("re-starred %d", n))
+	}
+	if n := len(payload.Changes.Unstars); n > 0 {
+		parts = append(parts, fmt.Sprintf("unstarred %d", n))
+	}
+	if n := len(payload.Changes.Renamed); n > 0 {
+		parts = append(parts, fmt.Sprintf("renamed %d", n))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// repoDetails returns the language and star count line of a repository, e.g. "Go · ★ 1.2k"
+func repoDetails(repo storage.Repository) string {
+	var parts []string
+	if repo.Language != "" {
+		parts = append(parts, repo.Language)
+	}
+	parts = append(parts, "★ "+templates.Humanize(repo.StarCount))
+	return strings.Join(parts, " · ")
+}
+
+// repoNames joins repository names into chunks that each fit within max characters
+func repoNames(repos []storage.Repository, max int) []string {
+	names := make([]string, len(repos))
+	for i, repo := range repos {
+		names[i] = repo.FullName
+	}
+	return joinNames(names, max)
+}
+
+// renamedNames joins renamed repositories as "old → new" into chunks that each fit
+// within max characters
+func renamedNames(renamed []monitor.RenamedRepository, max int) []string {
+	names := make([]string, len(renamed))
+	for i, r := range renamed {
+		names[i] = r.PreviousFullName + " → " + r.Repository.FullName
+	}
+	return joinNames(names, max)
+}
+
+// joinNames joins names into chunks that each fit within max characters
+func joinNames(names []string, max int) []string {
+	var chunks []string
+	var current strings.Builder
+
+	for _, name := range names {
+		name = templates.Truncate(name, max)
+		if current.Len() > 0 && utf8.RuneCountInString(current.String())+2+utf8.RuneCountInString(name) > max {
+			chunks = append(chunks, current.String())
+			current.Reset()
+		}
+		if current.Len() > 0 {
+			current.WriteString(", ")
+		}
+		current.WriteString(name)
+	}
+
+	if current.Len() > 0 {
+		chunks = append(chunks, current.String())
+	}
+	return chunks
+}
+
+// splitText splits text into chunks of at most max characters, preferring line breaks.
+// Blank text yields no chunks.
+func splitText(text string, max int) []string {
+	var chunks []string
+	var current strings.Builder
+	currentLen := 0
+
+	flush := func() {
+		if chunk := strings.TrimSpace(current.String()); chunk != "" {
+			chunks = append(chunks, chunk)
+		}
+		current.Reset()
+		currentLen = 0
+	}
+
+	for _, line := range strings.SplitAfter(text, "\n") {
+		lineLen := utf8.RuneCountInString(line)
+		if currentLen > 0 && currentLen+lineLen > max {
+			flush()
+		}
+		for lineLen > max {
+			runes := []rune(line)
+			current.WriteString(string(runes[:max]))
+			flush()
+			line = string(runes[max:])
+			lineLen -= max
+		}
+		current.WriteString(line)
+		currentLen += lineLen
+	}
+	flush()
+
+	return chunks
+}
@@ -0,0 +1,280 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/akme/gh-stars-watcher/internal/config"
+	"github.com/akme/gh-stars-watcher/internal/monitor"
+	"github.com/akme/gh-stars-watcher/internal/storage"
+	"github.com/akme/gh-stars-watcher/internal/templates"
+)
+
+// manyStarsPayload returns a payload for username with count new stars and long descriptions
+func manyStarsPayload(username string, count int) *Payload {
+	repos := make([]storage.Repository, count)
+	for i := range repos {
+		repos[i] = storage.Repository{
+			FullName:    fmt.Sprintf("owner/repo-%d", i),
+			Description: strings.Repeat("A long description. ", 20),
+			URL:         fmt.Sprintf("https://github.com/owner/repo-%d", i),
+			Language:    "Go",
+			StarCount:   1234,
+		}
+	}
+	return &Payload{
+		Username: username,
+		Changes:  &monitor.RepositoryChanges{NewStars: repos, TotalChanges: count},
+	}
+}
+
+func TestSlack_MessagesSplitAtBlockLimit(t *testing.T) {
+	notifier := NewSlackNotifier(config.ChatConfig{WebhookURL: "https://hooks.slack.com/services/x"})
+	messages, err := notifier.Messages([]*Payload{manyStarsPayload("alice", 120), manyStarsPayload("bob", 3)})
+	if err != nil {
+		t.Fatalf("Messages failed: %v", err)
+	}
+	if len(messages) != 3 {
+		t.Fatalf("got %d messages, want 3", len(messages))
+	}
+
+	sections := 0
+	for i, data := range messages {
+		var message slackMessage
+		if err := json.Unmarshal(data, &message); err != nil {
+			t.Fatalf("message %d is not valid JSON: %v", i, err)
+		}
+		if len(message.Blocks) > slackMaxBlocks {
+			t.Errorf("message %d has %d blocks, limit is %d", i, len(message.Blocks), slackMaxBlocks)
+		}
+		if message.Blocks[0].Type != "header" {
+			t.Errorf("message %d starts with %q, want header", i, message.Blocks[0].Type)
+		}
+		if i > 0 && !strings.Contains(message.Blocks[0].Text.Text, "alice (continued)") {
+			t.Errorf("message %d header = %q, want continued header", i, message.Blocks[0].Text.Text)
+		}
+		if !strings.Contains(message.Text, fmt.Sprintf("(%d/3)", i+1)) {
+			t.Errorf("message %d fallback text = %q, want part number", i, message.Text)
+		}
+		for _, block := range message.Blocks {
+			if block.Type == "section" {
+				sections++
+				if utf8.RuneCountInString(block.Text.Text) > slackMaxText {
+					t.Errorf("section text exceeds %d characters", slackMaxText)
+				}
+			}
+		}
+	}
+	if sections != 123 {
+		t.Errorf("rendered %d repository sections, want 123", sections)
+	}
+}
+
+func TestSlack_RendersRepositoryDetails(t *testing.T) {
+	payload := &Payload{
+		Username: "octocat",
+		Changes: &monitor.RepositoryChanges{
+			NewStars: []storage.Repository{{
+				FullName: "golang/go", Description: "The Go <language>", URL: "https://github.com/golang/go",
+				Language: "Go", StarCount: 120000,
+			}},
+			Unstars:      []storage.Repository{{FullName: "old/repo"}},
+			TotalChanges: 2,
+		},
+	}
+
+	messages, err := NewSlackNotifier(config.ChatConfig{}).Messages([]*Payload{payload})
+	if err != nil {
+		t.Fatalf("Messages failed: %v", err)
+	}
+	if len(messages) != 1 {
+		t.Fatalf("got %d messages, want 1", len(messages))
+	}
+
+	body := string(messages[0])
+	for _, want := range []string{"<https://github.com/golang/go|golang/go>", "The Go &lt;language&gt;", "Go · ★ 120k", "Unstarred: old/repo", "starred 1, unstarred 1"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("message does not contain %q: %s", want, body)
+		}
+	}
+}
+
+func TestDiscord_MessagesRespectLimits(t *testing.T) {
+	notifier := NewDiscordNotifier(config.ChatConfig{WebhookURL: "https://discord.com/api/webhooks/x"})
+	payloads := []*Payload{manyStarsPayload("alice", 60)}
+	for i := 0; i < 12; i++ {
+		payloads = append(payloads, manyStarsPayload(fmt.Sprintf("user%d", i), 1))
+	}
+
+	messages, err := notifier.Messages(payloads)
+	if err != nil {
+		t.Fatalf("Messages failed: %v", err)
+	}
+	if len(messages) < 2 {
+		t.Fatalf("got %d messages, want the content split", len(messages))
+	}
+
+	fields := 0
+	for i, data := range messages {
+		var message discordMessage
+		if err := json.Unmarshal(data, &message); err != nil {
+			t.Fatalf("message %d is not valid JSON: %v", i, err)
+		}
+		if len(message.Embeds) > discordMaxEmbeds {
+			t.Errorf("message %d has %d embeds, limit is %d", i, len(message.Embeds), discordMaxEmbeds)
+		}
+		size := 0
+		for _, embed := range message.Embeds {
+			size += embed.size()
+			if len(embed.Fields) > discordMaxFields {
+				t.Errorf("embed %q has %d fields, limit is %d", embed.Title, len(embed.Fields), discordMaxFields)
+			}
+			for _, field := range embed.Fields {
+				if utf8.RuneCountInString(field.Value) > discordMaxFieldValue {
+					t.Errorf("field %q exceeds %d characters", field.Name, discordMaxFieldValue)
+				}
+			}
+			fields += len(embed.Fields)
+		}
+		if size > discordMaxChars {
+			t.Errorf("message %d has %d embed characters, limit is %d", i, size, discordMaxChars)
+		}
+	}
+	if fields != 72 {
+		t.Errorf("rendered %d repository fields, want 72", fields)
+	}
+}
+
+func TestDispatch_BatchNotifierGroupsUsers(t *testing.T) {
+	var mu sync.Mutex
+	var bodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		bodies = append(bodies, string(body))
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	status := NewStatusStore(filepath.Join(t.TempDir(), "notifications.json"))
+	dispatcher := NewDispatcher(testRetryConfig(), status)
+	dispatcher.SetLogger(func(format string, args ...interface{}) {})
+	dispatcher.AddBatch(NewSlackNotifier(config.ChatConfig{WebhookURL: server.URL}))
+
+	deliveries := dispatcher.Dispatch(context.Background(), []*monitor.MonitorResult{testResult("alice"), testResult("bob")})
+
+	if len(bodies) != 1 {
+		t.Fatalf("webhook called %d times, want a single grouped message", len(bodies))
+	}
+	if !strings.Contains(bodies[0], "alice") || !strings.Contains(bodies[0], "bob") {
+		t.Errorf("message does not mention both users: %s", bodies[0])
+	}
+	if len(deliveries) != 2 || !deliveries[0].Delivered || !deliveries[1].Delivered {
+		t.Errorf("deliveries = %+v, want one successful delivery per user", deliveries)
+	}
+
+	recorded, err := status.List()
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(recorded) != 2 {
+		t.Errorf("recorded %d deliveries, want 2", len(recorded))
+	}
+}
+
+func TestDispatch_RoutesUsersToTheirSinks(t *testing.T) {
+	var mu sync.Mutex
+	bodies := make(map[string][]string)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		bodies[r.URL.Path] = append(bodies[r.URL.Path], string(body))
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	dispatcher, _ := newTestDispatcher(t, config.WebhookConfig{URL: server.URL + "/webhook"})
+	dispatcher.AddBatch(NewSlackNotifier(config.ChatConfig{WebhookURL: server.URL + "/slack"}))
+	dispatcher.SetRoutes(map[string][]string{
+		"alice": {"slack"},
+		"bob":   {"webhook"},
+	})
+
+	deliveries := dispatcher.Dispatch(context.Background(), []*monitor.MonitorResult{testResult("Alice"), testResult("bob"), testResult("carol")})
+
+	if len(deliveries) != 4 {
+		t.Errorf("got %d deliveries, want alice to slack, bob to the webhook and carol to both", len(deliveries))
+	}
+	if webhook := bodies["/webhook"]; len(webhook) != 2 || !strings.Contains(webhook[0], `"bob"`) || !strings.Contains(webhook[1], `"carol"`) {
+		t.Errorf("webhook received %v, want bob and carol", webhook)
+	}
+	slack := bodies["/slack"]
+	if len(slack) != 1 || !strings.Contains(slack[0], "Alice") || !strings.Contains(slack[0], "carol") || strings.Contains(slack[0], "bob") {
+		t.Errorf("slack received %v, want one message for Alice and carol", slack)
+	}
+}
+
+func TestChat_TemplatesReplaceBuiltInLayout(t *testing.T) {
+	tmpl, err := templates.Parse("chat.tmpl", `{{.Username}}:{{range .Changes.NewStars}} {{markdown .FullName}} ({{humanize .StarCount}}){{end}}`, false)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	payload := &Payload{
+		Username: "alice",
+		Changes: &monitor.RepositoryChanges{
+			NewStars:     []storage.Repository{{FullName: "my_org/repo", StarCount: 1234}},
+			TotalChanges: 1,
+		},
+	}
+	want := `alice: my\_org/repo (1.2k)`
+
+	slack := NewSlackNotifier(config.ChatConfig{})
+	slack.SetTemplate(tmpl)
+	messages, err := slack.Messages([]*Payload{payload})
+	if err != nil {
+		t.Fatalf("Slack Messages failed: %v", err)
+	}
+	var slackMsg slackMessage
+	json.Unmarshal(messages[0], &slackMsg)
+	if len(slackMsg.Blocks) != 1 || slackMsg.Blocks[0].Text.Text != want {
+		t.Errorf("Slack blocks = %+v, want one section %q", slackMsg.Blocks, want)
+	}
+
+	discord := NewDiscordNotifier(config.ChatConfig{})
+	discord.SetTemplate(tmpl)
+	messages, err = discord.Messages([]*Payload{payload, manyStarsPayload("bob", 0)})
+	if err != nil {
+		t.Fatalf("Discord Messages failed: %v", err)
+	}
+	var discordMsg discordMessage
+	json.Unmarshal(messages[0], &discordMsg)
+	if len(discordMsg.Embeds) != 1 || discordMsg.Embeds[0].Description != want {
+		t.Errorf("Discord embeds = %+v, want one embed %q", discordMsg.Embeds, want)
+	}
+}
+
+func TestSplitText_PrefersLineBreaks(t *testing.T) {
+	text := strings.Repeat("0123456789\n", 5) + strings.Repeat("x", 25)
+	chunks := splitText(text, 22)
+
+	for i, chunk := range chunks {
+		if utf8.RuneCountInString(chunk) > 22 {
+			t.Errorf("chunk %d has %d characters, limit is 22", i, utf8.RuneCountInString(chunk))
+		}
+	}
+	if chunks[0] != "0123456789\n0123456789" {
+		t.Errorf("first chunk = %q, want two whole lines", chunks[0])
+	}
+	if joined := strings.Join(chunks, ""); strings.Count(joined, "x") != 25 {
+		t.Errorf("chunks %q lost characters of the long line", chunks)
+	}
+}
@@ -0,0 +1,329 @@
+package notify
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/akme/gh-stars-watcher/internal/storage"
+)
+
+// Digest collects the star changes of all users over one digest period
+type Digest struct {
+	ID          string                 `json:"id"`
+	PeriodStart time.Time              `json:"period_start"`
+	Users       map[string]*DigestUser `json:"users"`
+}
+
+// DigestUser holds the accumulated changes of one user
+type DigestUser struct {
+	Username string               `json:"username"`
+	NewStars []storage.Repository `json:"new_stars"`
+	ReStars  []storage.Repository `json:"re_stars"`
+	Unstars  []storage.Repository `json:"unstars"`
+}
+
+// Changes returns the number of accumulated changes of the user
+func (u *DigestUser) Changes() int {
+	return len(u.NewStars) + len(u.ReStars) + len(u.Unstars)
+}
+
+// Changes returns the number of accumulated changes across all users
+func (d *Digest) Changes() int {
+	total := 0
+	for _, user := range d.Users {
+		total += user.Changes()
+	}
+	return total
+}
+
+// SortedUsers returns the users with changes ordered by username
+func (d *Digest) SortedUsers() []*DigestUser {
+	users := make([]*DigestUser, 0, len(d.Users))
+	for _, user := range d.Users {
+		if user.Changes() > 0 {
+			users = append(users, user)
+		}
+	}
+	sort.Slice(users, func(i, j int) bool {
+		return users[i].Username < users[j].Username
+	})
+	return users
+}
+
+// add merges the changes of a payload. A repository starred and unstarred again within
+// the same period cancels out; one unstarred and starred again is reported as re-starred.
+func (d *Digest) add(payload *Payload) {
+	user, ok := d.Users[payload.Username]
+	if !ok {
+		user = &DigestUser{Username: payload.Username}
+		d.Users[payload.Username] = user
+	}
+
+	for _, repo := range payload.Changes.NewStars {
+		if removed := removeRepo(&user.Unstars, repo.FullName); removed {
+			user.ReStars = upsertRepo(user.ReStars, repo)
+		} else {
+			user.NewStars = upsertRepo(user.NewStars, repo)
+		}
+	}
+	for _, repo := range payload.Changes.ReStars {
+		removeRepo(&user.Unstars, repo.FullName)
+		user.ReStars = upsertRepo(user.ReStars, repo)
+	}
+	for _, repo := range payload.Changes.Unstars {
+		if removeRepo(&user.NewStars, repo.FullName) {
+			continue
+		}
+		removeRepo(&user.ReStars, repo.FullName)
+		user.Unstars = upsertRepo(user.Unstars, repo)
+	}
+
+	// Repositories renamed since they were collected are listed under their new name
+	for _, renamed := range payload.Changes.Renamed {
+		for _, repos := range []*[]storage.Repository{&user.NewStars, &user.ReStars, &user.Unstars} {
+			if removeRepo(repos, renamed.PreviousFullName) {
+				*repos = upsertRepo(*repos, renamed.Repository)
+			}
+		}
+	}
+}
+
+// upsertRepo adds repo to repos or replaces the entry with the same name
+func upsertRepo(repos []storage.Repository, repo storage.Repository) []storage.Repository {
+	for i := range repos {
+		if repos[i].FullName == repo.FullName {
+			repos[i] = repo
+			return repos
+		}
+	}
+	return append(repos, repo)
+}
+
+// removeRepo removes the repository named fullName and reports whether it was present
+func removeRepo(repos *[]storage.Repository, fullName string) bool {
+	for i := range *repos {
+		if (*repos)[i].FullName == fullName {
+			*repos = append((*repos)[:i], (*repos)[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// digestSealName is authenticated with a sealed digest, so it cannot pass for a user's state
+const digestSealName = "email digest"
+
+// sealedDigest is the file form of a digest sealed with the state cipher
+type sealedDigest struct {
+	Encrypted []byte `json:"encrypted"`
+}
+
+// DigestStore accumulates changes between digests in a JSON file in the state directory.
+// The digest lists repository names, so it is sealed like the state when encryption is set.
+type DigestStore struct {
+	mu      sync.Mutex
+	path    string
+	period  time.Duration
+	source  storage.CipherSource
+	encrypt bool
+
+	cipherLoaded bool
+	cipher       *storage.StateCipher
+}
+
+// NewDigestStore creates a digest store backed by path that is due every period
+func NewDigestStore(path string, period time.Duration) *DigestStore {
+	return &DigestStore{path: path, period: period}
+}
+
+// SetEncryption seals the pending digest with the cipher from source when encrypt is set.
+// A sealed digest is opened whenever source has its key, so encryption can be turned off again.
+func (s *DigestStore) SetEncryption(source storage.CipherSource, encrypt bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.source, s.encrypt = source, encrypt
+	s.cipherLoaded, s.cipher = false, nil
+}
+
+// Rekey seals the pending digest with the first key of next when it is sealed or
+// encryption is set. next must also hold the key that sealed it so far.
+func (s *DigestStore) Rekey(next *storage.StateCipher) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	digest, sealed, err := s.read(func() (*storage.StateCipher, error) { return next, nil })
+	if err != nil || digest == nil || (!sealed && !s.encrypt) {
+		return err
+	}
+	return s.write(digest, next)
+}
+
+// DigestPeriod returns the period of a digest setting (daily or weekly)
+func DigestPeriod(name string) (time.Duration, error) {
+	switch name {
+	case "daily":
+		return 24 * time.Hour, nil
+	case "weekly":
+		return 7 * 24 * time.Hour, nil
+	default:
+		return 0, fmt.Errorf("unknown digest period %q", name)
+	}
+}
+
+// Add merges payloads into the pending digest. The first call starts the first period.
+func (s *DigestStore) Add(payloads []*Payload, now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	digest, err := s.load(now)
+	if err != nil {
+		return err
+	}
+	for _, payload := range payloads {
+		if payload.Changes != nil {
+			digest.add(payload)
+		}
+	}
+	return s.save(digest)
+}
+
+// Pending returns the pending digest and the time it is due
+func (s *DigestStore) Pending(now time.Time) (*Digest, time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	digest, err := s.load(now)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	return digest, digest.PeriodStart.Add(s.period), nil
+}
+
+// Reset clears the delivered digest and starts a new period at now
+func (s *DigestStore) Reset(now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.save(newDigest(now))
+}
+
+// load reads the pending digest; a missing file starts a new period at now
+func (s *DigestStore) load(now time.Time) (*Digest, error) {
+	digest, _, err := s.read(s.getCipher)
+	if digest == nil && err == nil {
+		digest = newDigest(now)
+	}
+	return digest, err
+}
+
+// read reads the pending digest, opening it with the cipher from source when it is sealed.
+// It reports whether the digest was sealed; a missing file yields no digest.
+func (s *DigestStore) read(source storage.CipherSource) (*Digest, bool, error) {
+	data, err := os.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to read pending digest: %v", err)
+	}
+
+	var sealed sealedDigest
+	if err := json.Unmarshal(data, &sealed); err != nil {
+		return nil, false, fmt.Errorf("failed to parse pending digest %s: %v", s.path, err)
+	}
+	if len(sealed.Encrypted) > 0 {
+		c, err := source()
+		if err != nil {
+			return nil, true, err
+		}
+		if data, err = c.Open(digestSealName, s.path, sealed.Encrypted); err != nil {
+			return nil, true, err
+		}
+	}
+
+	digest := &Digest{}
+	if err := json.Unmarshal(data, digest); err != nil {
+		return nil, false, fmt.Errorf("failed to parse pending digest %s: %v", s.path, err)
+	}
+	if digest.Users == nil {
+		digest.Users = make(map[string]*DigestUser)
+	}
+	return digest, len(sealed.Encrypted) > 0, nil
+}
+
+// save writes the pending digest, sealed when encryption is set
+func (s *DigestStore) save(digest *Digest) error {
+	var c *storage.StateCipher
+	if s.encrypt {
+		var err error
+		if c, err = s.getCipher(); err != nil {
+			return err
+		}
+		if c == nil {
+			return fmt.Errorf("state encryption is enabled but no state key is configured; run \"star-watcher state rekey\" to create one")
+		}
+	}
+	return s.write(digest, c)
+}
+
+// write writes the digest atomically, sealed with c unless it is nil
+func (s *DigestStore) write(digest *Digest, c *storage.StateCipher) error {
+	data, err := json.MarshalIndent(digest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode pending digest: %v", err)
+	}
+	if c != nil {
+		sealed, err := c.Seal(digestSealName, data)
+		if err != nil {
+			return fmt.Errorf("failed to seal pending digest: %v", err)
+		}
+		if data, err = json.MarshalIndent(sealedDigest{Encrypted: sealed}, "", "  "); err != nil {
+			return fmt.Errorf("failed to encode pending digest: %v", err)
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return fmt.Errorf("failed to create digest directory: %v", err)
+	}
+
+	tempFile := s.path + ".tmp"
+	if err := os.WriteFile(tempFile, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write pending digest: %v", err)
+	}
+	if err := os.Rename(tempFile, s.path); err != nil {
+		os.Remove(tempFile)
+		return fmt.Errorf("failed to rename pending digest file: %v", err)
+	}
+
+	return nil
+}
+
+// getCipher returns the cipher from the source, requesting it once
+func (s *DigestStore) getCipher() (*storage.StateCipher, error) {
+	if s.source == nil {
+		return nil, nil
+	}
+	if !s.cipherLoaded {
+		c, err := s.source()
+		if err != nil {
+			return nil, err
+		}
+		s.cipher, s.cipherLoaded = c, true
+	}
+	return s.cipher, nil
+}
+
+// newDigest returns an empty digest whose period starts at now
+func newDigest(now time.Time) *Digest {
+	return &Digest{
+		ID:          newDeliveryID(),
+		PeriodStart: now,
+		Users:       make(map[string]*DigestUser),
+	}
+}
@@ -0,0 +1,206 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/akme/gh-stars-watcher/internal/config"
+	"github.com/akme/gh-stars-watcher/internal/storage"
+	"github.com/akme/gh-stars-watcher/internal/templates"
+)
+
+// Discord webhook limits
+const (
+	discordMaxEmbeds     = 10   // Embeds per message
+	discordMaxChars      = 6000 // Characters across all embeds of a message
+	discordMaxFields     = 25   // Fields per embed
+	discordMaxTitle      = 256  // Characters in an embed title or field name
+	discordMaxFieldValue = 1024 // Characters in a field value
+	discordMaxDesc       = 4096 // Characters in an embed description
+	discordMaxContent    = 2000 // Characters in the message content
+)
+
+// discordColor is the embed accent color (star yellow)
+const discordColor = 0xF1C40F
+
+// DiscordNotifier posts embed messages to a Discord webhook
+type DiscordNotifier struct {
+	webhookURL string
+	client     *http.Client
+	template   *templates.Template
+}
+
+// discordMessage is the body of a webhook execution request
+type discordMessage struct {
+	Username string         `json:"username"`
+	Content  string         `json:"content,omitempty"`
+	Embeds   []discordEmbed `json:"embeds"`
+}
+
+// discordEmbed is one rich embed of a message
+type discordEmbed struct {
+	Title       string         `json:"title,omitempty"`
+	Description string         `json:"description,omitempty"`
+	URL         string         `json:"url,omitempty"`
+	Color       int            `json:"color"`
+	Fields      []discordField `json:"fields,omitempty"`
+}
+
+// discordField is a name/value pair inside an embed
+type discordField struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Inline bool   `json:"inline"`
+}
+
+// size returns the characters of the embed counted against the message limit
+func (e discordEmbed) size() int {
+	size := utf8.RuneCountInString(e.Title) + utf8.RuneCountInString(e.Description)
+	for _, field := range e.Fields {
+		size += field.size()
+	}
+	return size
+}
+
+// size returns the characters of the field counted against the message limit
+func (f discordField) size() int {
+	return utf8.RuneCountInString(f.Name) + utf8.RuneCountInString(f.Value)
+}
+
+// NewDiscordNotifier creates a Discord notifier from its configuration
+func NewDiscordNotifier(cfg config.ChatConfig) *DiscordNotifier {
+	return &DiscordNotifier{
+		webhookURL: cfg.WebhookURL,
+		client:     &http.Client{Timeout: cfg.Timeout},
+	}
+}
+
+// Name returns the sink name used in delivery records
+func (d *DiscordNotifier) Name() string {
+	return "discord"
+}
+
+// SetTemplate renders each user's changes with tmpl as embed descriptions instead of the
+// built-in layout
+func (d *DiscordNotifier) SetTemplate(tmpl *templates.Template) {
+	d.template = tmpl
+}
+
+// Messages renders one embed per user, continuing long users in further embeds, and packs
+// the embeds into as few messages as the embed and character limits allow
+func (d *DiscordNotifier) Messages(payloads []*Payload) ([][]byte, error) {
+	var messages [][]discordEmbed
+	var current []discordEmbed
+	currentSize := 0
+	var users []string
+
+	addEmbed := func(embed discordEmbed) {
+		if len(current) == discordMaxEmbeds || (len(current) > 0 && currentSize+embed.size() > discordMaxChars) {
+			messages = append(messages, current)
+			current, currentSize = nil, 0
+		}
+		current = append(current, embed)
+		currentSize += embed.size()
+	}
+
+	for _, payload := range payloads {
+		if !chatChanges(payload) {
+			continue
+		}
+		users = append(users, payload.Username)
+
+		if d.template != nil {
+			text, err := d.template.Render(payload.Result())
+			if err != nil {
+				return nil, err
+			}
+			for _, chunk := range splitText(text, discordMaxDesc) {
+				addEmbed(discordEmbed{Description: chunk, Color: discordColor})
+			}
+			continue
+		}
+
+		title := templates.Truncate(fmt.Sprintf("⭐ %s %s", payload.Username, chatSummary(payload)), discordMaxTitle)
+		embed := discordEmbed{Title: title, URL: "https://github.com/" + payload.Username, Color: discordColor}
+
+		for _, field := range discordUserFields(payload) {
+			if len(embed.Fields) == discordMaxFields || embed.size()+field.size() > discordMaxChars {
+				addEmbed(embed)
+				embed = discordEmbed{
+					Title: templates.Truncate(payload.Username+" (continued)", discordMaxTitle),
+					URL:   embed.URL,
+					Color: discordColor,
+				}
+			}
+			embed.Fields = append(embed.Fields, field)
+		}
+		addEmbed(embed)
+	}
+
+	if len(current) > 0 {
+		messages = append(messages, current)
+	}
+
+	content := "Star changes for " + strings.Join(users, ", ")
+	encoded := make([][]byte, len(messages))
+	for i, embeds := range messages {
+		text := content
+		if len(messages) > 1 {
+			text = fmt.Sprintf("%s (%d/%d)", content, i+1, len(messages))
+		}
+
+		data, err := marshalJSON(discordMessage{
+			Username: "star-watcher",
+			Content:  templates.Truncate(text, discordMaxContent),
+			Embeds:   embeds,
+		})
+		if err != nil {
+			return nil, err
+		}
+		encoded[i] = data
+	}
+
+	return encoded, nil
+}
+
+// Send posts one message to the webhook
+func (d *DiscordNotifier) Send(ctx context.Context, message []byte) error {
+	return postJSON(ctx, d.client, d.webhookURL, message)
+}
+
+// discordUserFields renders the changes of one user as embed fields
+func discordUserFields(payload *Payload) []discordField {
+	var fields []discordField
+
+	for _, repo := range payload.Changes.NewStars {
+		fields = append(fields, discordRepoField(repo, ""))
+	}
+	for _, repo := range payload.Changes.ReStars {
+		fields = append(fields, discordRepoField(repo, " (re-starred)"))
+	}
+	for _, names := range repoNames(payload.Changes.Unstars, discordMaxFieldValue) {
+		fields = append(fields, discordField{Name: "Unstarred", Value: names})
+	}
+	for _, names := range renamedNames(payload.Changes.Renamed, discordMaxFieldValue) {
+		fields = append(fields, discordField{Name: "Renamed", Value: names})
+	}
+
+	return fields
+}
+
+// discordRepoField renders a repository with its description, details and link
+func discordRepoField(repo storage.Repository, suffix string) discordField {
+	var lines []string
+	if repo.Description != "" {
+		lines = append(lines, templates.Truncate(repo.Description, maxDescriptionLength))
+	}
+	lines = append(lines, fmt.Sprintf("%s · [View on GitHub](%s)", repoDetails(repo), repo.URL))
+
+	return discordField{
+		Name:  templates.Truncate(repo.FullName+suffix, discordMaxTitle),
+		Value: templates.Truncate(strings.Join(lines, "\n"), discordMaxFieldValue),
+	}
+}
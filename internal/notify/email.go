@@ -0,0 +1,273 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	htmltemplate "html/template"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+	texttemplate "text/template"
+	"time"
+
+	"github.com/akme/gh-stars-watcher/internal/config"
+	"github.com/akme/gh-stars-watcher/internal/monitor"
+)
+
+// DigestSender delivers an accumulated digest
+type DigestSender interface {
+	// Name identifies the sink in logs and delivery records
+	Name() string
+	// SendDigest delivers the digest covering the period up to end
+	SendDigest(ctx context.Context, digest *Digest, end time.Time) error
+}
+
+// EmailNotifier sends digests as multipart HTML and plain-text email over SMTP
+type EmailNotifier struct {
+	cfg config.EmailConfig
+}
+
+// NewEmailNotifier creates an email notifier from its configuration
+func NewEmailNotifier(cfg config.EmailConfig) *EmailNotifier {
+	return &EmailNotifier{cfg: cfg}
+}
+
+// Name returns the sink name used in delivery records
+func (e *EmailNotifier) Name() string {
+	return "email"
+}
+
+// SendDigest renders the digest and sends it to every recipient
+func (e *EmailNotifier) SendDigest(ctx context.Context, digest *Digest, end time.Time) error {
+	message, err := e.render(digest, end)
+	if err != nil {
+		return monitor.WrapNonRetryableError(fmt.Errorf("failed to render digest: %v", err))
+	}
+	return e.send(ctx, message)
+}
+
+// send delivers a complete message using the configured security and authentication
+func (e *EmailNotifier) send(ctx context.Context, message []byte) error {
+	addr := net.JoinHostPort(e.cfg.Host, strconv.Itoa(e.cfg.Port))
+	dialer := &net.Dialer{Timeout: e.cfg.Timeout}
+
+	var conn net.Conn
+	var err error
+	if e.cfg.Security == "tls" {
+		tlsDialer := &tls.Dialer{NetDialer: dialer, Config: &tls.Config{ServerName: e.cfg.Host}}
+		conn, err = tlsDialer.DialContext(ctx, "tcp", addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return monitor.WrapRetryableError(fmt.Errorf("failed to connect to SMTP server %s: %v", addr, err), false, 0)
+	}
+	conn.SetDeadline(time.Now().Add(e.cfg.Timeout))
+
+	client, err := smtp.NewClient(conn, e.cfg.Host)
+	if err != nil {
+		conn.Close()
+		return smtpError("greeting", err)
+	}
+	defer client.Close()
+
+	if e.cfg.Security == "starttls" {
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			return monitor.WrapNonRetryableError(fmt.Errorf("SMTP server %s does not support STARTTLS", addr))
+		}
+		if err := client.StartTLS(&tls.Config{ServerName: e.cfg.Host}); err != nil {
+			return smtpError("STARTTLS", err)
+		}
+	}
+
+	if e.cfg.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", e.cfg.Username, e.cfg.Password, e.cfg.Host)); err != nil {
+			return smtpError("authentication", err)
+		}
+	}
+
+	if err := client.Mail(e.cfg.From); err != nil {
+		return smtpError("MAIL FROM", err)
+	}
+	for _, recipient := range e.cfg.To {
+		if err := client.Rcpt(recipient); err != nil {
+			return smtpError("RCPT TO "+recipient, err)
+		}
+	}
+
+	writer, err := client.Data()
+	if err != nil {
+		return smtpError("DATA", err)
+	}
+	if _, err := writer.Write(message); err != nil {
+		return smtpError("DATA", err)
+	}
+	if err := writer.Close(); err != nil {
+		return smtpError("DATA", err)
+	}
+
+	return client.Quit()
+}
+
+// smtpError classifies an SMTP failure: 4xx replies and connection problems are
+// retryable, 5xx replies are permanent
+func smtpError(stage string, err error) error {
+	wrapped := fmt.Errorf("SMTP %s failed: %v", stage, err)
+	if protoErr, ok := err.(*textproto.Error); ok && protoErr.Code >= 500 {
+		return monitor.WrapNonRetryableError(wrapped)
+	}
+	return monitor.WrapRetryableError(wrapped, false, 0)
+}
+
+// digestView is the data passed to the digest templates
+type digestView struct {
+	Start    time.Time
+	End      time.Time
+	Users    []*DigestUser
+	NewStars int
+	ReStars  int
+	Unstars  int
+}
+
+// render builds the MIME message of a digest
+func (e *EmailNotifier) render(digest *Digest, end time.Time) ([]byte, error) {
+	view := digestView{Start: digest.PeriodStart, End: end, Users: digest.SortedUsers()}
+	for _, user := range view.Users {
+		view.NewStars += len(user.NewStars)
+		view.ReStars += len(user.ReStars)
+		view.Unstars += len(user.Unstars)
+	}
+
+	var text, html bytes.Buffer
+	if err := digestTextTemplate.Execute(&text, view); err != nil {
+		return nil, err
+	}
+	if err := digestHTMLTemplate.Execute(&html, view); err != nil {
+		return nil, err
+	}
+
+	var body bytes.Buffer
+	parts := multipart.NewWriter(&body)
+	for _, part := range []struct {
+		contentType string
+		content     []byte
+	}{
+		{"text/plain; charset=utf-8", text.Bytes()},
+		{"text/html; charset=utf-8", html.Bytes()},
+	} {
+		writer, err := parts.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		qp := quotedprintable.NewWriter(writer)
+		if _, err := qp.Write(part.content); err != nil {
+			return nil, err
+		}
+		if err := qp.Close(); err != nil {
+			return nil, err
+		}
+	}
+	if err := parts.Close(); err != nil {
+		return nil, err
+	}
+
+	subject := fmt.Sprintf("star-watcher digest: %d new, %d re-starred, %d unstarred (%s - %s)",
+		view.NewStars, view.ReStars, view.Unstars, view.Start.Format("Jan 2"), view.End.Format("Jan 2"))
+
+	var message bytes.Buffer
+	headers := [][2]string{
+		{"From", e.cfg.From},
+		{"To", strings.Join(e.cfg.To, ", ")},
+		{"Subject", mime.QEncoding.Encode("utf-8", subject)},
+		{"Date", end.Format(time.RFC1123Z)},
+		{"Message-ID", fmt.Sprintf("<%s@star-watcher>", digest.ID)},
+		{"MIME-Version", "1.0"},
+		{"Content-Type", "multipart/alternative; boundary=" + parts.Boundary()},
+	}
+	for _, header := range headers {
+		fmt.Fprintf(&message, "%s: %s\r\n", header[0], header[1])
+	}
+	message.WriteString("\r\n")
+	message.Write(body.Bytes())
+
+	return message.Bytes(), nil
+}
+
+var digestTextTemplate = texttemplate.Must(texttemplate.New("digest.txt").Parse(
+	`Star changes from {{.Start.Format "Mon Jan 2 15:04"}} to {{.End.Format "Mon Jan 2 15:04"}}
+{{range .Users}}
+== {{.Username}} ==
+{{- if .NewStars}}
+
+Starred:
+{{- range .NewStars}}
+  * {{.FullName}}{{if .Language}} [{{.Language}}]{{end}} ({{.StarCount}} stars)
+    {{.URL}}
+{{- if .Description}}
+    {{.Description}}
+{{- end}}
+{{- end}}
+{{- end}}
+{{- if .ReStars}}
+
+Re-starred:
+{{- range .ReStars}}
+  * {{.FullName}} - {{.URL}}
+{{- end}}
+{{- end}}
+{{- if .Unstars}}
+
+Unstarred:
+{{- range .Unstars}}
+  * {{.FullName}}
+{{- end}}
+{{- end}}
+{{end}}`))
+
+var digestHTMLTemplate = htmltemplate.Must(htmltemplate.New("digest.html").Parse(
+	`<!DOCTYPE html>
+<html>
+<body style="font-family: -apple-system, Segoe UI, Helvetica, Arial, sans-serif; color: #24292f;">
+<h2>Star changes</h2>
+<p style="color: #57606a;">{{.Start.Format "Mon Jan 2 15:04"}} &ndash; {{.End.Format "Mon Jan 2 15:04"}}:
+{{.NewStars}} new, {{.ReStars}} re-starred, {{.Unstars}} unstarred</p>
+{{range .Users}}
+<h3>{{.Username}}</h3>
+{{- if .NewStars}}
+<ul>
+{{- range .NewStars}}
+<li><a href="{{.URL}}"><strong>{{.FullName}}</strong></a>{{if .Language}} <code>{{.Language}}</code>{{end}} &#9733; {{.StarCount}}
+{{- if .Description}}<br><span style="color: #57606a;">{{.Description}}</span>{{end}}</li>
+{{- end}}
+</ul>
+{{- end}}
+{{- if .ReStars}}
+<p>Re-starred:</p>
+<ul>
+{{- range .ReStars}}
+<li><a href="{{.URL}}">{{.FullName}}</a></li>
+{{- end}}
+</ul>
+{{- end}}
+{{- if .Unstars}}
+<p>Unstarred:</p>
+<ul>
+{{- range .Unstars}}
+<li>{{.FullName}}</li>
+{{- end}}
+</ul>
+{{- end}}
+{{end}}
+</body>
+</html>
+`))
@@ -0,0 +1,337 @@
+package notify
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/akme/gh-stars-watcher/internal/config"
+	"github.com/akme/gh-stars-watcher/internal/monitor"
+	"github.com/akme/gh-stars-watcher/internal/storage"
+)
+
+// fakeSMTPServer is a minimal SMTP stand-in that records received messages
+type fakeSMTPServer struct {
+	listener net.Listener
+	mu       sync.Mutex
+	messages []string
+	auth     []string
+	rcpts    []string
+}
+
+func newFakeSMTPServer(t *testing.T) *fakeSMTPServer {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	server := &fakeSMTPServer{listener: listener}
+	go server.serve()
+	t.Cleanup(func() { listener.Close() })
+	return server
+}
+
+func (s *fakeSMTPServer) port() int {
+	return s.listener.Addr().(*net.TCPAddr).Port
+}
+
+func (s *fakeSMTPServer) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *fakeSMTPServer) handle(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	reply := func(line string) { io.WriteString(conn, line+"\r\n") }
+
+	reply("220 localhost ESMTP fake")
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		command := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+
+		switch command {
+		case "EHLO", "HELO":
+			reply("250-localhost")
+			reply("250 AUTH PLAIN")
+		case "AUTH":
+			fields := strings.Fields(line)
+			decoded, _ := base64.StdEncoding.DecodeString(fields[len(fields)-1])
+			s.mu.Lock()
+			s.auth = append(s.auth, string(decoded))
+			s.mu.Unlock()
+			reply("235 Authentication successful")
+		case "MAIL":
+			reply("250 OK")
+		case "RCPT":
+			s.mu.Lock()
+			s.rcpts = append(s.rcpts, line)
+			s.mu.Unlock()
+			reply("250 OK")
+		case "DATA":
+			reply("354 End data with <CR><LF>.<CR><LF>")
+			var data strings.Builder
+			for {
+				dataLine, err := reader.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if dataLine == ".\r\n" {
+					break
+				}
+				data.WriteString(strings.TrimPrefix(dataLine, "."))
+			}
+			s.mu.Lock()
+			s.messages = append(s.messages, data.String())
+			s.mu.Unlock()
+			reply("250 OK queued")
+		case "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("502 Command not implemented")
+		}
+	}
+}
+
+func (s *fakeSMTPServer) received() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string{}, s.messages...)
+}
+
+func testEmailConfig(server *fakeSMTPServer) config.EmailConfig {
+	return config.EmailConfig{
+		Host:     "127.0.0.1",
+		Port:     server.port(),
+		Security: "none",
+		Username: "watcher",
+		Password: "hunter2",
+		From:     "star-watcher@example.com",
+		To:       []string{"team@example.com", "ops@example.com"},
+		Digest:   "daily",
+		Timeout:  5 * time.Second,
+	}
+}
+
+func TestEmail_DigestBatchesUntilDue(t *testing.T) {
+	server := newFakeSMTPServer(t)
+	store := NewDigestStore(filepath.Join(t.TempDir(), DigestFileName), 24*time.Hour)
+	dispatcher := NewDispatcher(testRetryConfig(), nil)
+	dispatcher.SetLogger(func(format string, args ...interface{}) {})
+	dispatcher.AddDigest(NewEmailNotifier(testEmailConfig(server)), store)
+
+	now := time.Date(2026, 3, 2, 8, 0, 0, 0, time.UTC)
+	dispatcher.now = func() time.Time { return now }
+
+	// Two runs within the period accumulate without sending
+	if deliveries := dispatcher.Dispatch(context.Background(), []*monitor.MonitorResult{testResult("alice")}); len(deliveries) != 0 {
+		t.Fatalf("first run deliveries = %+v, want none before the digest is due", deliveries)
+	}
+	now = now.Add(6 * time.Hour)
+	bob := testResult("bob")
+	bob.Changes.Unstars = []storage.Repository{{FullName: "old/tool"}}
+	bob.Changes.TotalChanges = 2
+	dispatcher.Dispatch(context.Background(), []*monitor.MonitorResult{bob})
+
+	if len(server.received()) != 0 {
+		t.Fatal("email sent before the digest was due")
+	}
+	pending, _, err := store.Pending(now)
+	if err != nil {
+		t.Fatalf("Pending failed: %v", err)
+	}
+	if pending.Changes() != 3 {
+		t.Errorf("pending changes = %d, want 3", pending.Changes())
+	}
+
+	// A run after the period sends a single digest for everyone, even without new changes
+	now = now.Add(19 * time.Hour)
+	deliveries := dispatcher.Dispatch(context.Background(), nil)
+	if len(deliveries) != 2 || !deliveries[0].Delivered || !deliveries[1].Delivered {
+		t.Fatalf("deliveries = %+v, want one delivered digest entry per user", deliveries)
+	}
+
+	messages := server.received()
+	if len(messages) != 1 {
+		t.Fatalf("received %d emails, want 1", len(messages))
+	}
+	server.mu.Lock()
+	if len(server.rcpts) != 2 {
+		t.Errorf("RCPT commands = %v, want 2 recipients", server.rcpts)
+	}
+	if len(server.auth) != 1 || !strings.Contains(server.auth[0], "watcher\x00hunter2") {
+		t.Errorf("AUTH = %q, want PLAIN credentials", server.auth)
+	}
+	server.mu.Unlock()
+
+	text, html := parseDigestEmail(t, messages[0])
+	for _, want := range []string{"alice", "bob", "golang/go", "old/tool", "Unstarred"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("plain-text part does not contain %q:\n%s", want, text)
+		}
+		if !strings.Contains(html, want) {
+			t.Errorf("HTML part does not contain %q:\n%s", want, html)
+		}
+	}
+
+	pending, _, err = store.Pending(now)
+	if err != nil {
+		t.Fatalf("Pending failed: %v", err)
+	}
+	if pending.Changes() != 0 {
+		t.Errorf("pending changes after sending = %d, want 0", pending.Changes())
+	}
+}
+
+func TestEmail_FailedDigestStaysPending(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	port := listener.Addr().(*net.TCPAddr).Port
+	listener.Close() // Nothing listens on port any more
+
+	cfg := config.EmailConfig{Host: "127.0.0.1", Port: port, Security: "none", From: "a@example.com",
+		To: []string{"b@example.com"}, Timeout: time.Second}
+	store := NewDigestStore(filepath.Join(t.TempDir(), DigestFileName), time.Hour)
+	retryCfg := testRetryConfig()
+	retryCfg.MaxRetries = 1
+	dispatcher := NewDispatcher(retryCfg, nil)
+	dispatcher.SetLogger(func(format string, args ...interface{}) {})
+	dispatcher.AddDigest(NewEmailNotifier(cfg), store)
+
+	now := time.Now()
+	dispatcher.now = func() time.Time { return now }
+	dispatcher.Dispatch(context.Background(), []*monitor.MonitorResult{testResult("alice")})
+
+	now = now.Add(2 * time.Hour)
+	deliveries := dispatcher.Dispatch(context.Background(), nil)
+	if len(deliveries) != 1 || deliveries[0].Delivered || deliveries[0].Attempts != 2 {
+		t.Fatalf("deliveries = %+v, want one failed delivery after 2 attempts", deliveries)
+	}
+
+	pending, _, err := store.Pending(now)
+	if err != nil {
+		t.Fatalf("Pending failed: %v", err)
+	}
+	if pending.Changes() != 1 {
+		t.Errorf("pending changes = %d, want the failed digest kept", pending.Changes())
+	}
+}
+
+func TestDigest_StarAndUnstarWithinPeriodCancelOut(t *testing.T) {
+	digest := newDigest(time.Now())
+	repo := storage.Repository{FullName: "flaky/repo"}
+
+	digest.add(&Payload{Username: "alice", Changes: &monitor.RepositoryChanges{NewStars: []storage.Repository{repo}}})
+	digest.add(&Payload{Username: "alice", Changes: &monitor.RepositoryChanges{Unstars: []storage.Repository{repo}}})
+
+	if digest.Changes() != 0 {
+		t.Errorf("changes = %d, want star and unstar to cancel out", digest.Changes())
+	}
+
+	digest.add(&Payload{Username: "alice", Changes: &monitor.RepositoryChanges{Unstars: []storage.Repository{{FullName: "old/repo"}}}})
+	digest.add(&Payload{Username: "alice", Changes: &monitor.RepositoryChanges{NewStars: []storage.Repository{{FullName: "old/repo"}}}})
+
+	user := digest.Users["alice"]
+	if len(user.ReStars) != 1 || len(user.Unstars) != 0 {
+		t.Errorf("user = %+v, want old/repo reported as re-starred", user)
+	}
+}
+
+// parseDigestEmail returns the decoded plain-text and HTML parts of a digest email
+func parseDigestEmail(t *testing.T, raw string) (string, string) {
+	t.Helper()
+	message, err := mail.ReadMessage(strings.NewReader(raw))
+	if err != nil {
+		t.Fatalf("Invalid email: %v", err)
+	}
+	if subject, _ := new(mime.WordDecoder).DecodeHeader(message.Header.Get("Subject")); !strings.Contains(subject, "star-watcher digest") {
+		t.Errorf("Subject = %q, want digest subject", subject)
+	}
+
+	mediaType, params, err := mime.ParseMediaType(message.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/alternative" {
+		t.Fatalf("Content-Type = %q, want multipart/alternative", message.Header.Get("Content-Type"))
+	}
+
+	parts := map[string]string{}
+	reader := multipart.NewReader(message.Body, params["boundary"])
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Invalid MIME part: %v", err)
+		}
+		body, _ := io.ReadAll(part) // quoted-printable is decoded by the multipart reader
+		contentType, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+		parts[contentType] = string(body)
+	}
+
+	if len(parts) != 2 {
+		t.Fatalf("got %d parts, want text/plain and text/html:\n%s", len(parts), raw)
+	}
+	return parts["text/plain"], parts["text/html"]
+}
+
+func TestDigest_RekeyResealsPendingDigest(t *testing.T) {
+	cipher := func(keys ...[]byte) storage.CipherSource {
+		c, err := storage.NewStateCipher(keys...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return func() (*storage.StateCipher, error) { return c, nil }
+	}
+	oldKey, _ := storage.GenerateStateKey()
+	newKey, _ := storage.GenerateStateKey()
+	path := filepath.Join(t.TempDir(), DigestFileName)
+	now := time.Date(2026, 3, 2, 8, 0, 0, 0, time.UTC)
+
+	store := NewDigestStore(path, 24*time.Hour)
+	store.SetEncryption(cipher(oldKey), true)
+	if err := store.Add([]*Payload{NewPayload(testResult("alice"))}, now); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+
+	// While rekeying the next cipher also holds the old key
+	next, _ := cipher(newKey, oldKey)()
+	if err := store.Rekey(next); err != nil {
+		t.Fatalf("Rekey failed: %v", err)
+	}
+
+	rekeyed := NewDigestStore(path, 24*time.Hour)
+	rekeyed.SetEncryption(cipher(newKey), true)
+	if digest, _, err := rekeyed.Pending(now); err != nil || digest.Users["alice"] == nil {
+		t.Errorf("Pending with the new key = %+v, %v; want the changes of alice", digest, err)
+	}
+
+	stale := NewDigestStore(path, 24*time.Hour)
+	stale.SetEncryption(cipher(oldKey), true)
+	var wrongKey *storage.WrongKeyError
+	if _, _, err := stale.Pending(now); !errors.As(err, &wrongKey) {
+		t.Errorf("Pending with the old key = %v, want *WrongKeyError", err)
+	}
+}
@@ -0,0 +1,236 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/akme/gh-stars-watcher/internal/config"
+	"github.com/akme/gh-stars-watcher/internal/monitor"
+	"github.com/akme/gh-stars-watcher/internal/storage"
+)
+
+// Repository events passed to exec hooks running once per repository
+const (
+	EventNewStar = "new_star"
+	EventReStar  = "re_star"
+	EventUnstar  = "unstar"
+	EventUpdated = "updated"
+	EventRenamed = "renamed"
+)
+
+// RepositoryEvent is the JSON written to the stdin of a per-repository exec hook
+type RepositoryEvent struct {
+	Version    int                `json:"version"`
+	Event      string             `json:"event"`
+	DeliveryID string             `json:"delivery_id"`
+	Username   string             `json:"username"`
+	CheckedAt  time.Time          `json:"checked_at"`
+	Repository storage.Repository `json:"repository"`
+
+	PreviousFullName string `json:"previous_full_name,omitempty"` // Name before a renamed event
+}
+
+// ExecNotifier runs an external command for detected changes. The change is written as
+// JSON to the command's stdin and key fields are exported as STAR_* environment variables.
+type ExecNotifier struct {
+	command       string
+	perRepository bool
+	timeout       time.Duration
+	logger        *slog.Logger
+}
+
+// NewExecNotifier creates an exec hook from its configuration. Output of the command is
+// logged to logger; nil discards it.
+func NewExecNotifier(cfg config.ExecConfig, logger *slog.Logger) *ExecNotifier {
+	if logger == nil {
+		logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	}
+	return &ExecNotifier{
+		command:       cfg.Command,
+		perRepository: cfg.Per == "repository",
+		timeout:       cfg.Timeout,
+		logger:        logger,
+	}
+}
+
+// Name returns the sink name used in delivery records
+func (e *ExecNotifier) Name() string {
+	return "exec"
+}
+
+// Notify runs the command once for the payload, or once per changed repository.
+// Failures are not retried because the command may not be idempotent.
+func (e *ExecNotifier) Notify(ctx context.Context, payload *Payload) error {
+	if !e.perRepository {
+		input, err := json.Marshal(payload)
+		if err != nil {
+			return monitor.WrapNonRetryableError(fmt.Errorf("failed to encode payload: %v", err))
+		}
+		if err := e.run(ctx, input, map[string]string{
+			"STAR_USER":    payload.Username,
+			"STAR_EVENT":   payload.Event,
+			"STAR_CHANGES": strconv.Itoa(payload.Changes.TotalChanges),
+		}); err != nil {
+			return monitor.WrapNonRetryableError(err)
+		}
+		return nil
+	}
+
+	var errs []error
+	for _, event := range repositoryEvents(payload) {
+		repo := event.Repository
+		input, err := json.Marshal(event)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to encode %s: %v", repo.FullName, err))
+			continue
+		}
+
+		env := map[string]string{
+			"STAR_USER":  payload.Username,
+			"STAR_EVENT": event.Event,
+			"STAR_REPO":  repo.FullName,
+			"STAR_URL":   repo.URL,
+		}
+		if event.PreviousFullName != "" {
+			env["STAR_PREVIOUS_REPO"] = event.PreviousFullName
+		}
+		if err := e.run(ctx, input, env); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", repo.FullName, err))
+		}
+	}
+
+	if len(errs) > 0 {
+		return monitor.WrapNonRetryableError(errors.Join(errs...))
+	}
+	return nil
+}
+
+// repositoryEvents returns one event per changed repository of a payload
+func repositoryEvents(payload *Payload) []RepositoryEvent {
+	var events []RepositoryEvent
+	add := func(event string, repo storage.Repository, previousFullName string) {
+		events = append(events, RepositoryEvent{
+			Version:          PayloadVersion,
+			Event:            event,
+			DeliveryID:       payload.DeliveryID,
+			Username:         payload.Username,
+			CheckedAt:        payload.CurrentCheck,
+			Repository:       repo,
+			PreviousFullName: previousFullName,
+		})
+	}
+
+	for _, group := range []struct {
+		event string
+		repos []storage.Repository
+	}{
+		{EventNewStar, payload.Changes.NewStars},
+		{EventReStar, payload.Changes.ReStars},
+		{EventUnstar, payload.Changes.Unstars},
+		{EventUpdated, payload.Changes.Updated},
+	} {
+		for _, repo := range group.repos {
+			add(group.event, repo, "")
+		}
+	}
+	for _, renamed := range payload.Changes.Renamed {
+		add(EventRenamed, renamed.Repository, renamed.PreviousFullName)
+	}
+	return events
+}
+
+// run executes the command with input on stdin and the given extra environment
+func (e *ExecNotifier) run(ctx context.Context, input []byte, env map[string]string) error {
+	ctx, cancel := context.WithTimeout(ctx, e.timeout)
+	defer cancel()
+
+	cmd := shellCommand(ctx, e.command)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Env = os.Environ()
+	for key, value := range env {
+		cmd.Env = append(cmd.Env, key+"="+value)
+	}
+	cmd.WaitDelay = time.Second // Do not wait for children holding stdout/stderr after a kill
+
+	logAttrs := []any{"command", e.command, "user", env["STAR_USER"], "event", env["STAR_EVENT"]}
+	if repo := env["STAR_REPO"]; repo != "" {
+		logAttrs = append(logAttrs, "repo", repo)
+	}
+	stderr := &lineLogger{log: func(line string) {
+		e.logger.Warn("Exec hook stderr", append(logAttrs, "line", line)...)
+	}}
+	stdout := &lineLogger{log: func(line string) {
+		e.logger.Debug("Exec hook stdout", append(logAttrs, "line", line)...)
+	}}
+	cmd.Stderr = stderr
+	cmd.Stdout = stdout
+
+	err := cmd.Run()
+	stderr.Flush()
+	stdout.Flush()
+
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("hook timed out after %s", e.timeout)
+	}
+	if err != nil {
+		return fmt.Errorf("hook failed: %v", err)
+	}
+	return nil
+}
+
+// shellCommand runs command through the platform shell
+func shellCommand(ctx context.Context, command string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.CommandContext(ctx, "cmd", "/C", command)
+	}
+	return exec.CommandContext(ctx, "sh", "-c", command)
+}
+
+// lineLogger is an io.Writer that passes each complete line to log
+type lineLogger struct {
+	mu  sync.Mutex
+	buf []byte
+	log func(line string)
+}
+
+// Write buffers p and logs every complete line
+func (l *lineLogger) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.buf = append(l.buf, p...)
+	for {
+		i := bytes.IndexByte(l.buf, '\n')
+		if i < 0 {
+			break
+		}
+		if line := strings.TrimRight(string(l.buf[:i]), "\r"); line != "" {
+			l.log(line)
+		}
+		l.buf = l.buf[i+1:]
+	}
+	return len(p), nil
+}
+
+// Flush logs a trailing line without newline
+func (l *lineLogger) Flush() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if line := strings.TrimSpace(string(l.buf)); line != "" {
+		l.log(line)
+	}
+	l.buf = nil
+}
@@ -0,0 +1,151 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/akme/gh-stars-watcher/internal/config"
+	"github.com/akme/gh-stars-watcher/internal/monitor"
+	"github.com/akme/gh-stars-watcher/internal/storage"
+)
+
+// syncBuffer is a bytes.Buffer safe for concurrent log writes
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func newExecDispatcher(t *testing.T, cfg config.ExecConfig) (*Dispatcher, *StatusStore) {
+	t.Helper()
+	status := NewStatusStore(filepath.Join(t.TempDir(), StatusFileName))
+	dispatcher := NewDispatcher(testRetryConfig(), status)
+	dispatcher.SetLogger(func(format string, args ...interface{}) {})
+	dispatcher.Add(NewExecNotifier(cfg, nil))
+	return dispatcher, status
+}
+
+func skipWithoutShell(t *testing.T) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("exec hook tests use sh")
+	}
+}
+
+func TestExec_PassesPayloadOnStdinAndEnv(t *testing.T) {
+	skipWithoutShell(t)
+	dir := t.TempDir()
+	command := `cat > "$OUT/stdin.json"; echo "$STAR_USER $STAR_EVENT $STAR_CHANGES" > "$OUT/env.txt"`
+	t.Setenv("OUT", dir)
+
+	dispatcher, _ := newExecDispatcher(t, config.ExecConfig{Command: command, Per: "result", Timeout: 5 * time.Second})
+
+	deliveries := dispatcher.Dispatch(context.Background(), []*monitor.MonitorResult{testResult("alice")})
+	if len(deliveries) != 1 || !deliveries[0].Delivered {
+		t.Fatalf("deliveries = %+v, want one successful delivery", deliveries)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "stdin.json"))
+	if err != nil {
+		t.Fatalf("hook did not write stdin: %v", err)
+	}
+	var payload Payload
+	if err := json.Unmarshal(data, &payload); err != nil {
+		t.Fatalf("stdin is not a payload: %v", err)
+	}
+	if payload.Username != "alice" || len(payload.Changes.NewStars) != 1 {
+		t.Errorf("payload = %+v, want alice with one new star", payload)
+	}
+
+	env, _ := os.ReadFile(filepath.Join(dir, "env.txt"))
+	if got := strings.TrimSpace(string(env)); got != "alice star_changes 1" {
+		t.Errorf("env = %q, want \"alice star_changes 1\"", got)
+	}
+}
+
+func TestExec_RunsOncePerRepository(t *testing.T) {
+	skipWithoutShell(t)
+	dir := t.TempDir()
+	t.Setenv("OUT", dir)
+	command := `echo "$STAR_EVENT $STAR_REPO $STAR_URL${STAR_PREVIOUS_REPO:+ from $STAR_PREVIOUS_REPO}" >> "$OUT/events.txt"`
+
+	result := testResult("alice")
+	result.Changes.Unstars = []storage.Repository{{FullName: "old/tool", URL: "https://github.com/old/tool"}}
+	result.Changes.Renamed = []monitor.RenamedRepository{{
+		PreviousFullName: "octo/cli",
+		Repository:       storage.Repository{FullName: "cli-org/cli", URL: "https://github.com/cli-org/cli"},
+	}}
+	result.Changes.TotalChanges = 3
+
+	notifier := NewExecNotifier(config.ExecConfig{Command: command, Per: "repository", Timeout: 5 * time.Second}, nil)
+	if err := notifier.Notify(context.Background(), NewPayload(result)); err != nil {
+		t.Fatalf("Notify failed: %v", err)
+	}
+
+	data, _ := os.ReadFile(filepath.Join(dir, "events.txt"))
+	want := "new_star golang/go https://github.com/golang/go\nunstar old/tool https://github.com/old/tool\n" +
+		"renamed cli-org/cli https://github.com/cli-org/cli from octo/cli\n"
+	if string(data) != want {
+		t.Errorf("events = %q, want %q", data, want)
+	}
+}
+
+func TestExec_TimeoutAndStderrAreReported(t *testing.T) {
+	skipWithoutShell(t)
+	var logs syncBuffer
+	logger := slog.New(slog.NewTextHandler(&logs, nil))
+
+	notifier := NewExecNotifier(config.ExecConfig{Command: "echo boom >&2; sleep 5", Timeout: 200 * time.Millisecond}, logger)
+	start := time.Now()
+	err := notifier.Notify(context.Background(), NewPayload(testResult("alice")))
+	if err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Fatalf("err = %v, want timeout", err)
+	}
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Errorf("hook ran for %v, want it killed at the timeout", elapsed)
+	}
+	if !strings.Contains(logs.String(), "line=boom") {
+		t.Errorf("logs = %q, want stderr captured", logs.String())
+	}
+}
+
+func TestExec_FailureDoesNotAffectOtherResults(t *testing.T) {
+	skipWithoutShell(t)
+	dispatcher, status := newExecDispatcher(t, config.ExecConfig{Command: `[ "$STAR_USER" != bob ]`, Timeout: 5 * time.Second})
+
+	deliveries := dispatcher.Dispatch(context.Background(), []*monitor.MonitorResult{testResult("alice"), testResult("bob")})
+	if len(deliveries) != 2 {
+		t.Fatalf("deliveries = %+v, want 2", deliveries)
+	}
+	if !deliveries[0].Delivered || deliveries[1].Delivered {
+		t.Errorf("deliveries = %+v, want alice delivered and bob failed", deliveries)
+	}
+	if deliveries[1].Attempts != 1 {
+		t.Errorf("attempts = %d, want failed hooks not retried", deliveries[1].Attempts)
+	}
+
+	recorded, err := status.List()
+	if err != nil || len(recorded) != 2 {
+		t.Errorf("status = %+v (%v), want both deliveries recorded", recorded, err)
+	}
+}
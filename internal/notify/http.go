@@ -0,0 +1,78 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/akme/gh-stars-watcher/internal/monitor"
+)
+
+// marshalJSON encodes v without escaping <, > and &, which chat markup relies on
+func marshalJSON(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimRight(buf.Bytes(), "\n"), nil
+}
+
+// postJSON sends body as a JSON POST to url
+func postJSON(ctx context.Context, client *http.Client, url string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return monitor.WrapNonRetryableError(fmt.Errorf("failed to create request: %v", err))
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "star-watcher")
+
+	return doRequest(client, req)
+}
+
+// doRequest performs req and classifies failures. Server errors, 429 responses and network
+// failures are returned as retryable errors; other non-2xx responses are not retried.
+func doRequest(client *http.Client, req *http.Request) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return monitor.WrapRetryableError(fmt.Errorf("request to %s failed: %v", req.URL.Host, err), false, 0)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	httpErr := &monitor.HTTPError{
+		StatusCode: resp.StatusCode,
+		Status:     http.StatusText(resp.StatusCode),
+		Body:       string(respBody),
+		URL:        req.URL.Scheme + "://" + req.URL.Host, // Chat webhook paths contain their token
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests:
+		return monitor.WrapRetryableError(httpErr, true, retryAfter(resp))
+	case resp.StatusCode >= 500:
+		return monitor.WrapRetryableError(httpErr, false, 0)
+	default:
+		return monitor.WrapNonRetryableError(httpErr)
+	}
+}
+
+// retryAfter parses the Retry-After header given in seconds
+func retryAfter(resp *http.Response) time.Duration {
+	seconds, err := strconv.ParseFloat(resp.Header.Get("Retry-After"), 64)
+	if err != nil || seconds < 0 {
+		return 0
+	}
+	return time.Duration(seconds * float64(time.Second))
+}
@@ -0,0 +1,414 @@
+package notify
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/akme/gh-stars-watcher/internal/config"
+	"github.com/akme/gh-stars-watcher/internal/monitor"
+	"github.com/akme/gh-stars-watcher/internal/storage"
+	"github.com/akme/gh-stars-watcher/internal/templates"
+)
+
+// PayloadVersion is the version of the JSON payload sent to notification sinks.
+// It is incremented whenever a field is removed or changes meaning.
+const PayloadVersion = 1
+
+// EventStarChanges is the event name of a payload describing detected star changes
+const EventStarChanges = "star_changes"
+
+// Notifier delivers the changes detected for one user to an external sink
+type Notifier interface {
+	// Name identifies the sink in logs and delivery records
+	Name() string
+	// Notify delivers a single payload; errors may be wrapped with monitor.WrapRetryableError
+	Notify(ctx context.Context, payload *Payload) error
+}
+
+// BatchNotifier delivers the changes of every user in a run together, split into as many
+// messages as the sink's size limits require
+type BatchNotifier interface {
+	// Name identifies the sink in logs and delivery records
+	Name() string
+	// Messages renders the payloads into encoded messages that each fit the sink's limits
+	Messages(payloads []*Payload) ([][]byte, error)
+	// Send delivers a single encoded message; errors may be wrapped with monitor.WrapRetryableError
+	Send(ctx context.Context, message []byte) error
+}
+
+// Payload is the versioned description of one monitor result sent to notification sinks
+type Payload struct {
+	Version           int                        `json:"version"`
+	Event             string                     `json:"event"`
+	DeliveryID        string                     `json:"delivery_id"`
+	SentAt            time.Time                  `json:"sent_at"`
+	Username          string                     `json:"username"`
+	PreviousCheck     time.Time                  `json:"previous_check"`
+	CurrentCheck      time.Time                  `json:"current_check"`
+	IsFullSync        bool                       `json:"is_full_sync"`
+	TotalRepositories int                        `json:"total_repositories"`
+	Changes           *monitor.RepositoryChanges `json:"changes"`
+}
+
+// NewPayload builds the payload for a monitor result
+func NewPayload(result *monitor.MonitorResult) *Payload {
+	return &Payload{
+		Version:           PayloadVersion,
+		Event:             EventStarChanges,
+		DeliveryID:        newDeliveryID(),
+		SentAt:            time.Now(),
+		Username:          result.Username,
+		PreviousCheck:     result.PreviousCheck,
+		CurrentCheck:      result.CurrentCheck,
+		IsFullSync:        result.IsFullSync,
+		TotalRepositories: result.TotalRepositories,
+		Changes:           result.Changes,
+	}
+}
+
+// Result returns the monitor result described by the payload. Notification templates
+// receive it, so the same template works for the terminal output and the chat sinks.
+func (p *Payload) Result() *monitor.MonitorResult {
+	return &monitor.MonitorResult{
+		Username:          p.Username,
+		PreviousCheck:     p.PreviousCheck,
+		CurrentCheck:      p.CurrentCheck,
+		IsFullSync:        p.IsFullSync,
+		TotalRepositories: p.TotalRepositories,
+		Changes:           p.Changes,
+	}
+}
+
+// ShouldNotify reports whether a result is worth notifying about. The first run of a user
+// only establishes the baseline and results without changes are skipped.
+func ShouldNotify(result *monitor.MonitorResult) bool {
+	return result != nil && !result.IsFirstRun && result.Changes != nil && result.Changes.TotalChanges > 0
+}
+
+// Dispatcher sends monitor results to every configured notifier, retrying failed deliveries
+type Dispatcher struct {
+	notifiers      []Notifier
+	batchNotifiers []BatchNotifier
+	digests        []digestSink
+	retry          *monitor.RetryManager
+	status         *StatusStore
+	routes         map[string][]string // Sinks of users limited to some of them, keyed by lowercase username
+	now            func() time.Time
+}
+
+// SinkNames lists the names of every notification sink, as returned by their Name method
+var SinkNames = []string{"webhook", "slack", "discord", "exec", "email"}
+
+// IsSinkName reports whether name is one of SinkNames
+func IsSinkName(name string) bool {
+	for _, sink := range SinkNames {
+		if name == sink {
+			return true
+		}
+	}
+	return false
+}
+
+// digestSink pairs a digest sender with the store accumulating its changes
+type digestSink struct {
+	sender DigestSender
+	store  *DigestStore
+}
+
+// NewDispatcher creates a dispatcher that retries with cfg and records delivery status in
+// status (nil disables recording)
+func NewDispatcher(cfg *config.RetryConfig, status *StatusStore) *Dispatcher {
+	return &Dispatcher{
+		retry:  monitor.NewRetryManager(cfg),
+		status: status,
+		now:    time.Now,
+	}
+}
+
+// File names used by NewDispatcherFromConfig inside the state directory
+const (
+	StatusFileName = "notifications.json"
+	DigestFileName = "email-digest.json"
+)
+
+// NewDispatcherFromConfig creates a dispatcher with every sink enabled in cfg, keeping
+// delivery status and pending digests in stateDir. Exec hook output is logged to logger.
+// It returns nil when no sink is configured.
+func NewDispatcherFromConfig(cfg *config.Config, stateDir string, logger *slog.Logger) (*Dispatcher, error) {
+	dispatcher := NewDispatcher(&cfg.Retry, NewStatusStore(filepath.Join(stateDir, StatusFileName)))
+
+	if cfg.Notify.Webhook.URL != "" {
+		dispatcher.Add(NewWebhookNotifier(cfg.Notify.Webhook))
+	}
+	if cfg.Notify.Slack.WebhookURL != "" {
+		slack := NewSlackNotifier(cfg.Notify.Slack)
+		if cfg.Notify.Slack.Template != "" {
+			tmpl, err := templates.Load(cfg.Notify.Slack.Template)
+			if err != nil {
+				return nil, fmt.Errorf("notify.slack.template: %v", err)
+			}
+			slack.SetTemplate(tmpl)
+		}
+		dispatcher.AddBatch(slack)
+	}
+	if cfg.Notify.Discord.WebhookURL != "" {
+		discord := NewDiscordNotifier(cfg.Notify.Discord)
+		if cfg.Notify.Discord.Template != "" {
+			tmpl, err := templates.Load(cfg.Notify.Discord.Template)
+			if err != nil {
+				return nil, fmt.Errorf("notify.discord.template: %v", err)
+			}
+			discord.SetTemplate(tmpl)
+		}
+		dispatcher.AddBatch(discord)
+	}
+	if cfg.Notify.Exec.Command != "" {
+		dispatcher.Add(NewExecNotifier(cfg.Notify.Exec, logger))
+	}
+	if cfg.Notify.Email.Host != "" {
+		period, err := DigestPeriod(cfg.Notify.Email.Digest)
+		if err != nil {
+			return nil, err
+		}
+		dispatcher.AddDigest(NewEmailNotifier(cfg.Notify.Email), NewDigestStore(filepath.Join(stateDir, DigestFileName), period))
+	}
+
+	if len(dispatcher.notifiers) == 0 && len(dispatcher.batchNotifiers) == 0 && len(dispatcher.digests) == 0 {
+		return nil, nil
+	}
+	return dispatcher, nil
+}
+
+// Add registers a notifier
+func (d *Dispatcher) Add(notifier Notifier) {
+	d.notifiers = append(d.notifiers, notifier)
+}
+
+// AddBatch registers a notifier that receives all results of a run at once
+func (d *Dispatcher) AddBatch(notifier BatchNotifier) {
+	d.batchNotifiers = append(d.batchNotifiers, notifier)
+}
+
+// AddDigest registers a sender whose changes accumulate in store until the digest is due
+func (d *Dispatcher) AddDigest(sender DigestSender, store *DigestStore) {
+	d.digests = append(d.digests, digestSink{sender: sender, store: store})
+}
+
+// SetRoutes limits the results of some users to the named sinks, keyed by lowercase
+// username. Users without a route are sent to every sink.
+func (d *Dispatcher) SetRoutes(routes map[string][]string) {
+	d.routes = routes
+}
+
+// SetDigestEncryption seals the pending digests with the cipher from source when encrypt
+// is set, like the state
+func (d *Dispatcher) SetDigestEncryption(source storage.CipherSource, encrypt bool) {
+	for _, sink := range d.digests {
+		sink.store.SetEncryption(source, encrypt)
+	}
+}
+
+// routesTo reports whether the results of username go to the sink name
+func (d *Dispatcher) routesTo(username, name string) bool {
+	sinks, ok := d.routes[strings.ToLower(username)]
+	return !ok || slices.Contains(sinks, name)
+}
+
+// routed returns the results that the sink name should receive
+func (d *Dispatcher) routed(name string, results []*monitor.MonitorResult) []*monitor.MonitorResult {
+	var routed []*monitor.MonitorResult
+	for _, result := range results {
+		if d.routesTo(result.Username, name) {
+			routed = append(routed, result)
+		}
+	}
+	return routed
+}
+
+// SetLogger sets the logger used to report retries
+func (d *Dispatcher) SetLogger(logger func(format string, args ...interface{})) {
+	d.retry.SetLogger(logger)
+}
+
+// Dispatch delivers every result that ShouldNotify accepts to the notifiers its user is
+// routed to and returns the outcome of each delivery. Batch notifiers receive all accepted results together and
+// report one delivery per user. Digest senders accumulate the results and only deliver,
+// one delivery per user, once their digest is due.
+func (d *Dispatcher) Dispatch(ctx context.Context, results []*monitor.MonitorResult) []Delivery {
+	var accepted []*monitor.MonitorResult
+	for _, result := range results {
+		if ShouldNotify(result) {
+			accepted = append(accepted, result)
+		}
+	}
+
+	var deliveries []Delivery
+
+	for _, result := range accepted {
+		for _, notifier := range d.notifiers {
+			if !d.routesTo(result.Username, notifier.Name()) {
+				continue
+			}
+			payload := NewPayload(result)
+			attempts := 0
+			err := d.retry.ExecuteWithRetry(ctx, func() error {
+				attempts++
+				return notifier.Notify(ctx, payload)
+			})
+			deliveries = append(deliveries, d.record(notifier.Name(), payload, attempts, err))
+		}
+	}
+
+	for _, notifier := range d.batchNotifiers {
+		routed := d.routed(notifier.Name(), accepted)
+		if len(routed) == 0 {
+			continue
+		}
+		payloads := make([]*Payload, len(routed))
+		for i, result := range routed {
+			payloads[i] = NewPayload(result)
+		}
+
+		attempts, err := d.sendBatch(ctx, notifier, payloads)
+		for _, payload := range payloads {
+			deliveries = append(deliveries, d.record(notifier.Name(), payload, attempts, err))
+		}
+	}
+
+	for _, sink := range d.digests {
+		deliveries = append(deliveries, d.sendDigest(ctx, sink, d.routed(sink.sender.Name(), accepted))...)
+	}
+
+	return deliveries
+}
+
+// sendDigest adds results to the pending digest and delivers it when due. A digest that
+// fails to send stays pending and is retried on the next dispatch.
+func (d *Dispatcher) sendDigest(ctx context.Context, sink digestSink, results []*monitor.MonitorResult) []Delivery {
+	now := d.now()
+	name := sink.sender.Name()
+
+	payloads := make([]*Payload, len(results))
+	for i, result := range results {
+		payloads[i] = NewPayload(result)
+	}
+	if err := sink.store.Add(payloads, now); err != nil {
+		return []Delivery{{Notifier: name, Error: err.Error(), Timestamp: now}}
+	}
+
+	digest, due, err := sink.store.Pending(now)
+	if err != nil {
+		return []Delivery{{Notifier: name, Error: err.Error(), Timestamp: now}}
+	}
+	if now.Before(due) {
+		return nil
+	}
+
+	users := digest.SortedUsers()
+	if len(users) == 0 {
+		// Nothing happened this period; start the next one
+		if err := sink.store.Reset(now); err != nil {
+			return []Delivery{{Notifier: name, Error: err.Error(), Timestamp: now}}
+		}
+		return nil
+	}
+
+	attempts := 0
+	err = d.retry.ExecuteWithRetry(ctx, func() error {
+		attempts++
+		return sink.sender.SendDigest(ctx, digest, now)
+	})
+	if err == nil {
+		if resetErr := sink.store.Reset(now); resetErr != nil {
+			err = fmt.Errorf("digest sent but not cleared: %w", resetErr)
+		}
+	}
+
+	deliveries := make([]Delivery, len(users))
+	for i, user := range users {
+		deliveries[i] = d.record(name, &Payload{
+			Username:   user.Username,
+			DeliveryID: digest.ID,
+			Changes:    &monitor.RepositoryChanges{TotalChanges: user.Changes()},
+		}, attempts, err)
+	}
+	return deliveries
+}
+
+// PendingDigest returns the changes waiting in the first digest sink and when they are due.
+// It returns nil when no digest sender is configured.
+func (d *Dispatcher) PendingDigest() (*Digest, time.Time, error) {
+	if len(d.digests) == 0 {
+		return nil, time.Time{}, nil
+	}
+	return d.digests[0].store.Pending(d.now())
+}
+
+// sendBatch renders payloads and sends every message with retries. It stops at the first
+// message that cannot be delivered and returns the total number of attempts.
+func (d *Dispatcher) sendBatch(ctx context.Context, notifier BatchNotifier, payloads []*Payload) (int, error) {
+	messages, err := notifier.Messages(payloads)
+	if err != nil {
+		return 0, fmt.Errorf("failed to render %s message: %w", notifier.Name(), err)
+	}
+
+	attempts := 0
+	for i, message := range messages {
+		err := d.retry.ExecuteWithRetry(ctx, func() error {
+			attempts++
+			return notifier.Send(ctx, message)
+		})
+		if err != nil {
+			return attempts, fmt.Errorf("message %d of %d: %w", i+1, len(messages), err)
+		}
+	}
+	return attempts, nil
+}
+
+// record builds the delivery of a payload and stores it in the status store
+func (d *Dispatcher) record(notifier string, payload *Payload, attempts int, err error) Delivery {
+	delivery := Delivery{
+		Notifier:   notifier,
+		Username:   payload.Username,
+		DeliveryID: payload.DeliveryID,
+		Changes:    payload.Changes.TotalChanges,
+		Attempts:   attempts,
+		Delivered:  err == nil,
+		Timestamp:  time.Now(),
+	}
+	if err != nil {
+		delivery.Error = err.Error()
+	}
+
+	if d.status != nil {
+		if recordErr := d.status.Record(delivery); recordErr != nil {
+			delivery.Error = joinErrors(delivery.Error, fmt.Sprintf("failed to record delivery status: %v", recordErr))
+		}
+	}
+
+	return delivery
+}
+
+// newDeliveryID returns a random identifier for a delivery
+func newDeliveryID() string {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return fmt.Sprintf("%d", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(buf)
+}
+
+// joinErrors combines two error messages, either of which may be empty
+func joinErrors(a, b string) string {
+	if a == "" {
+		return b
+	}
+	return a + "; " + b
+}
@@ -0,0 +1,196 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/akme/gh-stars-watcher/internal/config"
+	"github.com/akme/gh-stars-watcher/internal/storage"
+	"github.com/akme/gh-stars-watcher/internal/templates"
+)
+
+// Slack Block Kit limits
+const (
+	slackMaxBlocks     = 50   // Blocks per message
+	slackMaxHeaderText = 150  // Characters in a header block
+	slackMaxText       = 3000 // Characters in a section or context text
+)
+
+// SlackNotifier posts Block Kit messages to a Slack incoming webhook
+type SlackNotifier struct {
+	webhookURL string
+	client     *http.Client
+	template   *templates.Template
+}
+
+// slackMessage is the body of an incoming webhook request
+type slackMessage struct {
+	Text   string       `json:"text"` // Fallback for notifications and clients without blocks
+	Blocks []slackBlock `json:"blocks"`
+}
+
+// slackBlock is a header, section, context or divider block
+type slackBlock struct {
+	Type     string      `json:"type"`
+	Text     *slackText  `json:"text,omitempty"`
+	Elements []slackText `json:"elements,omitempty"`
+}
+
+// slackText is a plain_text or mrkdwn text object
+type slackText struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+// NewSlackNotifier creates a Slack notifier from its configuration
+func NewSlackNotifier(cfg config.ChatConfig) *SlackNotifier {
+	return &SlackNotifier{
+		webhookURL: cfg.WebhookURL,
+		client:     &http.Client{Timeout: cfg.Timeout},
+	}
+}
+
+// Name returns the sink name used in delivery records
+func (s *SlackNotifier) Name() string {
+	return "slack"
+}
+
+// SetTemplate renders each user's changes with tmpl as mrkdwn instead of the built-in layout
+func (s *SlackNotifier) SetTemplate(tmpl *templates.Template) {
+	s.template = tmpl
+}
+
+// Messages renders all users into as few messages as the block limit allows. A user whose
+// blocks continue in the next message gets a "(continued)" header there.
+func (s *SlackNotifier) Messages(payloads []*Payload) ([][]byte, error) {
+	var messages [][]slackBlock
+	var current []slackBlock
+	var users []string
+
+	for _, payload := range payloads {
+		if !chatChanges(payload) {
+			continue
+		}
+		users = append(users, payload.Username)
+
+		blocks, err := s.userBlocks(payload)
+		if err != nil {
+			return nil, err
+		}
+		for i, block := range blocks {
+			if len(current) == slackMaxBlocks {
+				messages = append(messages, current)
+				current = nil
+			}
+			if len(current) == 0 && i > 0 && blocks[0].Type == "header" {
+				current = append(current, slackHeader(payload.Username+" (continued)"))
+			}
+			current = append(current, block)
+		}
+
+		if len(current) < slackMaxBlocks {
+			current = append(current, slackBlock{Type: "divider"})
+		}
+	}
+
+	if len(current) > 0 {
+		if current[len(current)-1].Type == "divider" {
+			current = current[:len(current)-1]
+		}
+		messages = append(messages, current)
+	}
+
+	fallback := "Star changes for " + strings.Join(users, ", ")
+	encoded := make([][]byte, len(messages))
+	for i, blocks := range messages {
+		text := fallback
+		if len(messages) > 1 {
+			text = fmt.Sprintf("%s (%d/%d)", fallback, i+1, len(messages))
+		}
+
+		data, err := marshalJSON(slackMessage{Text: text, Blocks: blocks})
+		if err != nil {
+			return nil, err
+		}
+		encoded[i] = data
+	}
+
+	return encoded, nil
+}
+
+// Send posts one message to the incoming webhook
+func (s *SlackNotifier) Send(ctx context.Context, message []byte) error {
+	return postJSON(ctx, s.client, s.webhookURL, message)
+}
+
+// userBlocks renders the changes of one user with the template, if set, or the built-in layout
+func (s *SlackNotifier) userBlocks(payload *Payload) ([]slackBlock, error) {
+	if s.template == nil {
+		return slackUserBlocks(payload), nil
+	}
+
+	text, err := s.template.Render(payload.Result())
+	if err != nil {
+		return nil, err
+	}
+	var blocks []slackBlock
+	for _, chunk := range splitText(text, slackMaxText) {
+		blocks = append(blocks, slackBlock{Type: "section", Text: &slackText{Type: "mrkdwn", Text: chunk}})
+	}
+	return blocks, nil
+}
+
+// slackUserBlocks renders the changes of one user, starting with a header block
+func slackUserBlocks(payload *Payload) []slackBlock {
+	blocks := []slackBlock{slackHeader(fmt.Sprintf("⭐ %s %s", payload.Username, chatSummary(payload)))}
+
+	for _, repo := range payload.Changes.NewStars {
+		blocks = append(blocks, slackRepoSection(repo, ""))
+	}
+	for _, repo := range payload.Changes.ReStars {
+		blocks = append(blocks, slackRepoSection(repo, " (re-starred)"))
+	}
+	for _, names := range repoNames(payload.Changes.Unstars, slackMaxText-len("Unstarred: ")) {
+		blocks = append(blocks, slackBlock{
+			Type:     "context",
+			Elements: []slackText{{Type: "mrkdwn", Text: "Unstarred: " + slackEscape(names)}},
+		})
+	}
+	for _, names := range renamedNames(payload.Changes.Renamed, slackMaxText-len("Renamed: ")) {
+		blocks = append(blocks, slackBlock{
+			Type:     "context",
+			Elements: []slackText{{Type: "mrkdwn", Text: "Renamed: " + slackEscape(names)}},
+		})
+	}
+
+	return blocks
+}
+
+// slackHeader returns a header block
+func slackHeader(text string) slackBlock {
+	return slackBlock{
+		Type: "header",
+		Text: &slackText{Type: "plain_text", Text: templates.Truncate(text, slackMaxHeaderText)},
+	}
+}
+
+// slackRepoSection renders a repository as a section with its link, description and details
+func slackRepoSection(repo storage.Repository, suffix string) slackBlock {
+	lines := []string{fmt.Sprintf("*<%s|%s>*%s", repo.URL, slackEscape(repo.FullName), suffix)}
+	if repo.Description != "" {
+		lines = append(lines, slackEscape(templates.Truncate(repo.Description, maxDescriptionLength)))
+	}
+	lines = append(lines, repoDetails(repo))
+
+	return slackBlock{
+		Type: "section",
+		Text: &slackText{Type: "mrkdwn", Text: templates.Truncate(strings.Join(lines, "\n"), slackMaxText)},
+	}
+}
+
+// slackEscape escapes the characters Slack treats as control sequences in mrkdwn
+func slackEscape(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(s)
+}
@@ -0,0 +1,107 @@
+package notify
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Delivery is the outcome of sending one payload to one notifier
+type Delivery struct {
+	Notifier   string    `json:"notifier"`
+	Username   string    `json:"username"`
+	DeliveryID string    `json:"delivery_id"`
+	Changes    int       `json:"changes"`
+	Attempts   int       `json:"attempts"`
+	Delivered  bool      `json:"delivered"`
+	Error      string    `json:"error,omitempty"`
+	Timestamp  time.Time `json:"timestamp"`
+}
+
+// StatusStore persists the latest delivery of every notifier and user
+type StatusStore struct {
+	mu   sync.Mutex
+	path string
+}
+
+// NewStatusStore creates a status store backed by the JSON file at path
+func NewStatusStore(path string) *StatusStore {
+	return &StatusStore{path: path}
+}
+
+// Record stores a delivery, replacing the previous one of the same notifier and user
+func (s *StatusStore) Record(delivery Delivery) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	statuses, err := s.load()
+	if err != nil {
+		return err
+	}
+	statuses[delivery.Notifier+"/"+delivery.Username] = delivery
+
+	data, err := json.MarshalIndent(statuses, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode delivery status: %v", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return fmt.Errorf("failed to create status directory: %v", err)
+	}
+
+	tempFile := s.path + ".tmp"
+	if err := os.WriteFile(tempFile, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write delivery status: %v", err)
+	}
+	if err := os.Rename(tempFile, s.path); err != nil {
+		os.Remove(tempFile)
+		return fmt.Errorf("failed to rename delivery status file: %v", err)
+	}
+
+	return nil
+}
+
+// List returns the latest deliveries ordered by notifier and username
+func (s *StatusStore) List() ([]Delivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	statuses, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+
+	deliveries := make([]Delivery, 0, len(statuses))
+	for _, delivery := range statuses {
+		deliveries = append(deliveries, delivery)
+	}
+	sort.Slice(deliveries, func(i, j int) bool {
+		if deliveries[i].Notifier != deliveries[j].Notifier {
+			return deliveries[i].Notifier < deliveries[j].Notifier
+		}
+		return deliveries[i].Username < deliveries[j].Username
+	})
+	return deliveries, nil
+}
+
+// load reads the status file; a missing file yields no deliveries
+func (s *StatusStore) load() (map[string]Delivery, error) {
+	statuses := make(map[string]Delivery)
+
+	data, err := os.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return statuses, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read delivery status: %v", err)
+	}
+
+	if err := json.Unmarshal(data, &statuses); err != nil {
+		return nil, fmt.Errorf("failed to parse delivery status %s: %v", s.path, err)
+	}
+	return statuses, nil
+}
@@ -0,0 +1,73 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/akme/gh-stars-watcher/internal/config"
+	"github.com/akme/gh-stars-watcher/internal/monitor"
+)
+
+// Webhook request headers
+const (
+	SignatureHeader = "X-Star-Watcher-Signature" // "sha256=" followed by the hex HMAC of the body
+	EventHeader     = "X-Star-Watcher-Event"
+	DeliveryHeader  = "X-Star-Watcher-Delivery"
+)
+
+// WebhookNotifier POSTs payloads as JSON to an HTTP endpoint
+type WebhookNotifier struct {
+	url    string
+	secret string
+	client *http.Client
+}
+
+// NewWebhookNotifier creates a webhook notifier from its configuration
+func NewWebhookNotifier(cfg config.WebhookConfig) *WebhookNotifier {
+	return &WebhookNotifier{
+		url:    cfg.URL,
+		secret: cfg.Secret,
+		client: &http.Client{Timeout: cfg.Timeout},
+	}
+}
+
+// Name returns the sink name used in delivery records
+func (w *WebhookNotifier) Name() string {
+	return "webhook"
+}
+
+// Notify POSTs the payload with the event, delivery and signature headers
+func (w *WebhookNotifier) Notify(ctx context.Context, payload *Payload) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return monitor.WrapNonRetryableError(fmt.Errorf("failed to encode webhook payload: %v", err))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return monitor.WrapNonRetryableError(fmt.Errorf("failed to create webhook request: %v", err))
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "star-watcher")
+	req.Header.Set(EventHeader, payload.Event)
+	req.Header.Set(DeliveryHeader, payload.DeliveryID)
+	if w.secret != "" {
+		req.Header.Set(SignatureHeader, Sign(w.secret, body))
+	}
+
+	return doRequest(w.client, req)
+}
+
+// Sign returns the signature header value of body: "sha256=" and the hex HMAC-SHA256
+// of the body keyed with secret. Receivers should compare it with hmac.Equal.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,168 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/akme/gh-stars-watcher/internal/config"
+	"github.com/akme/gh-stars-watcher/internal/monitor"
+	"github.com/akme/gh-stars-watcher/internal/storage"
+)
+
+func testResult(username string) *monitor.MonitorResult {
+	return &monitor.MonitorResult{
+		Username:          username,
+		PreviousCheck:     time.Now().Add(-time.Hour),
+		CurrentCheck:      time.Now(),
+		TotalRepositories: 2,
+		Changes: &monitor.RepositoryChanges{
+			NewStars: []storage.Repository{
+				{FullName: "golang/go", URL: "https://github.com/golang/go", Language: "Go"},
+			},
+			TotalChanges: 1,
+		},
+	}
+}
+
+func testRetryConfig() *config.RetryConfig {
+	cfg := config.DefaultConfig().Retry
+	cfg.InitialDelay = time.Millisecond
+	cfg.MaxDelay = 5 * time.Millisecond
+	return &cfg
+}
+
+func newTestDispatcher(t *testing.T, webhook config.WebhookConfig) (*Dispatcher, *StatusStore) {
+	t.Helper()
+	status := NewStatusStore(filepath.Join(t.TempDir(), "notifications.json"))
+	dispatcher := NewDispatcher(testRetryConfig(), status)
+	dispatcher.SetLogger(func(format string, args ...interface{}) {})
+	if webhook.Timeout == 0 {
+		webhook.Timeout = time.Second
+	}
+	dispatcher.Add(NewWebhookNotifier(webhook))
+	return dispatcher, status
+}
+
+func TestWebhook_DeliversSignedPayload(t *testing.T) {
+	const secret = "s3cret"
+	var received *Payload
+	var signature, event string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		signature = r.Header.Get(SignatureHeader)
+		event = r.Header.Get(EventHeader)
+		if signature != Sign(secret, body) {
+			t.Errorf("signature %q does not match body", signature)
+		}
+		received = &Payload{}
+		if err := json.Unmarshal(body, received); err != nil {
+			t.Errorf("invalid payload: %v", err)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	dispatcher, status := newTestDispatcher(t, config.WebhookConfig{URL: server.URL, Secret: secret})
+	deliveries := dispatcher.Dispatch(context.Background(), []*monitor.MonitorResult{testResult("octocat")})
+
+	if len(deliveries) != 1 || !deliveries[0].Delivered || deliveries[0].Attempts != 1 {
+		t.Fatalf("deliveries = %+v, want one successful delivery", deliveries)
+	}
+	if received == nil {
+		t.Fatal("webhook was not called")
+	}
+	if received.Version != PayloadVersion || received.Username != "octocat" || event != EventStarChanges {
+		t.Errorf("payload = %+v (event %q), want version %d for octocat", received, event, PayloadVersion)
+	}
+	if len(received.Changes.NewStars) != 1 || received.Changes.NewStars[0].FullName != "golang/go" {
+		t.Errorf("payload changes = %+v, want golang/go as new star", received.Changes)
+	}
+	if received.DeliveryID != deliveries[0].DeliveryID {
+		t.Errorf("payload delivery ID %q, want %q", received.DeliveryID, deliveries[0].DeliveryID)
+	}
+
+	recorded, err := status.List()
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(recorded) != 1 || !recorded[0].Delivered || recorded[0].Notifier != "webhook" {
+		t.Errorf("recorded status = %+v, want delivered webhook", recorded)
+	}
+}
+
+func TestWebhook_RetriesServerErrors(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) < 3 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	dispatcher, _ := newTestDispatcher(t, config.WebhookConfig{URL: server.URL})
+	deliveries := dispatcher.Dispatch(context.Background(), []*monitor.MonitorResult{testResult("octocat")})
+
+	if len(deliveries) != 1 || !deliveries[0].Delivered {
+		t.Fatalf("deliveries = %+v, want delivered after retries", deliveries)
+	}
+	if deliveries[0].Attempts != 3 {
+		t.Errorf("Attempts = %d, want 3", deliveries[0].Attempts)
+	}
+}
+
+func TestWebhook_DoesNotRetryClientErrors(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	dispatcher, status := newTestDispatcher(t, config.WebhookConfig{URL: server.URL})
+	deliveries := dispatcher.Dispatch(context.Background(), []*monitor.MonitorResult{testResult("octocat")})
+
+	if len(deliveries) != 1 || deliveries[0].Delivered || deliveries[0].Error == "" {
+		t.Fatalf("deliveries = %+v, want one failed delivery", deliveries)
+	}
+	if calls.Load() != 1 {
+		t.Errorf("webhook called %d times, want 1", calls.Load())
+	}
+
+	recorded, err := status.List()
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(recorded) != 1 || recorded[0].Delivered {
+		t.Errorf("recorded status = %+v, want failed delivery", recorded)
+	}
+}
+
+func TestDispatch_SkipsFirstRunsAndUnchangedResults(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+	}))
+	defer server.Close()
+
+	firstRun := testResult("first")
+	firstRun.IsFirstRun = true
+	unchanged := testResult("unchanged")
+	unchanged.Changes = &monitor.RepositoryChanges{}
+
+	dispatcher, _ := newTestDispatcher(t, config.WebhookConfig{URL: server.URL})
+	deliveries := dispatcher.Dispatch(context.Background(), []*monitor.MonitorResult{firstRun, unchanged})
+
+	if len(deliveries) != 0 || calls.Load() != 0 {
+		t.Errorf("got %d deliveries and %d calls, want none", len(deliveries), calls.Load())
+	}
+}
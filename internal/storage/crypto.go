@@ -0,0 +1,155 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+)
+
+// StateKeySize is the size of a state key: AES-256
+const StateKeySize = 32
+
+// sealedVersion starts every sealed value, followed by the key ID and the nonce
+const sealedVersion = 1
+
+// keyIDSize is the size of the key ID stored with every sealed value
+const keyIDSize = 8
+
+// WrongKeyError is returned when encrypted state was sealed with a key that is not
+// configured. Unlike a *StateCorruptionError, the stored data is intact.
+type WrongKeyError struct {
+	Username string
+	KeyID    string // Key the data was sealed with
+}
+
+func (e *WrongKeyError) Error() string {
+	return fmt.Sprintf("state of %s is encrypted with key %s, which is not configured; set STAR_WATCHER_STATE_KEY or restore the key in the keychain", e.Username, e.KeyID)
+}
+
+// GenerateStateKey returns a new random state key
+func GenerateStateKey() ([]byte, error) {
+	key := make([]byte, StateKeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("failed to generate state key: %v", err)
+	}
+	return key, nil
+}
+
+// ParseStateKey decodes a base64 state key, as kept in the environment or keychain
+func ParseStateKey(text string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(text)
+	if err != nil || len(key) != StateKeySize {
+		return nil, fmt.Errorf("invalid state key: must be %d bytes in base64", StateKeySize)
+	}
+	return key, nil
+}
+
+// FormatStateKey encodes a state key as base64
+func FormatStateKey(key []byte) string {
+	return base64.StdEncoding.EncodeToString(key)
+}
+
+// StateKeyID returns the public ID of a key, which is stored with the data it seals
+func StateKeyID(key []byte) string {
+	sum := sha256.Sum256(append([]byte("star-watcher state key\x00"), key...))
+	return hex.EncodeToString(sum[:keyIDSize])
+}
+
+// StateCipher seals state with AES-256-GCM. It seals with its first key and opens data
+// sealed with any of its keys, so state stays readable while it is being rekeyed.
+type StateCipher struct {
+	keys []stateKey
+}
+
+// stateKey is one key of a StateCipher
+type stateKey struct {
+	id   []byte
+	aead cipher.AEAD
+}
+
+// NewStateCipher creates a cipher sealing with the first key; the others only open
+func NewStateCipher(keys ...[]byte) (*StateCipher, error) {
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no state key")
+	}
+
+	c := &StateCipher{}
+	for _, key := range keys {
+		if len(key) != StateKeySize {
+			return nil, fmt.Errorf("invalid state key: must be %d bytes", StateKeySize)
+		}
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+		id, _ := hex.DecodeString(StateKeyID(key))
+		c.keys = append(c.keys, stateKey{id: id, aead: aead})
+	}
+	return c, nil
+}
+
+// KeyID returns the ID of the key that seals
+func (c *StateCipher) KeyID() string {
+	return hex.EncodeToString(c.keys[0].id)
+}
+
+// seal encrypts plaintext of a user. The username is authenticated with the data, so
+// sealed values cannot be moved to another user.
+func (c *StateCipher) seal(username string, plaintext []byte) ([]byte, error) {
+	key := c.keys[0]
+	nonce := make([]byte, key.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %v", err)
+	}
+
+	sealed := append([]byte{sealedVersion}, key.id...)
+	sealed = append(sealed, nonce...)
+	return key.aead.Seal(sealed, nonce, plaintext, []byte(username)), nil
+}
+
+// Seal encrypts data kept next to the state, such as the pending email digest. name is
+// authenticated with the data and must be passed to Open.
+func (c *StateCipher) Seal(name string, plaintext []byte) ([]byte, error) {
+	return c.seal(name, plaintext)
+}
+
+// Open decrypts data sealed by Seal under name; location names the data in errors
+func (c *StateCipher) Open(name, location string, sealed []byte) ([]byte, error) {
+	return c.open(name, location, sealed)
+}
+
+// open decrypts a value sealed for a user. A value sealed with an unknown key is
+// reported as a *WrongKeyError, one that fails authentication as a *StateCorruptionError.
+func (c *StateCipher) open(username, location string, sealed []byte) ([]byte, error) {
+	if len(sealed) < 1+keyIDSize || sealed[0] != sealedVersion {
+		return nil, &StateCorruptionError{FilePath: location, Cause: fmt.Errorf("unsupported encrypted data")}
+	}
+
+	id := sealed[1 : 1+keyIDSize]
+	if c != nil {
+		for _, key := range c.keys {
+			if !bytes.Equal(key.id, id) {
+				continue
+			}
+			nonceEnd := 1 + keyIDSize + key.aead.NonceSize()
+			if len(sealed) < nonceEnd {
+				return nil, &StateCorruptionError{FilePath: location, Cause: fmt.Errorf("encrypted data is truncated")}
+			}
+			plaintext, err := key.aead.Open(nil, sealed[1+keyIDSize:nonceEnd], sealed[nonceEnd:], []byte(username))
+			if err != nil {
+				return nil, &StateCorruptionError{FilePath: location, Cause: fmt.Errorf("failed to decrypt: %v", err)}
+			}
+			return plaintext, nil
+		}
+	}
+	return nil, &WrongKeyError{Username: username, KeyID: hex.EncodeToString(id)}
+}
@@ -0,0 +1,249 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+)
+
+// Rewriter is implemented by stores that can rewrite everything kept for a user in
+// place: the state, its snapshots and its events
+type Rewriter interface {
+	Rewrite(username string, state func(*UserState) error, event func(*Event) error) error
+}
+
+// CipherSource returns the cipher of an EncryptedStore, or nil when no key is configured
+type CipherSource func() (*StateCipher, error)
+
+// EncryptedStore wraps a Store and seals the repositories of every state, snapshot and
+// event with AES-256-GCM, so repository names never reach the backend in plain text.
+// Usernames, timestamps and counts stay readable. Plain state written before encryption
+// was enabled is read as is and sealed when it is saved again.
+type EncryptedStore struct {
+	Store
+	source  CipherSource
+	encrypt bool
+
+	once   sync.Once
+	cipher *StateCipher
+	err    error
+}
+
+// NewEncryptedStore wraps store. The cipher is only requested from source when state is
+// sealed or opened. When encrypt is false, saved state is written in plain text and
+// sealed state is still opened, so encryption can be turned off again.
+func NewEncryptedStore(store Store, source CipherSource, encrypt bool) *EncryptedStore {
+	return &EncryptedStore{Store: store, source: source, encrypt: encrypt}
+}
+
+// Unwrap returns the wrapped store
+func (e *EncryptedStore) Unwrap() Store {
+	return e.Store
+}
+
+// Load reads and opens the state of a user
+func (e *EncryptedStore) Load(username string) (*UserState, error) {
+	state, err := e.Store.Load(username)
+	if err != nil {
+		return nil, err
+	}
+	return e.openState(e.getCipher, username, state)
+}
+
+// LoadSnapshot reads and opens a snapshot of a user
+func (e *EncryptedStore) LoadSnapshot(username, id string) (*UserState, error) {
+	state, err := e.Store.LoadSnapshot(username, id)
+	if err != nil {
+		return nil, err
+	}
+	return e.openState(e.getCipher, username, state)
+}
+
+// Save seals the state and events of a user and saves them
+func (e *EncryptedStore) Save(username string, state *UserState, events []Event) error {
+	if !e.encrypt {
+		return e.Store.Save(username, state, events)
+	}
+
+	c, err := e.sealingCipher()
+	if err != nil {
+		return err
+	}
+	sealed, err := sealState(c, username, state)
+	if err != nil {
+		return err
+	}
+	sealedEvents := make([]Event, len(events))
+	for i, event := range events {
+		if sealedEvents[i], err = sealEvent(c, username, event); err != nil {
+			return err
+		}
+	}
+	return e.Store.Save(username, sealed, sealedEvents)
+}
+
+// Events reads and opens the events of a user that match filter. The language of a
+// sealed event is only known once it is opened, so that part of the filter is applied here.
+func (e *EncryptedStore) Events(username string, filter EventFilter) ([]Event, error) {
+	unfiltered := filter
+	unfiltered.Language = ""
+	events, err := e.Store.Events(username, unfiltered)
+	if err != nil {
+		return nil, err
+	}
+
+	matching := events[:0]
+	for _, event := range events {
+		if len(event.Encrypted) > 0 {
+			c, err := e.getCipher()
+			if err != nil {
+				return nil, err
+			}
+			if event, err = openEvent(c, username, event); err != nil {
+				return nil, err
+			}
+		}
+		if filter.Match(event) {
+			matching = append(matching, event)
+		}
+	}
+	return matching, nil
+}
+
+// Rekey seals the state, snapshots and events of a user with the first key of next,
+// whether they were sealed before or not. next must also hold the keys that opened
+// the data so far; the wrapped store must be a Rewriter.
+func (e *EncryptedStore) Rekey(username string, next *StateCipher) error {
+	rewriter, ok := e.Store.(Rewriter)
+	if !ok {
+		return fmt.Errorf("%T cannot be rekeyed", e.Store)
+	}
+	nextCipher := func() (*StateCipher, error) { return next, nil }
+
+	return rewriter.Rewrite(username,
+		func(state *UserState) error {
+			opened, err := e.openState(nextCipher, username, state)
+			if err != nil {
+				return err
+			}
+			sealed, err := sealState(next, username, opened)
+			if err != nil {
+				return err
+			}
+			*state = *sealed
+			return nil
+		},
+		func(event *Event) error {
+			opened := *event
+			if len(event.Encrypted) > 0 {
+				var err error
+				if opened, err = openEvent(next, username, *event); err != nil {
+					return err
+				}
+			}
+			sealed, err := sealEvent(next, username, opened)
+			if err != nil {
+				return err
+			}
+			*event = sealed
+			return nil
+		})
+}
+
+// getCipher returns the cipher of the store, requesting it from the source once
+func (e *EncryptedStore) getCipher() (*StateCipher, error) {
+	e.once.Do(func() {
+		e.cipher, e.err = e.source()
+	})
+	return e.cipher, e.err
+}
+
+// sealingCipher returns the cipher of the store, which must have a key
+func (e *EncryptedStore) sealingCipher() (*StateCipher, error) {
+	c, err := e.getCipher()
+	if err != nil {
+		return nil, err
+	}
+	if c == nil {
+		return nil, fmt.Errorf("state encryption is enabled but no state key is configured; run \"star-watcher state rekey\" to create one")
+	}
+	return c, nil
+}
+
+// openState returns state with its repositories opened; plain state is returned as is
+func (e *EncryptedStore) openState(getCipher CipherSource, username string, state *UserState) (*UserState, error) {
+	if len(state.Encrypted) == 0 {
+		return state, nil
+	}
+
+	c, err := getCipher()
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := c.open(username, username, state.Encrypted)
+	if err != nil {
+		return nil, err
+	}
+
+	opened := *state
+	opened.Encrypted = nil
+	if err := json.Unmarshal(plaintext, &opened.Repositories); err != nil {
+		return nil, &StateCorruptionError{FilePath: username, Cause: fmt.Errorf("encrypted repositories: %v", err)}
+	}
+	if err := opened.Validate(); err != nil {
+		return nil, &StateCorruptionError{FilePath: username, Cause: fmt.Errorf("validation failed: %v", err)}
+	}
+	return &opened, nil
+}
+
+// sealState returns a copy of state with its repositories sealed
+func sealState(c *StateCipher, username string, state *UserState) (*UserState, error) {
+	plaintext, err := json.Marshal(state.Repositories)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode repositories: %v", err)
+	}
+
+	sealed := *state
+	sealed.Repositories = []Repository{}
+	if sealed.Encrypted, err = c.seal(username, plaintext); err != nil {
+		return nil, err
+	}
+	return &sealed, nil
+}
+
+// sealedEvent is the sealed part of an event: its repository, with the previous name
+// of a renamed repository alongside the repository fields
+type sealedEvent struct {
+	Repository
+	PreviousFullName string `json:"previous_full_name,omitempty"`
+}
+
+// sealEvent returns a copy of event with its repository sealed
+func sealEvent(c *StateCipher, username string, event Event) (Event, error) {
+	plaintext, err := json.Marshal(sealedEvent{event.Repository, event.PreviousFullName})
+	if err != nil {
+		return Event{}, fmt.Errorf("failed to encode event repository: %v", err)
+	}
+
+	event.Repository = Repository{}
+	event.PreviousFullName = ""
+	event.Encrypted, err = c.seal(username, plaintext)
+	return event, err
+}
+
+// openEvent returns a copy of event with its repository opened
+func openEvent(c *StateCipher, username string, event Event) (Event, error) {
+	plaintext, err := c.open(username, username+" events", event.Encrypted)
+	if err != nil {
+		return Event{}, err
+	}
+
+	var opened sealedEvent
+	if err := json.Unmarshal(plaintext, &opened); err != nil {
+		return Event{}, &StateCorruptionError{FilePath: username + " events", Cause: fmt.Errorf("encrypted repository: %v", err)}
+	}
+	event.Encrypted = nil
+	event.Repository = opened.Repository
+	event.PreviousFullName = opened.PreviousFullName
+	return event, nil
+}
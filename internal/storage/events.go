@@ -0,0 +1,230 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Event types recorded in the event log
+const (
+	EventBaseline = "baseline" // Repository found on the first run of a user
+	EventStar     = "star"     // Newly starred repository
+	EventUnstar   = "unstar"   // Repository no longer starred
+	EventReStar   = "re_star"  // Repository starred again after being unstarred
+	EventUpdate   = "update"   // Repository metadata changed
+	EventRename   = "rename"   // Repository renamed or transferred to another owner
+)
+
+// Event is one entry of a user's event log
+type Event struct {
+	RunID            string     `json:"run_id"`                       // Identifies the monitor run that detected the change
+	Timestamp        time.Time  `json:"timestamp"`                    // When the change was detected
+	Username         string     `json:"username"`                     // GitHub user whose stars changed
+	Type             string     `json:"type"`                         // One of the Event* constants
+	Repository       Repository `json:"repository"`                   // Repository as seen by the run
+	PreviousFullName string     `json:"previous_full_name,omitempty"` // Name of the repository before a rename event
+	Changes          []string   `json:"changes,omitempty"`            // Changed fields of an update event
+	Encrypted        []byte     `json:"encrypted,omitempty"`          // Repository sealed by EncryptedStore; Repository is then empty
+}
+
+// EventLogPath returns the event log stored next to a state file,
+// e.g. ~/.star-watcher/octocat.events.jsonl for ~/.star-watcher/octocat.json
+func EventLogPath(stateFilePath string) string {
+	return strings.TrimSuffix(stateFilePath, filepath.Ext(stateFilePath)) + ".events.jsonl"
+}
+
+// EventLog is an append-only JSON Lines file of a user's star changes
+type EventLog struct {
+	path string
+}
+
+// NewEventLog creates an event log backed by path
+func NewEventLog(path string) *EventLog {
+	return &EventLog{path: path}
+}
+
+// Path returns the file backing the log
+func (l *EventLog) Path() string {
+	return l.path
+}
+
+// Append writes events to the end of the log in a single write. The returned rollback
+// truncates the log to its previous size; call it when the state save of the same run
+// fails so the log never records changes the state does not reflect.
+func (l *EventLog) Append(events []Event) (rollback func() error, err error) {
+	noop := func() error { return nil }
+	if len(events) == 0 {
+		return noop, nil
+	}
+
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	for _, event := range events {
+		if err := encoder.Encode(event); err != nil {
+			return noop, fmt.Errorf("failed to encode event: %v", err)
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(l.path), 0755); err != nil {
+		return noop, fmt.Errorf("failed to create directory for event log: %v", err)
+	}
+
+	file, err := os.OpenFile(l.path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return noop, fmt.Errorf("failed to open event log: %v", err)
+	}
+	defer file.Close()
+
+	size, err := completeSize(file)
+	if err != nil {
+		return noop, fmt.Errorf("failed to read event log: %v", err)
+	}
+	if err := file.Truncate(size); err != nil { // Drop a torn line left by an interrupted write
+		return noop, fmt.Errorf("failed to repair event log: %v", err)
+	}
+	rollback = func() error {
+		return os.Truncate(l.path, size)
+	}
+
+	if _, err := file.Write(buf.Bytes()); err != nil {
+		rollback()
+		return noop, fmt.Errorf("failed to write event log: %v", err)
+	}
+	if err := file.Sync(); err != nil {
+		rollback()
+		return noop, fmt.Errorf("failed to sync event log: %v", err)
+	}
+
+	return rollback, nil
+}
+
+// Replace atomically replaces the whole log with events. A missing log without events
+// is left missing.
+func (l *EventLog) Replace(events []Event) error {
+	if len(events) == 0 {
+		if _, err := os.Stat(l.path); os.IsNotExist(err) {
+			return nil
+		}
+	}
+
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	for _, event := range events {
+		if err := encoder.Encode(event); err != nil {
+			return fmt.Errorf("failed to encode event: %v", err)
+		}
+	}
+
+	tempFile := l.path + ".tmp"
+	if err := os.WriteFile(tempFile, buf.Bytes(), 0644); err != nil {
+		os.Remove(tempFile)
+		return fmt.Errorf("failed to write event log: %v", err)
+	}
+	if err := os.Rename(tempFile, l.path); err != nil {
+		os.Remove(tempFile)
+		return fmt.Errorf("failed to replace event log: %v", err)
+	}
+	return nil
+}
+
+// completeSize returns the size of file up to and including its last newline
+func completeSize(file *os.File) (int64, error) {
+	info, err := file.Stat()
+	if err != nil {
+		return 0, err
+	}
+
+	const chunkSize = 64 * 1024
+	buf := make([]byte, chunkSize)
+	for end := info.Size(); end > 0; {
+		start := max(end-chunkSize, 0)
+		n, err := file.ReadAt(buf[:end-start], start)
+		if err != nil && err != io.EOF {
+			return 0, err
+		}
+		if i := bytes.LastIndexByte(buf[:n], '\n'); i >= 0 {
+			return start + int64(i) + 1, nil
+		}
+		end = start
+	}
+	return 0, nil
+}
+
+// Read returns all events in the order they were written. A missing log has no events.
+// A torn last line left by a crash during a write is ignored.
+func (l *EventLog) Read() ([]Event, error) {
+	file, err := os.Open(l.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open event log: %v", err)
+	}
+	defer file.Close()
+
+	var events []Event
+	reader := bufio.NewReader(file)
+	for lineNumber := 1; ; lineNumber++ {
+		line, readErr := reader.ReadBytes('\n')
+		if readErr != nil && readErr != io.EOF {
+			return nil, fmt.Errorf("failed to read event log: %v", readErr)
+		}
+
+		if len(bytes.TrimSpace(line)) > 0 {
+			var event Event
+			if err := json.Unmarshal(line, &event); err != nil {
+				if readErr == io.EOF { // Last line without newline: an interrupted write
+					break
+				}
+				return nil, &StateCorruptionError{
+					FilePath: l.path,
+					Cause:    fmt.Errorf("line %d: %v", lineNumber, err),
+				}
+			}
+			events = append(events, event)
+		}
+
+		if readErr == io.EOF {
+			break
+		}
+	}
+
+	return events, nil
+}
+
+// EventFilter selects events from an event log. Zero fields match everything.
+type EventFilter struct {
+	Since    time.Time // Events at or after Since
+	Until    time.Time // Events before Until
+	Types    []string  // Event types (Event* constants)
+	Language string    // Repository language, case-insensitive
+}
+
+// Match reports whether event passes the filter
+func (f EventFilter) Match(event Event) bool {
+	if !f.Since.IsZero() && event.Timestamp.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && !event.Timestamp.Before(f.Until) {
+		return false
+	}
+	if f.Language != "" && !strings.EqualFold(f.Language, event.Repository.Language) {
+		return false
+	}
+	if len(f.Types) == 0 {
+		return true
+	}
+	for _, eventType := range f.Types {
+		if eventType == event.Type {
+			return true
+		}
+	}
+	return false
+}
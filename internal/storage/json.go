@@ -1,18 +1,36 @@
 package storage
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
+	"time"
 )
 
 // JSONStorage implements the StateStorage interface using JSON files
-type JSONStorage struct{}
+type JSONStorage struct {
+	snapshots   SnapshotPolicy
+	compression Compression
+}
 
 // NewJSONStorage creates a new JSON storage implementation
 func NewJSONStorage() *JSONStorage {
-	return &JSONStorage{}
+	return &JSONStorage{snapshots: DefaultSnapshotPolicy}
+}
+
+// SetSnapshotPolicy sets how many snapshots of replaced states are kept
+func (j *JSONStorage) SetSnapshotPolicy(policy SnapshotPolicy) {
+	j.snapshots = policy
+}
+
+// SetCompression sets how state files are compressed when they are written. Files are
+// loaded whatever their compression, and a .gz or .zst file name takes precedence.
+func (j *JSONStorage) SetCompression(compression Compression) {
+	j.compression = compression
 }
 
 // SaveUserState persists user state to the specified file path with atomic writes
@@ -28,15 +46,17 @@ func (j *JSONStorage) SaveUserState(filePath string, state *UserState) error {
 		return fmt.Errorf("failed to create directory %s: %v", dir, err)
 	}
 
-	// Create backup of existing file if it exists
-	if _, err := os.Stat(filePath); err == nil {
-		backupPath := filePath + ".bak"
-		if err := copyFile(filePath, backupPath); err != nil {
-			// Log warning but don't fail the save operation
-			fmt.Fprintf(os.Stderr, "Warning: failed to create backup: %v\n", err)
-		}
+	// Keep a snapshot of the existing file if it exists
+	if err := j.snapshot(filePath, time.Now()); err != nil {
+		// Log warning but don't fail the save operation
+		fmt.Fprintf(os.Stderr, "Warning: failed to create snapshot: %v\n", err)
 	}
 
+	return j.write(filePath, state)
+}
+
+// write replaces the state file at filePath atomically
+func (j *JSONStorage) write(filePath string, state *UserState) error {
 	// Atomic write: write to temporary file first, then rename
 	tempFile := filePath + ".tmp"
 	file, err := os.Create(tempFile)
@@ -51,12 +71,23 @@ func (j *JSONStorage) SaveUserState(filePath string, state *UserState) error {
 		}
 	}()
 
-	// Write JSON with indentation for human readability
-	encoder := json.NewEncoder(file)
-	encoder.SetIndent("", "  ")
+	compression := compressionFor(filePath, j.compression)
+	writer, err := compressWriter(file, compression)
+	if err != nil {
+		return err
+	}
+
+	// Write JSON with indentation for human readability, unless it is compressed anyway
+	encoder := json.NewEncoder(writer)
+	if compression == CompressionNone {
+		encoder.SetIndent("", "  ")
+	}
 	if err := encoder.Encode(state); err != nil {
 		return fmt.Errorf("failed to encode JSON: %v", err)
 	}
+	if err := writer.Close(); err != nil {
+		return fmt.Errorf("failed to compress state: %v", err)
+	}
 
 	// Close file before rename
 	if err := file.Close(); err != nil {
@@ -79,18 +110,15 @@ func (j *JSONStorage) LoadUserState(filePath string) (*UserState, error) {
 	}
 
 	// Read file
-	data, err := os.ReadFile(filePath)
+	data, err := readStateFile(filePath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read state file: %v", err)
+		return nil, err
 	}
 
-	// Parse JSON
-	var state UserState
-	if err := json.Unmarshal(data, &state); err != nil {
-		return nil, &StateCorruptionError{
-			FilePath: filePath,
-			Cause:    err,
-		}
+	// Parse JSON, upgrading state of older versions
+	state, err := decodeState(filePath, data)
+	if err != nil {
+		return nil, err
 	}
 
 	// Validate loaded state
@@ -101,7 +129,148 @@ func (j *JSONStorage) LoadUserState(filePath string) (*UserState, error) {
 		}
 	}
 
-	return &state, nil
+	return state, nil
+}
+
+// SaveUserStateWithEvents appends events to the event log next to the state file and then
+// saves the state. The appended events are removed again if the state cannot be saved.
+func (j *JSONStorage) SaveUserStateWithEvents(filePath string, state *UserState, events []Event) error {
+	eventLog := NewEventLog(EventLogPath(filePath))
+	rollback, err := eventLog.Append(events)
+	if err != nil {
+		return err
+	}
+
+	if err := j.SaveUserState(filePath, state); err != nil {
+		if rollbackErr := rollback(); rollbackErr != nil {
+			return fmt.Errorf("%v (failed to roll back event log %s: %v)", err, eventLog.Path(), rollbackErr)
+		}
+		return err
+	}
+
+	return nil
+}
+
+// LoadEvents reads the event log next to the state file
+func (j *JSONStorage) LoadEvents(filePath string, filter EventFilter) ([]Event, error) {
+	events, err := NewEventLog(EventLogPath(filePath)).Read()
+	if err != nil {
+		return nil, err
+	}
+
+	matching := events[:0]
+	for _, event := range events {
+		if filter.Match(event) {
+			matching = append(matching, event)
+		}
+	}
+	return matching, nil
+}
+
+// snapshot copies the state file into its snapshot directory and removes the snapshots
+// the policy no longer keeps
+func (j *JSONStorage) snapshot(filePath string, now time.Time) error {
+	if j.snapshots.Count <= 0 {
+		return nil
+	}
+	if _, err := os.Stat(filePath); os.IsNotExist(err) {
+		return nil
+	}
+
+	dir := SnapshotDir(filePath)
+	existing, err := snapshotsIn(dir)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	taken := Snapshot{TakenAt: snapshotTime(now, existing)}
+	taken.ID = taken.TakenAt.UTC().Format(snapshotIDFormat)
+	if err := copyFile(filePath, filepath.Join(dir, taken.ID+".json")); err != nil {
+		return err
+	}
+
+	for _, expired := range j.snapshots.Expired(append([]Snapshot{taken}, existing...), now) {
+		if err := os.Remove(filepath.Join(dir, expired.ID+".json")); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// snapshotsIn lists the snapshot files in dir by ID and time only, newest first
+func snapshotsIn(dir string) ([]Snapshot, error) {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list snapshots: %v", err)
+	}
+
+	var snapshots []Snapshot
+	for _, entry := range entries {
+		id, ok := strings.CutSuffix(entry.Name(), ".json")
+		if !ok || entry.IsDir() {
+			continue
+		}
+		takenAt, err := parseSnapshotID(id)
+		if err != nil {
+			continue
+		}
+		snapshots = append(snapshots, Snapshot{ID: id, TakenAt: takenAt})
+	}
+
+	sort.Slice(snapshots, func(i, k int) bool {
+		return snapshots[i].TakenAt.After(snapshots[k].TakenAt)
+	})
+	return snapshots, nil
+}
+
+// Snapshots lists the snapshots of a state file, newest first
+func (j *JSONStorage) Snapshots(filePath string) ([]Snapshot, error) {
+	dir := SnapshotDir(filePath)
+	snapshots, err := snapshotsIn(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, snapshot := range snapshots {
+		state, err := j.LoadUserState(filepath.Join(dir, snapshot.ID+".json"))
+		if err != nil {
+			continue // Listed by time only, LoadSnapshot reports the problem
+		}
+		snapshots[i] = newSnapshot(state, snapshot.TakenAt)
+	}
+	return snapshots, nil
+}
+
+// LoadSnapshot loads a snapshot of a state file
+func (j *JSONStorage) LoadSnapshot(filePath, id string) (*UserState, error) {
+	if _, err := parseSnapshotID(id); err != nil {
+		return nil, &StateFileNotFoundError{FilePath: filepath.Join(SnapshotDir(filePath), id)}
+	}
+	return j.LoadUserState(filepath.Join(SnapshotDir(filePath), id+".json"))
+}
+
+// readStateFile reads a state file, decompressing it if needed. A file that fails to
+// decompress is reported as a *StateCorruptionError.
+func readStateFile(filePath string) ([]byte, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read state file: %v", err)
+	}
+
+	data, err = decompress(data)
+	if err != nil {
+		return nil, &StateCorruptionError{
+			FilePath: filePath,
+			Cause:    fmt.Errorf("failed to decompress: %v", err),
+		}
+	}
+	return data, nil
 }
 
 // copyFile creates a copy of a file for backup purposes
@@ -112,3 +281,209 @@ func copyFile(src, dst string) error {
 	}
 	return os.WriteFile(dst, data, 0644)
 }
+
+// JSONStore implements the Store interface with one JSON file per user, next to its
+// snapshot directory (.snapshots) and event log (.events.jsonl)
+type JSONStore struct {
+	dir     string
+	file    string
+	storage *JSONStorage
+}
+
+// NewJSONStore creates a store keeping the state of each user in <dir>/<username>.json
+func NewJSONStore(dir string) *JSONStore {
+	return &JSONStore{dir: dir, storage: NewJSONStorage()}
+}
+
+// NewJSONFileStore creates a store keeping state in a single file, whatever the user
+func NewJSONFileStore(path string) *JSONStore {
+	return &JSONStore{dir: filepath.Dir(path), file: path, storage: NewJSONStorage()}
+}
+
+// Path returns the state file of a user
+func (s *JSONStore) Path(username string) string {
+	if s.file != "" {
+		return s.file
+	}
+	return filepath.Join(s.dir, username+".json")
+}
+
+// Load reads the state file of a user
+func (s *JSONStore) Load(username string) (*UserState, error) {
+	return s.load(username, s.Path(username))
+}
+
+// load reads a state file, reporting a missing file as a *StateNotFoundError
+func (s *JSONStore) load(username, path string) (*UserState, error) {
+	state, err := s.storage.LoadUserState(path)
+	if _, ok := err.(*StateFileNotFoundError); ok {
+		return nil, &StateNotFoundError{Username: username}
+	}
+	return state, err
+}
+
+// SetSnapshotPolicy sets how many snapshots are kept per user
+func (s *JSONStore) SetSnapshotPolicy(policy SnapshotPolicy) {
+	s.storage.SetSnapshotPolicy(policy)
+}
+
+// SetCompression sets how state files are compressed when they are written
+func (s *JSONStore) SetCompression(compression Compression) {
+	s.storage.SetCompression(compression)
+}
+
+// Snapshots lists the snapshots of a user, newest first
+func (s *JSONStore) Snapshots(username string) ([]Snapshot, error) {
+	return s.storage.Snapshots(s.Path(username))
+}
+
+// LoadSnapshot reads a snapshot of a user
+func (s *JSONStore) LoadSnapshot(username, id string) (*UserState, error) {
+	state, err := s.storage.LoadSnapshot(s.Path(username), id)
+	if _, ok := err.(*StateFileNotFoundError); ok {
+		return nil, &SnapshotNotFoundError{Username: username, ID: id}
+	}
+	return state, err
+}
+
+// Save appends events to the event log of a user and writes the state file
+func (s *JSONStore) Save(username string, state *UserState, events []Event) error {
+	return s.storage.SaveUserStateWithEvents(s.Path(username), state, events)
+}
+
+// Events reads the event log of a user
+func (s *JSONStore) Events(username string, filter EventFilter) ([]Event, error) {
+	return s.storage.LoadEvents(s.Path(username), filter)
+}
+
+// List returns the users with a state file. Other JSON files sharing the directory,
+// such as the notification status, are skipped.
+func (s *JSONStore) List() ([]string, error) {
+	paths := []string{s.file}
+	if s.file == "" {
+		var err error
+		if paths, err = filepath.Glob(filepath.Join(s.dir, "*.json")); err != nil {
+			return nil, fmt.Errorf("failed to list state files: %v", err)
+		}
+	}
+
+	usernames := make([]string, 0, len(paths))
+	for _, path := range paths {
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			continue
+		}
+		data, err := readStateFile(path)
+		if _, ok := err.(*StateCorruptionError); ok {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		var state struct {
+			Username string `json:"username"`
+		}
+		if json.Unmarshal(data, &state) != nil || state.Username == "" {
+			continue
+		}
+
+		if s.file != "" {
+			usernames = append(usernames, state.Username)
+		} else {
+			usernames = append(usernames, strings.TrimSuffix(filepath.Base(path), ".json"))
+		}
+	}
+
+	sort.Strings(usernames)
+	return usernames, nil
+}
+
+// Delete removes the state file of a user with its snapshots and event log.
+// The .bak backup written by earlier versions is removed as well.
+func (s *JSONStore) Delete(username string) error {
+	path := s.Path(username)
+	if err := os.Remove(path); os.IsNotExist(err) {
+		return &StateNotFoundError{Username: username}
+	} else if err != nil {
+		return fmt.Errorf("failed to remove state file %s: %v", path, err)
+	}
+
+	for _, extra := range []string{path + ".bak", EventLogPath(path), SnapshotDir(path)} {
+		if err := os.RemoveAll(extra); err != nil {
+			return fmt.Errorf("failed to remove %s: %v", extra, err)
+		}
+	}
+	return nil
+}
+
+// Rewrite applies state to the state file and every snapshot of a user and event to
+// every event in its event log. Each file is replaced atomically and no snapshot is taken.
+func (s *JSONStore) Rewrite(username string, state func(*UserState) error, event func(*Event) error) error {
+	path := s.Path(username)
+	current, err := s.load(username, path)
+	if err != nil {
+		return err
+	}
+
+	eventLog := NewEventLog(EventLogPath(path))
+	events, err := eventLog.Read()
+	if err != nil {
+		return err
+	}
+	for i := range events {
+		if err := event(&events[i]); err != nil {
+			return err
+		}
+	}
+	if err := eventLog.Replace(events); err != nil {
+		return err
+	}
+
+	dir := SnapshotDir(path)
+	snapshots, err := snapshotsIn(dir)
+	if err != nil {
+		return err
+	}
+	for _, snapshot := range snapshots {
+		snapshotPath := filepath.Join(dir, snapshot.ID+".json")
+		snapshotState, err := s.storage.LoadUserState(snapshotPath)
+		if err != nil {
+			return err
+		}
+		if err := state(snapshotState); err != nil {
+			return fmt.Errorf("snapshot %s: %w", snapshot.ID, err)
+		}
+		if err := s.storage.write(snapshotPath, snapshotState); err != nil {
+			return err
+		}
+	}
+
+	if err := state(current); err != nil {
+		return err
+	}
+	if err := current.Validate(); err != nil {
+		return fmt.Errorf("invalid user state: %v", err)
+	}
+	return s.storage.write(path, current)
+}
+
+// Exists reports whether the state file of a user exists
+func (s *JSONStore) Exists(username string) (bool, error) {
+	_, err := os.Stat(s.Path(username))
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to check state file: %v", err)
+	}
+	return true, nil
+}
+
+// Lock locks <state file>.lock
+func (s *JSONStore) Lock(ctx context.Context, username string, timeout time.Duration) (*FileLock, error) {
+	return LockFile(ctx, s.Path(username)+".lock", timeout)
+}
+
+// Close does nothing; every operation opens and closes its own files
+func (s *JSONStore) Close() error {
+	return nil
+}
@@ -0,0 +1,74 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// lockPollInterval is how often a held lock is retried
+const lockPollInterval = 50 * time.Millisecond
+
+// LockTimeoutError is returned when another process holds a lock for longer than the timeout
+type LockTimeoutError struct {
+	Path    string
+	Timeout time.Duration
+}
+
+func (e *LockTimeoutError) Error() string {
+	return fmt.Sprintf("another run holds the lock %s (waited %s); try again later or raise --lock-timeout", e.Path, e.Timeout)
+}
+
+// FileLock is an exclusive advisory lock (flock on Unix) shared between processes.
+// The lock file itself is left in place after Unlock.
+type FileLock struct {
+	file *os.File
+}
+
+// LockFile acquires the lock on path, creating the file if needed. It retries until
+// timeout has passed or ctx is done; a zero timeout tries only once.
+func LockFile(ctx context.Context, path string, timeout time.Duration) (*FileLock, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create directory for lock: %v", err)
+	}
+
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lock file: %v", err)
+	}
+
+	deadline := time.Now().Add(timeout)
+	for {
+		locked, err := tryLock(file)
+		if err != nil {
+			file.Close()
+			return nil, fmt.Errorf("failed to lock %s: %v", path, err)
+		}
+		if locked {
+			return &FileLock{file: file}, nil
+		}
+
+		if !time.Now().Before(deadline) {
+			file.Close()
+			return nil, &LockTimeoutError{Path: path, Timeout: timeout}
+		}
+
+		select {
+		case <-ctx.Done():
+			file.Close()
+			return nil, ctx.Err()
+		case <-time.After(min(lockPollInterval, time.Until(deadline))):
+		}
+	}
+}
+
+// Unlock releases the lock
+func (l *FileLock) Unlock() error {
+	unlockErr := unlock(l.file)
+	if err := l.file.Close(); err != nil && unlockErr == nil {
+		return err
+	}
+	return unlockErr
+}
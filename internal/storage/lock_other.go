@@ -0,0 +1,18 @@
+//go:build !unix && !windows
+
+package storage
+
+import (
+	"errors"
+	"os"
+)
+
+// tryLock reports that advisory locks are not available on this platform
+func tryLock(file *os.File) (bool, error) {
+	return false, errors.New("file locking is not supported on this platform")
+}
+
+// unlock does nothing; no lock can be held
+func unlock(file *os.File) error {
+	return nil
+}
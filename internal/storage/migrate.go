@@ -0,0 +1,189 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// CurrentStateVersion is the schema version of the state this binary writes
+const CurrentStateVersion = "1.2.0"
+
+// unversionedStateVersion is assumed for state saved without a state_version
+const unversionedStateVersion = "1.0.0"
+
+// StateMigration upgrades a state document from one schema version to the next
+type StateMigration struct {
+	From        string
+	To          string
+	Description string
+	Migrate     func(doc map[string]any) error
+}
+
+// stateMigrations upgrade state documents one version at a time, oldest first. A step
+// sees the document as stored: the repositories of an encrypted state are sealed, so a
+// step must not depend on them.
+var stateMigrations = []StateMigration{
+	{
+		From:        "1.0.0",
+		To:          "1.1.0",
+		Description: "enable incremental fetching for state written before it existed",
+		Migrate:     enableIncrementalFetching,
+	},
+	{
+		From:        "1.1.0",
+		To:          "1.2.0",
+		Description: "schedule a full sync to fill in the GitHub IDs of starred repositories",
+		Migrate:     scheduleFullSync,
+	},
+}
+
+// StateVersionError is returned when state was written by a newer star-watcher with a
+// schema this binary does not know. The state is left untouched.
+type StateVersionError struct {
+	FilePath string
+	Version  string
+}
+
+func (e *StateVersionError) Error() string {
+	return fmt.Sprintf("state at %s has version %s, newer than version %s supported by this star-watcher; upgrade star-watcher to use it",
+		e.FilePath, e.Version, CurrentStateVersion)
+}
+
+// decodeState parses a state document read from location, upgrading it to
+// CurrentStateVersion. The upgraded state is written on its next save.
+func decodeState(location string, data []byte) (*UserState, error) {
+	var state UserState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, &StateCorruptionError{FilePath: location, Cause: err}
+	}
+	if state.StateVersion == CurrentStateVersion {
+		return &state, nil
+	}
+
+	var doc map[string]any
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, &StateCorruptionError{FilePath: location, Cause: err}
+	}
+	if err := migrateStateDocument(location, doc); err != nil {
+		return nil, err
+	}
+	migrated, err := json.Marshal(doc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode migrated state: %v", err)
+	}
+
+	state = UserState{}
+	if err := json.Unmarshal(migrated, &state); err != nil {
+		return nil, &StateCorruptionError{FilePath: location, Cause: fmt.Errorf("migrated state: %v", err)}
+	}
+	return &state, nil
+}
+
+// upgradeState upgrades a state that was not read from a document, such as a row of
+// the SQLite users table, to CurrentStateVersion
+func upgradeState(location string, state *UserState) (*UserState, error) {
+	if state.StateVersion == CurrentStateVersion {
+		return state, nil
+	}
+	data, err := json.Marshal(state)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode state: %v", err)
+	}
+	return decodeState(location, data)
+}
+
+// migrateStateDocument applies the migrations from the version declared by doc up to
+// CurrentStateVersion, recording each new version in doc
+func migrateStateDocument(location string, doc map[string]any) error {
+	version, _ := doc["state_version"].(string)
+	if version == "" {
+		version = unversionedStateVersion
+	}
+
+	newer, err := compareStateVersions(version, CurrentStateVersion)
+	if err != nil {
+		return &StateCorruptionError{FilePath: location, Cause: err}
+	}
+	if newer > 0 {
+		return &StateVersionError{FilePath: location, Version: version}
+	}
+
+	for version != CurrentStateVersion {
+		migration, ok := findStateMigration(version)
+		if !ok {
+			return &StateCorruptionError{FilePath: location, Cause: fmt.Errorf("no migration from state version %s", version)}
+		}
+		if err := migration.Migrate(doc); err != nil {
+			return &StateCorruptionError{FilePath: location, Cause: fmt.Errorf("migration to state version %s failed: %v", migration.To, err)}
+		}
+		version = migration.To
+		doc["state_version"] = version
+	}
+	return nil
+}
+
+// findStateMigration returns the migration upgrading state of version from
+func findStateMigration(from string) (StateMigration, bool) {
+	for _, migration := range stateMigrations {
+		if migration.From == from {
+			return migration, true
+		}
+	}
+	return StateMigration{}, false
+}
+
+// compareStateVersions compares the major, minor and patch numbers of two semantic
+// versions, returning -1, 0 or 1
+func compareStateVersions(a, b string) (int, error) {
+	partsA, err := parseStateVersion(a)
+	if err != nil {
+		return 0, err
+	}
+	partsB, err := parseStateVersion(b)
+	if err != nil {
+		return 0, err
+	}
+	for i := range partsA {
+		switch {
+		case partsA[i] < partsB[i]:
+			return -1, nil
+		case partsA[i] > partsB[i]:
+			return 1, nil
+		}
+	}
+	return 0, nil
+}
+
+// parseStateVersion returns the major, minor and patch numbers of a semantic version
+func parseStateVersion(version string) ([3]int, error) {
+	var parts [3]int
+	match := semanticVersionPattern.FindStringSubmatch(version)
+	if match == nil {
+		return parts, fmt.Errorf("invalid semantic version format: %s", version)
+	}
+	for i := range parts {
+		parts[i], _ = strconv.Atoi(match[i+1])
+	}
+	return parts, nil
+}
+
+// enableIncrementalFetching turns on incremental fetching for 1.0.0 state saved before
+// the incremental fields existed, recognised by both being unset
+func enableIncrementalFetching(doc map[string]any) error {
+	enabled, _ := doc["incremental_enabled"].(bool)
+	interval, _ := doc["full_sync_interval"].(float64)
+	if !enabled && interval == 0 {
+		doc["incremental_enabled"] = true
+		doc["full_sync_interval"] = 24
+	}
+	return nil
+}
+
+// scheduleFullSync makes the next run a full sync. Repositories were matched by name
+// before 1.2.0; the full sync stores their IDs so renames can be told from unstars.
+func scheduleFullSync(doc map[string]any) error {
+	doc["last_full_sync_at"] = time.Time{}
+	return nil
+}
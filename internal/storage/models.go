@@ -9,14 +9,16 @@ import (
 
 // Repository represents a starred GitHub repository with all metadata needed for comparison and display
 type Repository struct {
-	FullName    string    `json:"full_name"`   // Owner/repo format (e.g., "microsoft/vscode")
-	Description string    `json:"description"` // Repository description (nullable)
-	StarCount   int       `json:"star_count"`  // Current number of stars
-	UpdatedAt   time.Time `json:"updated_at"`  // Last repository update timestamp
-	URL         string    `json:"url"`         // Repository URL for browser access
-	StarredAt   time.Time `json:"starred_at"`  // When user starred this repository
-	Language    string    `json:"language"`    // Primary programming language (optional)
-	Private     bool      `json:"private"`     // Whether repository is private
+	ID          int64     `json:"id,omitempty"`      // GitHub repository ID, kept across renames and transfers (0 until known)
+	NodeID      string    `json:"node_id,omitempty"` // GraphQL node ID of the repository
+	FullName    string    `json:"full_name"`         // Owner/repo format (e.g., "microsoft/vscode")
+	Description string    `json:"description"`       // Repository description (nullable)
+	StarCount   int       `json:"star_count"`        // Current number of stars
+	UpdatedAt   time.Time `json:"updated_at"`        // Last repository update timestamp
+	URL         string    `json:"url"`               // Repository URL for browser access
+	StarredAt   time.Time `json:"starred_at"`        // When user starred this repository
+	Language    string    `json:"language"`          // Primary programming language (optional)
+	Private     bool      `json:"private"`           // Whether repository is private
 }
 
 // githubRepoNamePattern validates GitHub repository full names
@@ -63,18 +65,20 @@ func (r *Repository) String() string {
 
 // UserState represents the persisted state for a GitHub user's monitoring session
 type UserState struct {
-	Username     string       `json:"username"`      // GitHub username being monitored
-	LastCheck    time.Time    `json:"last_check"`    // Timestamp of last successful check
-	Repositories []Repository `json:"repositories"`  // Previously seen starred repositories
-	TotalCount   int          `json:"total_count"`   // Total repositories at last check (for pagination validation)
-	StateVersion string       `json:"state_version"` // Schema version for backward compatibility
-	CheckCount   int          `json:"check_count"`   // Number of successful checks performed
+	Username     string       `json:"username"`              // GitHub username being monitored
+	LastCheck    time.Time    `json:"last_check"`            // Timestamp of last successful check
+	Repositories []Repository `json:"repositories"`          // Previously seen starred repositories
+	TotalCount   int          `json:"total_count"`           // Total repositories at last check (for pagination validation)
+	StateVersion string       `json:"state_version"`         // Schema version for backward compatibility
+	CheckCount   int          `json:"check_count"`           // Number of successful checks performed
+	LastRunID    string       `json:"last_run_id,omitempty"` // Run that wrote this state; matches its events in the event log
+	Encrypted    []byte       `json:"encrypted,omitempty"`   // Repositories sealed by EncryptedStore; Repositories is then empty
 
 	// Incremental fetching fields
 	LastStarredAt      time.Time `json:"last_starred_at"`     // Most recent starred_at timestamp from previous fetch
 	LastFullSyncAt     time.Time `json:"last_full_sync_at"`   // Timestamp of last complete repository fetch
 	IncrementalEnabled bool      `json:"incremental_enabled"` // Whether incremental fetching is enabled
-	FullSyncInterval   int       `json:"full_sync_interval"`  // Hours between full syncs (0 = disabled)
+	FullSyncInterval   int       `json:"full_sync_interval"`  // Hours between full syncs (0 = every run)
 
 	// Audit and monitoring fields
 	LastIncrementalAt time.Time `json:"last_incremental_at"` // Timestamp of last incremental fetch
@@ -155,7 +159,7 @@ func NewUserState(username string) *UserState {
 		LastCheck:    time.Time{}, // Zero time for first run
 		Repositories: make([]Repository, 0),
 		TotalCount:   0,
-		StateVersion: "1.0.0",
+		StateVersion: CurrentStateVersion,
 		CheckCount:   0,
 
 		// Incremental fetching defaults
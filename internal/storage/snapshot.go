@@ -0,0 +1,89 @@
+package storage
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// snapshotIDFormat names snapshots after the time they were taken, in UTC
+const snapshotIDFormat = "20060102T150405.000000000Z"
+
+// Snapshot describes a saved copy of a user state, taken just before it was replaced
+type Snapshot struct {
+	ID           string    `json:"id"`           // Identifies the snapshot for LoadSnapshot
+	TakenAt      time.Time `json:"taken_at"`     // When the state was replaced
+	LastCheck    time.Time `json:"last_check"`   // LastCheck of the saved state
+	CheckCount   int       `json:"check_count"`  // CheckCount of the saved state
+	Repositories int       `json:"repositories"` // Number of starred repositories in the saved state
+	RunID        string    `json:"run_id"`       // Run that wrote the saved state
+}
+
+// SnapshotPolicy decides how many snapshots are kept per user
+type SnapshotPolicy struct {
+	Count  int           // Maximum number of snapshots; 0 disables snapshots
+	MaxAge time.Duration // Snapshots older than this are removed; 0 keeps them regardless of age
+}
+
+// DefaultSnapshotPolicy keeps the five most recent snapshots
+var DefaultSnapshotPolicy = SnapshotPolicy{Count: 5}
+
+// Expired returns the snapshots that the policy no longer keeps. snapshots must be
+// sorted newest first. The newest snapshot is only expired by a zero Count, so a bad
+// run can always be rolled back.
+func (p SnapshotPolicy) Expired(snapshots []Snapshot, now time.Time) []Snapshot {
+	if p.Count <= 0 {
+		return snapshots
+	}
+
+	var expired []Snapshot
+	for i, snapshot := range snapshots {
+		if i >= p.Count || (i > 0 && p.MaxAge > 0 && now.Sub(snapshot.TakenAt) > p.MaxAge) {
+			expired = append(expired, snapshot)
+		}
+	}
+	return expired
+}
+
+// SnapshotNotFoundError represents an error when a snapshot does not exist
+type SnapshotNotFoundError struct {
+	Username string
+	ID       string
+}
+
+func (e *SnapshotNotFoundError) Error() string {
+	return fmt.Sprintf("no snapshot %s for user %s", e.ID, e.Username)
+}
+
+// SnapshotDir returns the directory holding the snapshots of a state file,
+// e.g. ~/.star-watcher/octocat.snapshots for ~/.star-watcher/octocat.json
+func SnapshotDir(stateFilePath string) string {
+	return strings.TrimSuffix(stateFilePath, filepath.Ext(stateFilePath)) + ".snapshots"
+}
+
+// newSnapshot describes a snapshot of state taken at takenAt
+func newSnapshot(state *UserState, takenAt time.Time) Snapshot {
+	return Snapshot{
+		ID:           takenAt.UTC().Format(snapshotIDFormat),
+		TakenAt:      takenAt,
+		LastCheck:    state.LastCheck,
+		CheckCount:   state.CheckCount,
+		Repositories: state.TotalCount,
+		RunID:        state.LastRunID,
+	}
+}
+
+// snapshotTime returns now, or just after the newest existing snapshot when the clock
+// has not moved on since, so snapshot IDs stay unique and ordered
+func snapshotTime(now time.Time, snapshots []Snapshot) time.Time {
+	if len(snapshots) > 0 && !now.After(snapshots[0].TakenAt) {
+		return snapshots[0].TakenAt.Add(time.Nanosecond)
+	}
+	return now
+}
+
+// parseSnapshotID returns the time a snapshot was taken
+func parseSnapshotID(id string) (time.Time, error) {
+	return time.Parse(snapshotIDFormat, id)
+}
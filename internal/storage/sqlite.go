@@ -0,0 +1,780 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite" // Pure-Go SQLite driver
+)
+
+// sqliteTimeFormat stores timestamps in UTC with a fixed width so they sort as text
+const sqliteTimeFormat = "2006-01-02T15:04:05.000000000Z"
+
+// sqliteStarsColumns are the columns of the stars table. Repository metadata is kept per
+// user, as in the JSON state files, so one user's save never changes what another loads.
+const sqliteStarsColumns = `
+	state_key   TEXT NOT NULL REFERENCES users(state_key) ON DELETE CASCADE,
+	full_name   TEXT NOT NULL,
+	starred_at  TEXT NOT NULL,
+	description TEXT NOT NULL,
+	star_count  INTEGER NOT NULL,
+	updated_at  TEXT NOT NULL,
+	url         TEXT NOT NULL,
+	language    TEXT NOT NULL,
+	private     INTEGER NOT NULL,
+	repo_id     INTEGER NOT NULL DEFAULT 0,
+	node_id     TEXT NOT NULL DEFAULT '',
+	PRIMARY KEY (state_key, full_name)
+`
+
+// sqliteSchema creates the tables of a star-watcher database. States are keyed by username.
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS users (
+	state_key           TEXT PRIMARY KEY,
+	username            TEXT NOT NULL,
+	last_check          TEXT NOT NULL,
+	total_count         INTEGER NOT NULL,
+	state_version       TEXT NOT NULL,
+	check_count         INTEGER NOT NULL,
+	last_run_id         TEXT NOT NULL DEFAULT '',
+	last_starred_at     TEXT NOT NULL,
+	last_full_sync_at   TEXT NOT NULL,
+	incremental_enabled INTEGER NOT NULL,
+	full_sync_interval  INTEGER NOT NULL,
+	last_incremental_at TEXT NOT NULL,
+	api_calls_saved     INTEGER NOT NULL,
+	encrypted           BLOB
+);
+
+CREATE TABLE IF NOT EXISTS stars (` + sqliteStarsColumns + `);
+
+CREATE TABLE IF NOT EXISTS events (
+	id         INTEGER PRIMARY KEY AUTOINCREMENT,
+	state_key  TEXT NOT NULL,
+	run_id     TEXT NOT NULL,
+	timestamp  TEXT NOT NULL,
+	username   TEXT NOT NULL,
+	type       TEXT NOT NULL,
+	full_name  TEXT NOT NULL,
+	language   TEXT NOT NULL,
+	repository TEXT NOT NULL,
+	changes    TEXT NOT NULL DEFAULT '',
+	encrypted  BLOB,
+	previous_full_name TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX IF NOT EXISTS events_state_time ON events (state_key, timestamp);
+
+CREATE TABLE IF NOT EXISTS snapshots (
+	state_key    TEXT NOT NULL,
+	id           TEXT NOT NULL,
+	taken_at     TEXT NOT NULL,
+	last_check   TEXT NOT NULL,
+	check_count  INTEGER NOT NULL,
+	repositories INTEGER NOT NULL,
+	run_id       TEXT NOT NULL,
+	state        TEXT NOT NULL,
+	PRIMARY KEY (state_key, id)
+);
+`
+
+// sqliteColumns are the columns added since the tables were introduced. They are
+// created in older databases when those are opened, before their shared repositories
+// table is moved into stars.
+var sqliteColumns = []struct {
+	table, column, definition string
+}{
+	{"users", "encrypted", "BLOB"},
+	{"events", "encrypted", "BLOB"},
+	{"repositories", "repo_id", "INTEGER NOT NULL DEFAULT 0"},
+	{"repositories", "node_id", "TEXT NOT NULL DEFAULT ''"},
+	{"events", "previous_full_name", "TEXT NOT NULL DEFAULT ''"},
+}
+
+// sqlQuerier runs queries on the database or inside a transaction
+type sqlQuerier interface {
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+// SQLiteStorage implements the Store interface in a single SQLite database.
+// Saving a state only writes the stars and repository metadata that changed.
+type SQLiteStorage struct {
+	db        *sql.DB
+	path      string
+	snapshots SnapshotPolicy
+}
+
+// NewSQLiteStorage opens or creates the database at path
+func NewSQLiteStorage(path string) (*SQLiteStorage, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create directory for database: %v", err)
+	}
+
+	dsn := "file:" + path + "?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_pragma=foreign_keys(1)&_pragma=secure_delete(1)&_txlock=immediate"
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database %s: %v", path, err)
+	}
+
+	if err := createSQLiteSchema(db); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create schema in %s: %v", path, err)
+	}
+
+	return &SQLiteStorage{db: db, path: path, snapshots: DefaultSnapshotPolicy}, nil
+}
+
+// createSQLiteSchema creates the tables, adds the missing sqliteColumns and moves the
+// metadata of older databases into stars in one write transaction, so processes opening
+// a new database at the same time wait for each other instead of failing
+func createSQLiteSchema(db *sql.DB) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(sqliteSchema); err != nil {
+		return err
+	}
+
+	for _, c := range sqliteColumns {
+		var columns, count int
+		if err := tx.QueryRow(`SELECT COUNT(*), COUNT(CASE WHEN name = ? THEN 1 END) FROM pragma_table_info(?)`,
+			c.column, c.table).Scan(&columns, &count); err != nil {
+			return err
+		}
+		if columns > 0 && count == 0 {
+			if _, err := tx.Exec(`ALTER TABLE ` + c.table + ` ADD COLUMN ` + c.column + ` ` + c.definition); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := moveSharedRepositories(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// moveSharedRepositories copies the metadata of the repositories table, shared by all
+// users in older databases, into each user's stars and drops the table
+func moveSharedRepositories(tx *sql.Tx) error {
+	var count int
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'repositories'`).Scan(&count); err != nil {
+		return err
+	}
+	if count == 0 {
+		return nil
+	}
+
+	for _, statement := range []string{
+		`CREATE TABLE stars_with_metadata (` + sqliteStarsColumns + `)`,
+		`INSERT INTO stars_with_metadata (state_key, full_name, starred_at, description, star_count, updated_at, url, language,
+			private, repo_id, node_id)
+			SELECT s.state_key, s.full_name, s.starred_at, r.description, r.star_count, r.updated_at, r.url, r.language,
+			r.private, r.repo_id, r.node_id FROM stars s JOIN repositories r ON r.full_name = s.full_name`,
+		`DROP TABLE stars`,
+		`DROP TABLE repositories`,
+		`ALTER TABLE stars_with_metadata RENAME TO stars`,
+	} {
+		if _, err := tx.Exec(statement); err != nil {
+			return fmt.Errorf("failed to move repository metadata into stars: %v", err)
+		}
+	}
+	return nil
+}
+
+// SetSnapshotPolicy sets how many snapshots are kept per user
+func (s *SQLiteStorage) SetSnapshotPolicy(policy SnapshotPolicy) {
+	s.snapshots = policy
+}
+
+// Path returns the database file
+func (s *SQLiteStorage) Path() string {
+	return s.path
+}
+
+// Close closes the database
+func (s *SQLiteStorage) Close() error {
+	return s.db.Close()
+}
+
+// Save stores the state of a user and inserts events in a single transaction.
+// The previous state is kept as a snapshot, like the .snapshots directory of JSONStore.
+func (s *SQLiteStorage) Save(username string, state *UserState, events []Event) error {
+	if err := state.Validate(); err != nil {
+		return fmt.Errorf("invalid user state: %v", err)
+	}
+
+	key := username
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if err := s.snapshot(tx, username, time.Now()); err != nil {
+		return fmt.Errorf("failed to snapshot previous state: %v", err)
+	}
+	if err := saveUser(tx, key, state); err != nil {
+		return fmt.Errorf("failed to save user: %v", err)
+	}
+	if err := saveStars(tx, key, state.Repositories); err != nil {
+		return fmt.Errorf("failed to save repositories: %v", err)
+	}
+	if err := insertEvents(tx, key, events); err != nil {
+		return fmt.Errorf("failed to save events: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit state: %v", err)
+	}
+	return nil
+}
+
+// Load returns the state of a user
+func (s *SQLiteStorage) Load(username string) (*UserState, error) {
+	return s.load(s.db, username)
+}
+
+// load reads the state of a user with q
+func (s *SQLiteStorage) load(q sqlQuerier, username string) (*UserState, error) {
+	key := username
+	state := &UserState{}
+	var lastCheck, lastStarredAt, lastFullSyncAt, lastIncrementalAt string
+	err := q.QueryRow(`SELECT username, last_check, total_count, state_version, check_count, last_run_id,
+		last_starred_at, last_full_sync_at, incremental_enabled, full_sync_interval, last_incremental_at, api_calls_saved,
+		encrypted FROM users WHERE state_key = ?`, key).Scan(
+		&state.Username, &lastCheck, &state.TotalCount, &state.StateVersion, &state.CheckCount, &state.LastRunID,
+		&lastStarredAt, &lastFullSyncAt, &state.IncrementalEnabled, &state.FullSyncInterval, &lastIncrementalAt, &state.APICallsSaved,
+		&state.Encrypted)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, &StateNotFoundError{Username: username}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read state: %v", err)
+	}
+
+	corrupt := func(err error) error {
+		return &StateCorruptionError{FilePath: s.path, Cause: fmt.Errorf("state of %s: %v", username, err)}
+	}
+	for _, field := range []struct {
+		text string
+		dst  *time.Time
+	}{
+		{lastCheck, &state.LastCheck},
+		{lastStarredAt, &state.LastStarredAt},
+		{lastFullSyncAt, &state.LastFullSyncAt},
+		{lastIncrementalAt, &state.LastIncrementalAt},
+	} {
+		if *field.dst, err = parseSQLiteTime(field.text); err != nil {
+			return nil, corrupt(err)
+		}
+	}
+
+	rows, err := q.Query(`SELECT full_name, description, star_count, updated_at, url, starred_at, language, private,
+		repo_id, node_id FROM stars WHERE state_key = ? ORDER BY starred_at DESC, full_name`, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read repositories: %v", err)
+	}
+	defer rows.Close()
+
+	state.Repositories = make([]Repository, 0, state.TotalCount)
+	for rows.Next() {
+		var repo Repository
+		var updatedAt, starredAt string
+		if err := rows.Scan(&repo.FullName, &repo.Description, &repo.StarCount, &updatedAt, &repo.URL, &starredAt, &repo.Language, &repo.Private,
+			&repo.ID, &repo.NodeID); err != nil {
+			return nil, corrupt(err)
+		}
+		if repo.UpdatedAt, err = parseSQLiteTime(updatedAt); err != nil {
+			return nil, corrupt(err)
+		}
+		if repo.StarredAt, err = parseSQLiteTime(starredAt); err != nil {
+			return nil, corrupt(err)
+		}
+		state.Repositories = append(state.Repositories, repo)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read repositories: %v", err)
+	}
+
+	if state, err = upgradeState(s.path, state); err != nil {
+		return nil, err
+	}
+	if err := state.Validate(); err != nil {
+		return nil, corrupt(fmt.Errorf("validation failed: %v", err))
+	}
+	return state, nil
+}
+
+// Events returns the events of a user that match filter, oldest first
+func (s *SQLiteStorage) Events(username string, filter EventFilter) ([]Event, error) {
+	query := `SELECT run_id, timestamp, username, type, repository, changes, encrypted, previous_full_name FROM events WHERE state_key = ?`
+	args := []any{username}
+
+	if !filter.Since.IsZero() {
+		query += ` AND timestamp >= ?`
+		args = append(args, formatSQLiteTime(filter.Since))
+	}
+	if !filter.Until.IsZero() {
+		query += ` AND timestamp < ?`
+		args = append(args, formatSQLiteTime(filter.Until))
+	}
+	if filter.Language != "" {
+		query += ` AND language = ? COLLATE NOCASE`
+		args = append(args, filter.Language)
+	}
+	if len(filter.Types) > 0 {
+		query += ` AND type IN (?` + strings.Repeat(`, ?`, len(filter.Types)-1) + `)`
+		for _, eventType := range filter.Types {
+			args = append(args, eventType)
+		}
+	}
+	query += ` ORDER BY id`
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query events: %v", err)
+	}
+	defer rows.Close()
+
+	var events []Event
+	for rows.Next() {
+		var event Event
+		var timestamp, repository, changes string
+		if err := rows.Scan(&event.RunID, &timestamp, &event.Username, &event.Type, &repository, &changes, &event.Encrypted,
+			&event.PreviousFullName); err != nil {
+			return nil, fmt.Errorf("failed to read event: %v", err)
+		}
+		if event.Timestamp, err = parseSQLiteTime(timestamp); err != nil {
+			return nil, &StateCorruptionError{FilePath: s.path, Cause: err}
+		}
+		if err := json.Unmarshal([]byte(repository), &event.Repository); err != nil {
+			return nil, &StateCorruptionError{FilePath: s.path, Cause: err}
+		}
+		if changes != "" {
+			event.Changes = strings.Split(changes, ",")
+		}
+		events = append(events, event)
+	}
+	return events, rows.Err()
+}
+
+// List returns the users with a stored state
+func (s *SQLiteStorage) List() ([]string, error) {
+	rows, err := s.db.Query(`SELECT state_key FROM users ORDER BY state_key`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list users: %v", err)
+	}
+	defer rows.Close()
+
+	usernames := []string{}
+	for rows.Next() {
+		var username string
+		if err := rows.Scan(&username); err != nil {
+			return nil, fmt.Errorf("failed to list users: %v", err)
+		}
+		usernames = append(usernames, username)
+	}
+	return usernames, rows.Err()
+}
+
+// Delete removes the state, snapshots and events of a user
+func (s *SQLiteStorage) Delete(username string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`DELETE FROM users WHERE state_key = ?`, username)
+	if err != nil {
+		return fmt.Errorf("failed to delete state of %s: %v", username, err)
+	}
+	if deleted, _ := result.RowsAffected(); deleted == 0 {
+		return &StateNotFoundError{Username: username}
+	}
+	if _, err := tx.Exec(`DELETE FROM snapshots WHERE state_key = ?`, username); err != nil {
+		return fmt.Errorf("failed to delete snapshots of %s: %v", username, err)
+	}
+	if _, err := tx.Exec(`DELETE FROM events WHERE state_key = ?`, username); err != nil {
+		return fmt.Errorf("failed to delete events of %s: %v", username, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit delete: %v", err)
+	}
+	return nil
+}
+
+// Exists reports whether a state is stored for a user
+func (s *SQLiteStorage) Exists(username string) (bool, error) {
+	var count int
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM users WHERE state_key = ?`, username).Scan(&count); err != nil {
+		return false, fmt.Errorf("failed to check state of %s: %v", username, err)
+	}
+	return count > 0, nil
+}
+
+// Lock locks <database>.locks/<username>.lock. Transactions keep single writes
+// consistent; the lock keeps a whole run of one user from interleaving with another.
+func (s *SQLiteStorage) Lock(ctx context.Context, username string, timeout time.Duration) (*FileLock, error) {
+	return LockFile(ctx, filepath.Join(s.path+".locks", username+".lock"), timeout)
+}
+
+// snapshot copies the current state of a user into the snapshots table and removes the
+// snapshots the policy no longer keeps
+func (s *SQLiteStorage) snapshot(tx *sql.Tx, username string, now time.Time) error {
+	if s.snapshots.Count <= 0 {
+		return nil
+	}
+
+	current, err := s.load(tx, username)
+	if _, ok := err.(*StateNotFoundError); ok {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	existing, err := s.listSnapshots(tx, username)
+	if err != nil {
+		return err
+	}
+	taken := newSnapshot(current, snapshotTime(now, existing))
+	data, err := json.Marshal(current)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`INSERT INTO snapshots (state_key, id, taken_at, last_check, check_count, repositories, run_id, state)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`, username, taken.ID, formatSQLiteTime(taken.TakenAt), formatSQLiteTime(taken.LastCheck),
+		taken.CheckCount, taken.Repositories, taken.RunID, string(data)); err != nil {
+		return err
+	}
+
+	for _, expired := range s.snapshots.Expired(append([]Snapshot{taken}, existing...), now) {
+		if _, err := tx.Exec(`DELETE FROM snapshots WHERE state_key = ? AND id = ?`, username, expired.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Snapshots lists the snapshots of a user, newest first
+func (s *SQLiteStorage) Snapshots(username string) ([]Snapshot, error) {
+	snapshots, err := s.listSnapshots(s.db, username)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list snapshots: %v", err)
+	}
+	return snapshots, nil
+}
+
+// listSnapshots reads the snapshots of a user with q, newest first
+func (s *SQLiteStorage) listSnapshots(q sqlQuerier, username string) ([]Snapshot, error) {
+	rows, err := q.Query(`SELECT id, taken_at, last_check, check_count, repositories, run_id
+		FROM snapshots WHERE state_key = ? ORDER BY id DESC`, username)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var snapshots []Snapshot
+	for rows.Next() {
+		var snapshot Snapshot
+		var takenAt, lastCheck string
+		if err := rows.Scan(&snapshot.ID, &takenAt, &lastCheck, &snapshot.CheckCount, &snapshot.Repositories, &snapshot.RunID); err != nil {
+			return nil, err
+		}
+		if snapshot.TakenAt, err = parseSQLiteTime(takenAt); err != nil {
+			return nil, err
+		}
+		if snapshot.LastCheck, err = parseSQLiteTime(lastCheck); err != nil {
+			return nil, err
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+	return snapshots, rows.Err()
+}
+
+// LoadSnapshot returns the state kept in a snapshot of a user
+func (s *SQLiteStorage) LoadSnapshot(username, id string) (*UserState, error) {
+	var data string
+	err := s.db.QueryRow(`SELECT state FROM snapshots WHERE state_key = ? AND id = ?`, username, id).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, &SnapshotNotFoundError{Username: username, ID: id}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read snapshot: %v", err)
+	}
+
+	state, err := decodeState(snapshotLocation(s.path, username, id), []byte(data))
+	if err != nil {
+		return nil, err
+	}
+	if err := state.Validate(); err != nil {
+		return nil, &StateCorruptionError{FilePath: s.path, Cause: fmt.Errorf("snapshot %s of %s: validation failed: %v", id, username, err)}
+	}
+	return state, nil
+}
+
+// Rewrite applies state to the state and every snapshot of a user and event to every
+// event of the user, all in one transaction. No snapshot is taken.
+func (s *SQLiteStorage) Rewrite(username string, state func(*UserState) error, event func(*Event) error) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	current, err := s.load(tx, username)
+	if err != nil {
+		return err
+	}
+	if err := state(current); err != nil {
+		return err
+	}
+	if err := current.Validate(); err != nil {
+		return fmt.Errorf("invalid user state: %v", err)
+	}
+	if err := saveUser(tx, username, current); err != nil {
+		return fmt.Errorf("failed to save user: %v", err)
+	}
+	if err := saveStars(tx, username, current.Repositories); err != nil {
+		return fmt.Errorf("failed to save repositories: %v", err)
+	}
+
+	if err := rewriteSnapshots(tx, s.path, username, state); err != nil {
+		return err
+	}
+	if err := rewriteEvents(tx, s.path, username, event); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit state: %v", err)
+	}
+
+	// Deleted content is zeroed (secure_delete), but the write-ahead log still holds the
+	// pages as they were until it is checkpointed. Readers in other processes can hold
+	// the checkpoint back, which does not fail the rewrite.
+	s.db.Exec(`PRAGMA wal_checkpoint(TRUNCATE)`)
+	return nil
+}
+
+// rewriteSnapshots applies fn to every snapshot of a user
+func rewriteSnapshots(tx *sql.Tx, path, username string, fn func(*UserState) error) error {
+	rows, err := tx.Query(`SELECT id, state FROM snapshots WHERE state_key = ?`, username)
+	if err != nil {
+		return fmt.Errorf("failed to query snapshots: %v", err)
+	}
+	snapshots := map[string]string{}
+	for rows.Next() {
+		var id, data string
+		if err := rows.Scan(&id, &data); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to read snapshot: %v", err)
+		}
+		snapshots[id] = data
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read snapshots: %v", err)
+	}
+
+	for id, data := range snapshots {
+		state, err := decodeState(snapshotLocation(path, username, id), []byte(data))
+		if err != nil {
+			return err
+		}
+		if err := fn(state); err != nil {
+			return fmt.Errorf("snapshot %s: %w", id, err)
+		}
+		rewritten, err := json.Marshal(state)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`UPDATE snapshots SET state = ? WHERE state_key = ? AND id = ?`, string(rewritten), username, id); err != nil {
+			return fmt.Errorf("failed to update snapshot: %v", err)
+		}
+	}
+	return nil
+}
+
+// rewriteEvents applies fn to every event of a user. Only the repository of an event,
+// with its previous name, is written back.
+func rewriteEvents(tx *sql.Tx, path, username string, fn func(*Event) error) error {
+	rows, err := tx.Query(`SELECT id, run_id, timestamp, username, type, repository, changes, encrypted, previous_full_name
+		FROM events WHERE state_key = ? ORDER BY id`, username)
+	if err != nil {
+		return fmt.Errorf("failed to query events: %v", err)
+	}
+	var ids []int64
+	var events []Event
+	for rows.Next() {
+		var id int64
+		var event Event
+		var timestamp, repository, changes string
+		if err := rows.Scan(&id, &event.RunID, &timestamp, &event.Username, &event.Type, &repository, &changes, &event.Encrypted,
+			&event.PreviousFullName); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to read event: %v", err)
+		}
+		if event.Timestamp, err = parseSQLiteTime(timestamp); err != nil {
+			rows.Close()
+			return &StateCorruptionError{FilePath: path, Cause: err}
+		}
+		if err := json.Unmarshal([]byte(repository), &event.Repository); err != nil {
+			rows.Close()
+			return &StateCorruptionError{FilePath: path, Cause: err}
+		}
+		if changes != "" {
+			event.Changes = strings.Split(changes, ",")
+		}
+		ids = append(ids, id)
+		events = append(events, event)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read events: %v", err)
+	}
+
+	for i := range events {
+		if err := fn(&events[i]); err != nil {
+			return err
+		}
+		repository, err := json.Marshal(events[i].Repository)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`UPDATE events SET full_name = ?, language = ?, repository = ?, encrypted = ?, previous_full_name = ? WHERE id = ?`,
+			events[i].Repository.FullName, events[i].Repository.Language, string(repository), events[i].Encrypted,
+			events[i].PreviousFullName, ids[i]); err != nil {
+			return fmt.Errorf("failed to update event: %v", err)
+		}
+	}
+	return nil
+}
+
+// snapshotLocation names a snapshot in a database for errors
+func snapshotLocation(path, username, id string) string {
+	return fmt.Sprintf("%s (snapshot %s of %s)", path, id, username)
+}
+
+// saveUser inserts or replaces the user row of a state
+func saveUser(tx *sql.Tx, key string, state *UserState) error {
+	_, err := tx.Exec(`INSERT INTO users (state_key, username, last_check, total_count, state_version, check_count,
+		last_run_id, last_starred_at, last_full_sync_at, incremental_enabled, full_sync_interval, last_incremental_at,
+		api_calls_saved, encrypted) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (state_key) DO UPDATE SET username = excluded.username, last_check = excluded.last_check,
+		total_count = excluded.total_count, state_version = excluded.state_version, check_count = excluded.check_count,
+		last_run_id = excluded.last_run_id, last_starred_at = excluded.last_starred_at,
+		last_full_sync_at = excluded.last_full_sync_at, incremental_enabled = excluded.incremental_enabled,
+		full_sync_interval = excluded.full_sync_interval, last_incremental_at = excluded.last_incremental_at,
+		api_calls_saved = excluded.api_calls_saved, encrypted = excluded.encrypted`,
+		key, state.Username, formatSQLiteTime(state.LastCheck), state.TotalCount, state.StateVersion, state.CheckCount,
+		state.LastRunID, formatSQLiteTime(state.LastStarredAt), formatSQLiteTime(state.LastFullSyncAt),
+		state.IncrementalEnabled, state.FullSyncInterval, formatSQLiteTime(state.LastIncrementalAt), state.APICallsSaved,
+		state.Encrypted)
+	return err
+}
+
+// saveStars brings the stars of a state in line with repos, writing only what changed
+func saveStars(tx *sql.Tx, key string, repos []Repository) error {
+	existing := make(map[string]bool)
+	rows, err := tx.Query(`SELECT full_name FROM stars WHERE state_key = ?`, key)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var fullName string
+		if err := rows.Scan(&fullName); err != nil {
+			rows.Close()
+			return err
+		}
+		existing[fullName] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	upsertStar, err := tx.Prepare(`INSERT INTO stars (state_key, full_name, starred_at, description, star_count, updated_at, url,
+		language, private, repo_id, node_id) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (state_key, full_name) DO UPDATE SET starred_at = excluded.starred_at, description = excluded.description,
+		star_count = excluded.star_count, updated_at = excluded.updated_at, url = excluded.url, language = excluded.language,
+		private = excluded.private, repo_id = excluded.repo_id, node_id = excluded.node_id
+		WHERE starred_at != excluded.starred_at OR description != excluded.description OR star_count != excluded.star_count
+		OR updated_at != excluded.updated_at OR url != excluded.url OR language != excluded.language
+		OR private != excluded.private OR repo_id != excluded.repo_id OR node_id != excluded.node_id`)
+	if err != nil {
+		return err
+	}
+	defer upsertStar.Close()
+
+	for _, repo := range repos {
+		if _, err := upsertStar.Exec(key, repo.FullName, formatSQLiteTime(repo.StarredAt), repo.Description, repo.StarCount,
+			formatSQLiteTime(repo.UpdatedAt), repo.URL, repo.Language, repo.Private, repo.ID, repo.NodeID); err != nil {
+			return err
+		}
+		delete(existing, repo.FullName)
+	}
+
+	// Whatever is left is no longer starred
+	for fullName := range existing {
+		if _, err := tx.Exec(`DELETE FROM stars WHERE state_key = ? AND full_name = ?`, key, fullName); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// insertEvents appends events to the events table
+func insertEvents(tx *sql.Tx, key string, events []Event) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	insert, err := tx.Prepare(`INSERT INTO events (state_key, run_id, timestamp, username, type, full_name, language, repository, changes,
+		encrypted, previous_full_name) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return err
+	}
+	defer insert.Close()
+
+	for _, event := range events {
+		repository, err := json.Marshal(event.Repository)
+		if err != nil {
+			return err
+		}
+		if _, err := insert.Exec(key, event.RunID, formatSQLiteTime(event.Timestamp), event.Username, event.Type,
+			event.Repository.FullName, event.Repository.Language, string(repository), strings.Join(event.Changes, ","), event.Encrypted,
+			event.PreviousFullName); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// formatSQLiteTime formats t for storage
+func formatSQLiteTime(t time.Time) string {
+	return t.UTC().Format(sqliteTimeFormat)
+}
+
+// parseSQLiteTime parses a stored timestamp
+func parseSQLiteTime(text string) (time.Time, error) {
+	t, err := time.Parse(sqliteTimeFormat, text)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid timestamp %q: %v", text, err)
+	}
+	return t, nil
+}
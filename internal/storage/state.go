@@ -1,5 +1,14 @@
 package storage
 
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
 // StateStorage defines the interface for persisting and loading user state
 type StateStorage interface {
 	// SaveUserState persists user state to the specified file path
@@ -11,6 +20,53 @@ type StateStorage interface {
 	LoadUserState(filePath string) (*UserState, error)
 }
 
+// Store persists the state and event history of each monitored user, keyed by GitHub
+// username. Where and how a user's state is kept is a detail of the implementation.
+type Store interface {
+	// Load returns the state of a user, or a *StateNotFoundError when none is stored
+	Load(username string) (*UserState, error)
+
+	// Save stores the state of a user and records the events of the same run.
+	// Either both are stored or neither is. The replaced state is kept as a snapshot.
+	Save(username string, state *UserState, events []Event) error
+
+	// Snapshots lists the snapshots of a user's replaced states, newest first
+	Snapshots(username string) ([]Snapshot, error)
+
+	// LoadSnapshot returns the state kept in a snapshot, or a *SnapshotNotFoundError
+	LoadSnapshot(username, id string) (*UserState, error)
+
+	// Events returns the recorded events of a user that match filter, oldest first
+	Events(username string, filter EventFilter) ([]Event, error)
+
+	// List returns the users with a stored state in alphabetical order
+	List() ([]string, error)
+
+	// Delete removes the state, snapshots and events of a user.
+	// It returns a *StateNotFoundError when no state is stored.
+	Delete(username string) error
+
+	// Exists reports whether a state is stored for a user
+	Exists(username string) (bool, error)
+
+	// Lock takes the lock of a user shared by every process using the store, waiting up
+	// to timeout. Hold it from loading a state until the new state is saved.
+	// It returns a *LockTimeoutError when another process keeps the lock.
+	Lock(ctx context.Context, username string, timeout time.Duration) (*FileLock, error)
+
+	// Close releases the resources held by the store
+	Close() error
+}
+
+// StateNotFoundError represents an error when no state is stored for a user
+type StateNotFoundError struct {
+	Username string
+}
+
+func (e *StateNotFoundError) Error() string {
+	return "no state stored for user " + e.Username
+}
+
 // StateFileNotFoundError represents an error when state file doesn't exist
 type StateFileNotFoundError struct {
 	FilePath string
@@ -29,3 +85,48 @@ type StateCorruptionError struct {
 func (e *StateCorruptionError) Error() string {
 	return "state file corrupted at " + e.FilePath + ": " + e.Cause.Error()
 }
+
+// Options configures the store returned by Open
+type Options struct {
+	Snapshots   SnapshotPolicy // Snapshots kept of replaced states
+	Compression Compression    // Compression of JSON state files; SQLite ignores it
+}
+
+// DefaultOptions returns the options of a store without configuration
+func DefaultOptions() Options {
+	return Options{Snapshots: DefaultSnapshotPolicy, Compression: CompressionNone}
+}
+
+// Open returns the store selected by url: "json" (or empty) for a JSONStore in stateDir
+// and "sqlite://path" for SQLiteStorage. A leading ~ in the path is the home directory
+// and an empty path is star-watcher.db in stateDir.
+func Open(url, stateDir string, options Options) (Store, error) {
+	if url == "" || url == "json" {
+		store := NewJSONStore(stateDir)
+		store.SetSnapshotPolicy(options.Snapshots)
+		store.SetCompression(options.Compression)
+		return store, nil
+	}
+
+	path, ok := strings.CutPrefix(url, "sqlite://")
+	if !ok {
+		return nil, fmt.Errorf("unsupported storage %q: must be json or sqlite://path", url)
+	}
+
+	if path == "" {
+		path = filepath.Join(stateDir, "star-watcher.db")
+	} else if path == "~" || strings.HasPrefix(path, "~/") {
+		homeDir, err := os.UserHomeDir()
+		if err != nil {
+			return nil, fmt.Errorf("failed to expand %s: %v", path, err)
+		}
+		path = filepath.Join(homeDir, path[1:])
+	}
+
+	store, err := NewSQLiteStorage(path)
+	if err != nil {
+		return nil, err
+	}
+	store.SetSnapshotPolicy(options.Snapshots)
+	return store, nil
+}
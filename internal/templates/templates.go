@@ -0,0 +1,184 @@
+// Package templates renders monitor results with user-supplied Go templates. The same
+// template files are used by the terminal output and the chat notification sinks.
+package templates
+
+import (
+	"bytes"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	texttemplate "text/template"
+	"time"
+	"unicode/utf8"
+)
+
+// executor is the part of text/template and html/template used for rendering
+type executor interface {
+	Execute(w io.Writer, data any) error
+}
+
+// Template is a parsed text/template or html/template. Templates receive a
+// *monitor.MonitorResult and can use the helpers returned by Funcs.
+type Template struct {
+	name string
+	tmpl executor
+}
+
+// Load parses the template file at path. Files ending in .html or .htm are parsed with
+// html/template, which escapes values for HTML; everything else uses text/template.
+func Load(path string) (*Template, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read template: %v", err)
+	}
+
+	ext := strings.ToLower(filepath.Ext(path))
+	return Parse(filepath.Base(path), string(data), ext == ".html" || ext == ".htm")
+}
+
+// Parse parses a template from text, using html/template when html is set
+func Parse(name, text string, html bool) (*Template, error) {
+	var tmpl executor
+	var err error
+	if html {
+		tmpl, err = htmltemplate.New(name).Funcs(htmltemplate.FuncMap(Funcs())).Parse(text)
+	} else {
+		tmpl, err = texttemplate.New(name).Funcs(Funcs()).Parse(text)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse template %s: %v", name, err)
+	}
+	return &Template{name: name, tmpl: tmpl}, nil
+}
+
+// Name returns the file name of the template
+func (t *Template) Name() string {
+	return t.name
+}
+
+// Execute renders data to w
+func (t *Template) Execute(w io.Writer, data any) error {
+	if err := t.tmpl.Execute(w, data); err != nil {
+		return fmt.Errorf("failed to render template %s: %v", t.name, err)
+	}
+	return nil
+}
+
+// Render renders data to a string
+func (t *Template) Render(data any) (string, error) {
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// Funcs returns the helper functions available to templates:
+//
+//	humanize  abbreviates counts: {{humanize .StarCount}} -> 1.2k
+//	ago       relative time: {{ago .StarredAt}} -> 3 hours ago
+//	truncate  shortens text: {{.Description | truncate 80}}
+//	markdown  escapes Markdown formatting characters: {{markdown .Description}}
+//	date      formats a time: {{date "2006-01-02" .StarredAt}}
+//	join      joins strings: {{join ", " .Names}}
+//	upper, lower
+func Funcs() texttemplate.FuncMap {
+	return texttemplate.FuncMap{
+		"humanize": Humanize,
+		"ago":      RelativeTime,
+		"truncate": func(max int, s string) string { return Truncate(s, max) },
+		"markdown": EscapeMarkdown,
+		"date":     func(layout string, t time.Time) string { return t.Format(layout) },
+		"join":     func(sep string, elems []string) string { return strings.Join(elems, sep) },
+		"upper":    strings.ToUpper,
+		"lower":    strings.ToLower,
+	}
+}
+
+// Humanize abbreviates large counts (1234 -> 1.2k, 12345 -> 12k, 1234567 -> 1.2M)
+func Humanize(count int) string {
+	switch {
+	case count >= 1_000_000:
+		return fmt.Sprintf("%.1fM", float64(count)/1_000_000)
+	case count >= 10_000:
+		return fmt.Sprintf("%dk", count/1000)
+	case count >= 1000:
+		return fmt.Sprintf("%.1fk", float64(count)/1000)
+	default:
+		return fmt.Sprintf("%d", count)
+	}
+}
+
+// RelativeTime describes t relative to now, e.g. "5 minutes ago" or "in 2 hours"
+func RelativeTime(t time.Time) string {
+	return relativeTime(t, time.Now())
+}
+
+// relativeTime describes t relative to now
+func relativeTime(t, now time.Time) string {
+	if t.IsZero() {
+		return "never"
+	}
+
+	d := now.Sub(t)
+	future := d < 0
+	if future {
+		d = -d
+	}
+
+	var amount string
+	switch {
+	case d < time.Minute:
+		return "just now"
+	case d < time.Hour:
+		amount = plural(int(d/time.Minute), "minute")
+	case d < 24*time.Hour:
+		amount = plural(int(d/time.Hour), "hour")
+	case d < 30*24*time.Hour:
+		amount = plural(int(d/(24*time.Hour)), "day")
+	case d < 365*24*time.Hour:
+		amount = plural(int(d/(30*24*time.Hour)), "month")
+	default:
+		amount = plural(int(d/(365*24*time.Hour)), "year")
+	}
+
+	if future {
+		return "in " + amount
+	}
+	return amount + " ago"
+}
+
+// plural formats n with unit, adding an s unless n is 1
+func plural(n int, unit string) string {
+	if n == 1 {
+		return "1 " + unit
+	}
+	return fmt.Sprintf("%d %ss", n, unit)
+}
+
+// Truncate shortens s to at most max characters, marking the cut with an ellipsis
+func Truncate(s string, max int) string {
+	if max <= 0 {
+		return ""
+	}
+	if utf8.RuneCountInString(s) <= max {
+		return s
+	}
+	runes := []rune(s)
+	return string(runes[:max-1]) + "…"
+}
+
+// markdownEscaper escapes characters with a formatting meaning in Markdown and the
+// Slack and Discord dialects
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`, "*", `\*`, "_", `\_`, "`", "\\`", "~", `\~`,
+	"[", `\[`, "]", `\]`, "|", `\|`, ">", `\>`, "#", `\#`,
+)
+
+// EscapeMarkdown escapes s so it is shown literally in Markdown
+func EscapeMarkdown(s string) string {
+	return markdownEscaper.Replace(s)
+}
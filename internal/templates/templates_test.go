@@ -0,0 +1,112 @@
+package templates
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/akme/gh-stars-watcher/internal/monitor"
+	"github.com/akme/gh-stars-watcher/internal/storage"
+)
+
+func testResult() *monitor.MonitorResult {
+	return &monitor.MonitorResult{
+		Username:          "octocat",
+		TotalRepositories: 42,
+		Changes: &monitor.RepositoryChanges{
+			NewStars: []storage.Repository{{
+				FullName:    "golang/go",
+				Description: "The Go <programming> language",
+				StarCount:   123456,
+				StarredAt:   time.Now().Add(-3 * time.Hour),
+			}},
+			TotalChanges: 1,
+		},
+	}
+}
+
+func TestLoad_TextTemplateWithHelpers(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "stars.tmpl")
+	text := `{{.Username}} ({{.TotalRepositories}}){{range .Changes.NewStars}}
+{{upper .FullName}} {{humanize .StarCount}} {{ago .StarredAt}} {{.Description | truncate 10}}{{end}}`
+	if err := os.WriteFile(path, []byte(text), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tmpl, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	got, err := tmpl.Render(testResult())
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+
+	want := "octocat (42)\nGOLANG/GO 123k 3 hours ago The Go <p…"
+	if got != want {
+		t.Errorf("Render = %q, want %q", got, want)
+	}
+}
+
+func TestLoad_HTMLTemplateEscapes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "stars.html")
+	if err := os.WriteFile(path, []byte(`{{range .Changes.NewStars}}<b>{{.Description}}</b>{{end}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tmpl, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	got, err := tmpl.Render(testResult())
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	if want := "<b>The Go &lt;programming&gt; language</b>"; got != want {
+		t.Errorf("Render = %q, want %q", got, want)
+	}
+}
+
+func TestLoad_ReportsErrors(t *testing.T) {
+	if _, err := Load(filepath.Join(t.TempDir(), "missing.tmpl")); err == nil {
+		t.Error("Load of a missing file succeeded")
+	}
+
+	if _, err := Parse("broken.tmpl", "{{.Username", false); err == nil || !strings.Contains(err.Error(), "broken.tmpl") {
+		t.Errorf("Parse error = %v, want error naming the template", err)
+	}
+
+	tmpl, err := Parse("field.tmpl", "{{.NoSuchField}}", false)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if _, err := tmpl.Render(testResult()); err == nil {
+		t.Error("Render with an unknown field succeeded")
+	}
+}
+
+func TestHelpers(t *testing.T) {
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		got, want string
+	}{
+		{Humanize(999), "999"},
+		{Humanize(1234), "1.2k"},
+		{Humanize(56789), "56k"},
+		{Humanize(2_500_000), "2.5M"},
+		{relativeTime(now.Add(-30*time.Second), now), "just now"},
+		{relativeTime(now.Add(-time.Minute), now), "1 minute ago"},
+		{relativeTime(now.Add(-50*time.Hour), now), "2 days ago"},
+		{relativeTime(now.Add(2*time.Hour), now), "in 2 hours"},
+		{relativeTime(time.Time{}, now), "never"},
+		{Truncate("héllo wörld", 5), "héll…"},
+		{Truncate("short", 10), "short"},
+		{EscapeMarkdown("*bold* _x_ [a](b) `c`"), "\\*bold\\* \\_x\\_ \\[a\\](b) \\`c\\`"},
+	} {
+		if tc.got != tc.want {
+			t.Errorf("got %q, want %q", tc.got, tc.want)
+		}
+	}
+}
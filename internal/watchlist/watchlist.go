@@ -0,0 +1,213 @@
+// Package watchlist keeps the GitHub users to monitor, with per-user metadata, in the
+// state directory
+package watchlist
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// FileName is the name of the watchlist inside the state directory
+const FileName = "watchlist.json"
+
+// Entry is a watched user and the settings that apply only to them
+type Entry struct {
+	Username string    `json:"username"`
+	AddedAt  time.Time `json:"added_at"`
+
+	// Labels are free-form tags for grouping users, e.g. "team" or "friends"
+	Labels []string `json:"labels,omitempty"`
+
+	// Notify names the notification sinks (webhook, slack, discord, email, exec) that
+	// receive this user's changes; empty sends them to every configured sink
+	Notify []string `json:"notify,omitempty"`
+
+	// FullSyncInterval overrides incremental.full_sync_interval for this user, in hours
+	// (0 = every run); nil uses the config
+	FullSyncInterval *int `json:"full_sync_interval,omitempty"`
+}
+
+// HasLabel reports whether the entry is tagged with label, ignoring case
+func (e Entry) HasLabel(label string) bool {
+	for _, l := range e.Labels {
+		if strings.EqualFold(l, label) {
+			return true
+		}
+	}
+	return false
+}
+
+// Watchlist is the set of watched users, stored as JSON at its path
+type Watchlist struct {
+	path    string
+	entries []Entry // Sorted by username
+}
+
+// Load reads the watchlist at path; a missing file yields an empty watchlist
+func Load(path string) (*Watchlist, error) {
+	w := &Watchlist{path: path}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return w, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read watchlist: %v", err)
+	}
+
+	var persisted struct {
+		Users []Entry `json:"users"`
+	}
+	if err := json.Unmarshal(data, &persisted); err != nil {
+		return nil, fmt.Errorf("failed to parse watchlist %s: %v", path, err)
+	}
+	w.entries = persisted.Users
+	w.sort()
+	return w, nil
+}
+
+// Save writes the watchlist atomically
+func (w *Watchlist) Save() error {
+	persisted := struct {
+		Users []Entry `json:"users"`
+	}{Users: w.entries}
+	if persisted.Users == nil {
+		persisted.Users = []Entry{}
+	}
+
+	data, err := json.MarshalIndent(persisted, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode watchlist: %v", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(w.path), 0o755); err != nil {
+		return fmt.Errorf("failed to create watchlist directory: %v", err)
+	}
+
+	tempFile := w.path + ".tmp"
+	if err := os.WriteFile(tempFile, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write watchlist: %v", err)
+	}
+	if err := os.Rename(tempFile, w.path); err != nil {
+		os.Remove(tempFile)
+		return fmt.Errorf("failed to rename watchlist file: %v", err)
+	}
+	return nil
+}
+
+// Entries returns the watched users ordered by username
+func (w *Watchlist) Entries() []Entry {
+	return append([]Entry(nil), w.entries...)
+}
+
+// Usernames returns the watched usernames in order
+func (w *Watchlist) Usernames() []string {
+	usernames := make([]string, len(w.entries))
+	for i, entry := range w.entries {
+		usernames[i] = entry.Username
+	}
+	return usernames
+}
+
+// Get returns the entry of username, ignoring case
+func (w *Watchlist) Get(username string) (Entry, bool) {
+	if i := w.index(username); i >= 0 {
+		return w.entries[i], true
+	}
+	return Entry{}, false
+}
+
+// Put adds entry, or replaces the entry of the same user. It reports whether the user
+// was added rather than replaced.
+func (w *Watchlist) Put(entry Entry) bool {
+	if i := w.index(entry.Username); i >= 0 {
+		w.entries[i] = entry
+		return false
+	}
+	w.entries = append(w.entries, entry)
+	w.sort()
+	return true
+}
+
+// Remove removes username and reports whether it was watched
+func (w *Watchlist) Remove(username string) bool {
+	i := w.index(username)
+	if i < 0 {
+		return false
+	}
+	w.entries = append(w.entries[:i], w.entries[i+1:]...)
+	return true
+}
+
+// FullSyncIntervals returns the full sync interval of every user that overrides it,
+// keyed by lowercase username
+func (w *Watchlist) FullSyncIntervals() map[string]int {
+	intervals := make(map[string]int)
+	for _, entry := range w.entries {
+		if entry.FullSyncInterval != nil {
+			intervals[strings.ToLower(entry.Username)] = *entry.FullSyncInterval
+		}
+	}
+	return intervals
+}
+
+// Routes returns the notification sinks of every user limited to some of them, keyed by
+// lowercase username
+func (w *Watchlist) Routes() map[string][]string {
+	routes := make(map[string][]string)
+	for _, entry := range w.entries {
+		if len(entry.Notify) > 0 {
+			routes[strings.ToLower(entry.Username)] = entry.Notify
+		}
+	}
+	return routes
+}
+
+// index returns the position of username, ignoring case, or -1
+func (w *Watchlist) index(username string) int {
+	for i, entry := range w.entries {
+		if strings.EqualFold(entry.Username, username) {
+			return i
+		}
+	}
+	return -1
+}
+
+func (w *Watchlist) sort() {
+	sort.Slice(w.entries, func(i, j int) bool {
+		return strings.ToLower(w.entries[i].Username) < strings.ToLower(w.entries[j].Username)
+	})
+}
+
+// ReadUsersFile reads usernames from a file with one username per line. Blank lines and
+// everything after a "#" are ignored; repeated usernames are returned once.
+func ReadUsersFile(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open users file: %v", err)
+	}
+	defer file.Close()
+
+	var usernames []string
+	seen := make(map[string]bool)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		username := strings.TrimSpace(line)
+		if username == "" || seen[strings.ToLower(username)] {
+			continue
+		}
+		seen[strings.ToLower(username)] = true
+		usernames = append(usernames, username)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read users file %s: %v", path, err)
+	}
+	return usernames, nil
+}
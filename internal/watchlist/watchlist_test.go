@@ -0,0 +1,83 @@
+package watchlist
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestWatchlist_PutRemoveAndReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), FileName)
+	w, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load of a missing watchlist = %v", err)
+	}
+	if len(w.Entries()) != 0 {
+		t.Fatalf("missing watchlist has entries %v", w.Entries())
+	}
+
+	interval := 6
+	added := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	if !w.Put(Entry{Username: "torvalds", AddedAt: added}) {
+		t.Error("Put of a new user reported a replacement")
+	}
+	w.Put(Entry{Username: "octocat", AddedAt: added, Labels: []string{"GitHub"}, Notify: []string{"slack"}, FullSyncInterval: &interval})
+	if w.Put(Entry{Username: "Torvalds", AddedAt: added, Labels: []string{"kernel"}}) {
+		t.Error("Put of a watched user in another case reported an addition")
+	}
+	if err := w.Save(); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	reloaded, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if got := reloaded.Usernames(); !reflect.DeepEqual(got, []string{"octocat", "Torvalds"}) {
+		t.Errorf("Usernames = %v, want [octocat Torvalds]", got)
+	}
+	if entry, ok := reloaded.Get("OCTOCAT"); !ok || !entry.HasLabel("github") || !entry.AddedAt.Equal(added) {
+		t.Errorf("Get(OCTOCAT) = %+v, %v; want octocat labelled GitHub", entry, ok)
+	}
+	if got := reloaded.FullSyncIntervals(); !reflect.DeepEqual(got, map[string]int{"octocat": 6}) {
+		t.Errorf("FullSyncIntervals = %v, want octocat every 6 hours", got)
+	}
+	if got := reloaded.Routes(); !reflect.DeepEqual(got, map[string][]string{"octocat": {"slack"}}) {
+		t.Errorf("Routes = %v, want octocat to slack only", got)
+	}
+
+	if !reloaded.Remove("torvalds") || reloaded.Remove("torvalds") {
+		t.Error("Remove did not report removing torvalds exactly once")
+	}
+	if got := reloaded.Usernames(); !reflect.DeepEqual(got, []string{"octocat"}) {
+		t.Errorf("Usernames after Remove = %v, want [octocat]", got)
+	}
+}
+
+func TestReadUsersFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users.txt")
+	content := `# Team members
+octocat
+  torvalds   # kernel
+
+OctoCat
+gaearon#react
+`
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	usernames, err := ReadUsersFile(path)
+	if err != nil {
+		t.Fatalf("ReadUsersFile failed: %v", err)
+	}
+	if want := []string{"octocat", "torvalds", "gaearon"}; !reflect.DeepEqual(usernames, want) {
+		t.Errorf("ReadUsersFile = %v, want %v", usernames, want)
+	}
+
+	if _, err := ReadUsersFile(filepath.Join(t.TempDir(), "missing.txt")); err == nil {
+		t.Error("ReadUsersFile of a missing file succeeded")
+	}
+}
@@ -0,0 +1,167 @@
+package contract
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/akme/gh-stars-watcher/internal/storage"
+)
+
+// compressionMagic are the bytes each compression starts a state file with
+var compressionMagic = map[storage.Compression][]byte{
+	storage.CompressionNone: []byte("{"),
+	storage.CompressionGzip: {0x1f, 0x8b},
+	storage.CompressionZstd: {0x28, 0xb5, 0x2f, 0xfd},
+}
+
+// TestStateCompression validates that compressed state files round-trip and that files
+// are loaded whatever compression they were written with
+func TestStateCompression(t *testing.T) {
+	state := syntheticState("octocat", 100)
+
+	for _, compression := range []storage.Compression{storage.CompressionNone, storage.CompressionGzip, storage.CompressionZstd} {
+		t.Run(string(compression), func(t *testing.T) {
+			dir := t.TempDir()
+			store := storage.NewJSONStore(dir)
+			store.SetCompression(compression)
+
+			if err := store.Save("octocat", state, nil); err != nil {
+				t.Fatalf("Save failed: %v", err)
+			}
+			data, err := os.ReadFile(store.Path("octocat"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.HasPrefix(data, compressionMagic[compression]) {
+				t.Errorf("state file starts with % x, want %s", data[:4], compression)
+			}
+
+			// A store configured differently still reads the file, its snapshots and its user
+			for _, other := range []storage.Compression{storage.CompressionNone, storage.CompressionZstd} {
+				reader := storage.NewJSONStore(dir)
+				reader.SetCompression(other)
+				loaded, err := reader.Load("octocat")
+				if err != nil {
+					t.Fatalf("Load with %s configured failed: %v", other, err)
+				}
+				if len(loaded.Repositories) != 100 || loaded.Repositories[99].FullName != state.Repositories[99].FullName {
+					t.Errorf("loaded %d repositories, want the saved 100", len(loaded.Repositories))
+				}
+				if usernames, err := reader.List(); err != nil || len(usernames) != 1 || usernames[0] != "octocat" {
+					t.Errorf("List = %v, %v; want [octocat]", usernames, err)
+				}
+			}
+
+			if err := store.Save("octocat", state, nil); err != nil {
+				t.Fatalf("Save failed: %v", err)
+			}
+			snapshots, err := store.Snapshots("octocat")
+			if err != nil || len(snapshots) != 1 || snapshots[0].Repositories != 100 {
+				t.Fatalf("Snapshots = %+v, %v; want one of 100 repositories", snapshots, err)
+			}
+			if _, err := store.LoadSnapshot("octocat", snapshots[0].ID); err != nil {
+				t.Errorf("LoadSnapshot failed: %v", err)
+			}
+		})
+	}
+
+	t.Run("ExtensionSelectsCompression", func(t *testing.T) {
+		for ext, compression := range map[string]storage.Compression{".gz": storage.CompressionGzip, ".zst": storage.CompressionZstd} {
+			store := storage.NewJSONFileStore(filepath.Join(t.TempDir(), "state.json"+ext))
+			if err := store.Save("octocat", state, nil); err != nil {
+				t.Fatalf("Save failed: %v", err)
+			}
+			data, err := os.ReadFile(store.Path("octocat"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.HasPrefix(data, compressionMagic[compression]) {
+				t.Errorf("%s file starts with % x, want %s", ext, data[:4], compression)
+			}
+		}
+	})
+
+	t.Run("CorruptCompressedFile", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "corrupt.json")
+		if err := os.WriteFile(path, []byte{0x1f, 0x8b, 0x08, 0x00, 0x01}, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		_, err := storage.NewJSONStorage().LoadUserState(path)
+		if _, ok := err.(*storage.StateCorruptionError); !ok {
+			t.Errorf("Expected *StateCorruptionError, got %T: %v", err, err)
+		}
+	})
+
+	if _, err := storage.ParseCompression("brotli"); err == nil {
+		t.Error("ParseCompression of an unsupported compression succeeded")
+	}
+}
+
+// BenchmarkStateCompression compares the file size, save and load times of a heavy
+// starrer's state (50k repositories) for each compression. Run it with
+//
+//	go test -run '^$' -bench StateCompression ./tests/contract/
+func BenchmarkStateCompression(b *testing.B) {
+	state := syntheticState("heavystarrer", 50000)
+
+	for _, compression := range []storage.Compression{storage.CompressionNone, storage.CompressionGzip, storage.CompressionZstd} {
+		store := storage.NewJSONStorage()
+		store.SetSnapshotPolicy(storage.SnapshotPolicy{})
+		store.SetCompression(compression)
+		path := filepath.Join(b.TempDir(), "state.json")
+
+		b.Run(string(compression)+"/Save", func(b *testing.B) {
+			for b.Loop() {
+				if err := store.SaveUserState(path, state); err != nil {
+					b.Fatal(err)
+				}
+			}
+			info, err := os.Stat(path)
+			if err != nil {
+				b.Fatal(err)
+			}
+			b.ReportMetric(float64(info.Size()), "file-bytes")
+		})
+
+		b.Run(string(compression)+"/Load", func(b *testing.B) {
+			for b.Loop() {
+				if _, err := store.LoadUserState(path); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// syntheticState returns a valid state of n starred repositories with realistic metadata
+func syntheticState(username string, n int) *storage.UserState {
+	languages := []string{"Go", "Rust", "TypeScript", "Python", "C", ""}
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	repos := make([]storage.Repository, n)
+	for i := range repos {
+		name := fmt.Sprintf("owner-%d/project-%d", i%997, i)
+		repos[i] = storage.Repository{
+			FullName:    name,
+			Description: fmt.Sprintf("A %s library number %d for doing useful things", languages[i%len(languages)], i),
+			StarCount:   (i * 7919) % 100000,
+			UpdatedAt:   now.Add(-time.Duration(i) * time.Hour),
+			URL:         "https://github.com/" + name,
+			StarredAt:   now.Add(-time.Duration(i) * time.Minute),
+			Language:    languages[i%len(languages)],
+		}
+	}
+
+	return &storage.UserState{
+		Username:     username,
+		LastCheck:    now,
+		Repositories: repos,
+		TotalCount:   n,
+		StateVersion: "1.0.0",
+		CheckCount:   1,
+	}
+}
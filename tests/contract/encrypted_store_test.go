@@ -0,0 +1,300 @@
+package contract
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/akme/gh-stars-watcher/internal/monitor"
+	"github.com/akme/gh-stars-watcher/internal/notify"
+	"github.com/akme/gh-stars-watcher/internal/storage"
+)
+
+// TestEncryptedStore validates state encryption on top of every backend
+func TestEncryptedStore(t *testing.T) {
+	backends := []struct {
+		name string
+		open func(t *testing.T, dir string) storage.Store
+	}{
+		{"JSON", func(t *testing.T, dir string) storage.Store {
+			return storage.NewJSONStore(dir)
+		}},
+		{"SQLite", func(t *testing.T, dir string) storage.Store {
+			store, err := storage.NewSQLiteStorage(filepath.Join(dir, "state.db"))
+			if err != nil {
+				t.Fatalf("Failed to open SQLite storage: %v", err)
+			}
+			return store
+		}},
+	}
+
+	for _, backend := range backends {
+		t.Run(backend.name, func(t *testing.T) {
+			testEncryptedStore(t, func(dir string) storage.Store { return backend.open(t, dir) })
+		})
+	}
+}
+
+func testEncryptedStore(t *testing.T, open func(dir string) storage.Store) {
+	keyA, keyB := mustStateKey(t), mustStateKey(t)
+	cipherA, cipherB := mustStateCipher(t, keyA), mustStateCipher(t, keyB)
+	with := func(c *storage.StateCipher) storage.CipherSource {
+		return func() (*storage.StateCipher, error) { return c, nil }
+	}
+
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	state := func(checkCount int, names ...string) *storage.UserState {
+		state := &storage.UserState{Username: "octocat", LastCheck: now, StateVersion: "1.0.0", CheckCount: checkCount}
+		for _, name := range names {
+			state.Repositories = append(state.Repositories, storage.Repository{
+				FullName: name, URL: "https://github.com/" + name, Language: "Go", StarredAt: now, UpdatedAt: now,
+			})
+		}
+		state.TotalCount = len(state.Repositories)
+		return state
+	}
+	star := func(name, language string) storage.Event {
+		return storage.Event{RunID: "run-1", Timestamp: now, Username: "octocat", Type: storage.EventStar,
+			Repository: storage.Repository{FullName: name, Language: language}}
+	}
+
+	t.Run("SealedAtRest", func(t *testing.T) {
+		dir := t.TempDir()
+		store := storage.NewEncryptedStore(open(dir), with(cipherA), true)
+		defer store.Close()
+
+		if err := store.Save("octocat", state(1, "secret/first"), []storage.Event{star("secret/first", "Go")}); err != nil {
+			t.Fatalf("Save failed: %v", err)
+		}
+		rename := star("secret/second", "Rust")
+		rename.Type, rename.PreviousFullName = storage.EventRename, "secret/before-rename"
+		if err := store.Save("octocat", state(2, "secret/second"), []storage.Event{star("secret/second", "Rust"), rename}); err != nil {
+			t.Fatalf("Save failed: %v", err)
+		}
+
+		digests := notify.NewDigestStore(filepath.Join(dir, notify.DigestFileName), 24*time.Hour)
+		digests.SetEncryption(with(cipherA), true)
+		changes := &monitor.RepositoryChanges{NewStars: state(2, "secret/second").Repositories, TotalChanges: 1}
+		if err := digests.Add([]*notify.Payload{{Username: "octocat", Changes: changes}}, now); err != nil {
+			t.Fatalf("Add to digest failed: %v", err)
+		}
+
+		if leaked := filesContaining(t, dir, "secret/"); len(leaked) > 0 {
+			t.Errorf("repository names stored in plain text in %v", leaked)
+		}
+
+		digest, _, err := digests.Pending(now)
+		if err != nil || len(digest.Users["octocat"].NewStars) != 1 || digest.Users["octocat"].NewStars[0].FullName != "secret/second" {
+			t.Errorf("Pending digest = %+v, %v; want the star of secret/second", digest, err)
+		}
+
+		loaded, err := store.Load("octocat")
+		if err != nil {
+			t.Fatalf("Load failed: %v", err)
+		}
+		if loaded.CheckCount != 2 || len(loaded.Repositories) != 1 || loaded.Repositories[0].FullName != "secret/second" {
+			t.Errorf("loaded %+v, want the second state", loaded)
+		}
+
+		snapshots, err := store.Snapshots("octocat")
+		if err != nil || len(snapshots) != 1 || snapshots[0].Repositories != 1 {
+			t.Fatalf("Snapshots = %+v, %v; want one of one repository", snapshots, err)
+		}
+		previous, err := store.LoadSnapshot("octocat", snapshots[0].ID)
+		if err != nil || len(previous.Repositories) != 1 || previous.Repositories[0].FullName != "secret/first" {
+			t.Errorf("LoadSnapshot = %+v, %v; want the first state", previous, err)
+		}
+
+		rust, err := store.Events("octocat", storage.EventFilter{Language: "rust", Types: []string{storage.EventStar}})
+		if err != nil || len(rust) != 1 || rust[0].Repository.FullName != "secret/second" {
+			t.Errorf("Events of language rust = %+v, %v; want the star of secret/second", rust, err)
+		}
+		renames, err := store.Events("octocat", storage.EventFilter{Types: []string{storage.EventRename}})
+		if err != nil || len(renames) != 1 || renames[0].PreviousFullName != "secret/before-rename" {
+			t.Errorf("rename Events = %+v, %v; want the rename from secret/before-rename", renames, err)
+		}
+	})
+
+	t.Run("WrongKey", func(t *testing.T) {
+		dir := t.TempDir()
+		store := storage.NewEncryptedStore(open(dir), with(cipherA), true)
+		if err := store.Save("octocat", state(1, "secret/first"), []storage.Event{star("secret/first", "Go")}); err != nil {
+			t.Fatalf("Save failed: %v", err)
+		}
+		store.Close()
+
+		for name, source := range map[string]storage.CipherSource{"OtherKey": with(cipherB), "NoKey": with(nil)} {
+			t.Run(name, func(t *testing.T) {
+				store := storage.NewEncryptedStore(open(dir), source, false)
+				defer store.Close()
+
+				if _, err := store.Load("octocat"); err == nil {
+					t.Error("Load with the wrong key succeeded")
+				} else if wrongKey, ok := err.(*storage.WrongKeyError); !ok {
+					t.Errorf("Load with the wrong key = %T: %v, want *WrongKeyError", err, err)
+				} else if wrongKey.KeyID != cipherA.KeyID() {
+					t.Errorf("WrongKeyError names key %s, want %s", wrongKey.KeyID, cipherA.KeyID())
+				}
+				if _, err := store.Events("octocat", storage.EventFilter{}); err == nil {
+					t.Error("Events with the wrong key succeeded")
+				} else if _, ok := err.(*storage.WrongKeyError); !ok {
+					t.Errorf("Events with the wrong key = %T: %v, want *WrongKeyError", err, err)
+				}
+			})
+		}
+	})
+
+	t.Run("TamperedState", func(t *testing.T) {
+		dir := t.TempDir()
+		inner := open(dir)
+		store := storage.NewEncryptedStore(inner, with(cipherA), true)
+		defer store.Close()
+		if err := store.Save("octocat", state(1, "secret/first"), nil); err != nil {
+			t.Fatalf("Save failed: %v", err)
+		}
+
+		sealed, err := inner.Load("octocat")
+		if err != nil {
+			t.Fatalf("Load of the sealed state failed: %v", err)
+		}
+		sealed.Encrypted[len(sealed.Encrypted)-1] ^= 0xff
+		if err := inner.Save("octocat", sealed, nil); err != nil {
+			t.Fatalf("Save of the tampered state failed: %v", err)
+		}
+
+		if _, err := store.Load("octocat"); err == nil {
+			t.Error("Load of tampered state succeeded")
+		} else if _, ok := err.(*storage.StateCorruptionError); !ok {
+			t.Errorf("Load of tampered state = %T: %v, want *StateCorruptionError", err, err)
+		}
+
+		// State sealed for one user cannot be passed off as another's
+		sealed.Encrypted[len(sealed.Encrypted)-1] ^= 0xff
+		sealed.Username = "mallory"
+		if err := inner.Save("mallory", sealed, nil); err != nil {
+			t.Fatalf("Save of the copied state failed: %v", err)
+		}
+		if _, err := store.Load("mallory"); err == nil {
+			t.Error("Load of state copied from another user succeeded")
+		} else if _, ok := err.(*storage.StateCorruptionError); !ok {
+			t.Errorf("Load of copied state = %T: %v, want *StateCorruptionError", err, err)
+		}
+	})
+
+	t.Run("PlainStateAndRekey", func(t *testing.T) {
+		dir := t.TempDir()
+		plain := open(dir)
+		if err := plain.Save("octocat", state(1, "secret/first"), []storage.Event{star("secret/first", "Go")}); err != nil {
+			t.Fatalf("Save failed: %v", err)
+		}
+		if err := plain.Save("octocat", state(2, "secret/second"), nil); err != nil {
+			t.Fatalf("Save failed: %v", err)
+		}
+		plain.Close()
+
+		// Plain state is read as is, even with encryption enabled
+		store := storage.NewEncryptedStore(open(dir), with(cipherA), true)
+		if loaded, err := store.Load("octocat"); err != nil || loaded.Repositories[0].FullName != "secret/second" {
+			t.Fatalf("Load of plain state = %+v, %v; want the second state", loaded, err)
+		}
+
+		// The first rekey seals everything, a second one moves it to another key
+		if err := store.Rekey("octocat", cipherA); err != nil {
+			t.Fatalf("Rekey failed: %v", err)
+		}
+		if leaked := filesContaining(t, dir, "secret/"); len(leaked) > 0 {
+			t.Errorf("repository names left in plain text after rekey in %v", leaked)
+		}
+		if err := store.Rekey("octocat", mustStateCipher(t, keyB, keyA)); err != nil {
+			t.Fatalf("Rekey to a new key failed: %v", err)
+		}
+		store.Close()
+
+		if _, err := storage.NewEncryptedStore(open(dir), with(cipherA), true).Load("octocat"); err == nil {
+			t.Error("Load with the old key succeeded after rekey")
+		} else if _, ok := err.(*storage.WrongKeyError); !ok {
+			t.Errorf("Load with the old key = %T: %v, want *WrongKeyError", err, err)
+		}
+
+		rekeyed := storage.NewEncryptedStore(open(dir), with(cipherB), true)
+		defer rekeyed.Close()
+		if loaded, err := rekeyed.Load("octocat"); err != nil || loaded.Repositories[0].FullName != "secret/second" {
+			t.Errorf("Load after rekey = %+v, %v; want the second state", loaded, err)
+		}
+		snapshots, err := rekeyed.Snapshots("octocat")
+		if err != nil || len(snapshots) != 1 {
+			t.Fatalf("Snapshots after rekey = %+v, %v; want one", snapshots, err)
+		}
+		if previous, err := rekeyed.LoadSnapshot("octocat", snapshots[0].ID); err != nil || previous.Repositories[0].FullName != "secret/first" {
+			t.Errorf("LoadSnapshot after rekey = %+v, %v; want the first state", previous, err)
+		}
+		if events, err := rekeyed.Events("octocat", storage.EventFilter{Language: "go"}); err != nil || len(events) != 1 || events[0].Repository.FullName != "secret/first" {
+			t.Errorf("Events after rekey = %+v, %v; want the star of secret/first", events, err)
+		}
+	})
+}
+
+// TestStateKeys validates the encoding and IDs of state keys
+func TestStateKeys(t *testing.T) {
+	key := mustStateKey(t)
+	parsed, err := storage.ParseStateKey(storage.FormatStateKey(key))
+	if err != nil || !bytes.Equal(parsed, key) {
+		t.Errorf("ParseStateKey(FormatStateKey(key)) = %x, %v; want the key", parsed, err)
+	}
+	if _, err := storage.ParseStateKey(storage.FormatStateKey(key[:16])); err == nil {
+		t.Error("ParseStateKey accepted a 16 byte key")
+	}
+	if _, err := storage.ParseStateKey("not base64!"); err == nil {
+		t.Error("ParseStateKey accepted invalid base64")
+	}
+
+	if storage.StateKeyID(key) == storage.StateKeyID(mustStateKey(t)) {
+		t.Error("two keys have the same ID")
+	}
+	if id := mustStateCipher(t, key).KeyID(); id != storage.StateKeyID(key) {
+		t.Errorf("cipher KeyID = %s, want the ID of its first key %s", id, storage.StateKeyID(key))
+	}
+}
+
+func mustStateKey(t *testing.T) []byte {
+	t.Helper()
+	key, err := storage.GenerateStateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func mustStateCipher(t *testing.T, keys ...[]byte) *storage.StateCipher {
+	t.Helper()
+	c, err := storage.NewStateCipher(keys...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+// filesContaining returns the files below dir that contain text
+func filesContaining(t *testing.T, dir, text string) []string {
+	t.Helper()
+	var found []string
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if bytes.Contains(data, []byte(text)) {
+			found = append(found, path)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return found
+}
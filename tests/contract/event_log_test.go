@@ -0,0 +1,138 @@
+package contract
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/akme/gh-stars-watcher/internal/storage"
+)
+
+// TestEventLogContract validates the append-only event log kept next to each state file
+func TestEventLogContract(t *testing.T) {
+	tmpDir := t.TempDir()
+	statePath := filepath.Join(tmpDir, "testuser.json")
+
+	if got := storage.EventLogPath(statePath); got != filepath.Join(tmpDir, "testuser.events.jsonl") {
+		t.Errorf("EventLogPath = %s, want testuser.events.jsonl next to the state file", got)
+	}
+
+	event := func(runID, eventType, repo string) storage.Event {
+		return storage.Event{
+			RunID:      runID,
+			Timestamp:  time.Now(),
+			Username:   "testuser",
+			Type:       eventType,
+			Repository: storage.Repository{FullName: repo},
+		}
+	}
+
+	t.Run("AppendAndRead", func(t *testing.T) {
+		log := storage.NewEventLog(filepath.Join(tmpDir, "append.events.jsonl"))
+		if events, err := log.Read(); err != nil || len(events) != 0 {
+			t.Fatalf("Read of a missing log = %v, %v; want no events", events, err)
+		}
+
+		if _, err := log.Append([]storage.Event{event("run-1", storage.EventStar, "a/one")}); err != nil {
+			t.Fatalf("Append failed: %v", err)
+		}
+		if _, err := log.Append([]storage.Event{event("run-2", storage.EventUnstar, "a/one"), event("run-2", storage.EventStar, "b/two")}); err != nil {
+			t.Fatalf("Append failed: %v", err)
+		}
+
+		events, err := log.Read()
+		if err != nil {
+			t.Fatalf("Read failed: %v", err)
+		}
+		if len(events) != 3 || events[0].RunID != "run-1" || events[1].Type != storage.EventUnstar || events[2].Repository.FullName != "b/two" {
+			t.Errorf("events = %+v, want the three events in order", events)
+		}
+	})
+
+	t.Run("RollbackRemovesRun", func(t *testing.T) {
+		log := storage.NewEventLog(filepath.Join(tmpDir, "rollback.events.jsonl"))
+		log.Append([]storage.Event{event("run-1", storage.EventStar, "a/one")})
+
+		rollback, err := log.Append([]storage.Event{event("run-2", storage.EventStar, "b/two")})
+		if err != nil {
+			t.Fatalf("Append failed: %v", err)
+		}
+		if err := rollback(); err != nil {
+			t.Fatalf("rollback failed: %v", err)
+		}
+
+		events, _ := log.Read()
+		if len(events) != 1 || events[0].RunID != "run-1" {
+			t.Errorf("events after rollback = %+v, want only run-1", events)
+		}
+	})
+
+	t.Run("TornLineIsRepaired", func(t *testing.T) {
+		path := filepath.Join(tmpDir, "torn.events.jsonl")
+		log := storage.NewEventLog(path)
+		log.Append([]storage.Event{event("run-1", storage.EventStar, "a/one")})
+
+		// Simulate a crash in the middle of a write
+		file, _ := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+		file.WriteString(`{"run_id":"run-2","type":"st`)
+		file.Close()
+
+		if events, err := log.Read(); err != nil || len(events) != 1 {
+			t.Fatalf("Read with torn line = %+v, %v; want the complete event only", events, err)
+		}
+
+		log.Append([]storage.Event{event("run-3", storage.EventStar, "c/three")})
+		events, err := log.Read()
+		if err != nil {
+			t.Fatalf("Read after repair failed: %v", err)
+		}
+		if len(events) != 2 || events[1].RunID != "run-3" {
+			t.Errorf("events = %+v, want run-1 and run-3", events)
+		}
+	})
+
+	t.Run("CorruptLineIsReported", func(t *testing.T) {
+		path := filepath.Join(tmpDir, "corrupt.events.jsonl")
+		os.WriteFile(path, []byte("not json\n{}\n"), 0644)
+
+		_, err := storage.NewEventLog(path).Read()
+		if _, ok := err.(*storage.StateCorruptionError); !ok {
+			t.Errorf("Read error = %v, want *StateCorruptionError", err)
+		}
+	})
+}
+
+// TestEventFilter validates selecting events by time, type and language
+func TestEventFilter(t *testing.T) {
+	base := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	event := storage.Event{
+		Timestamp:  base.Add(time.Hour),
+		Type:       storage.EventStar,
+		Repository: storage.Repository{FullName: "golang/go", Language: "Go"},
+	}
+
+	tests := []struct {
+		name   string
+		filter storage.EventFilter
+		want   bool
+	}{
+		{"Empty", storage.EventFilter{}, true},
+		{"SinceInclusive", storage.EventFilter{Since: event.Timestamp}, true},
+		{"SinceAfter", storage.EventFilter{Since: event.Timestamp.Add(time.Second)}, false},
+		{"UntilExclusive", storage.EventFilter{Until: event.Timestamp}, false},
+		{"UntilAfter", storage.EventFilter{Until: base.AddDate(0, 0, 1)}, true},
+		{"Type", storage.EventFilter{Types: []string{storage.EventUnstar, storage.EventStar}}, true},
+		{"OtherType", storage.EventFilter{Types: []string{storage.EventUnstar}}, false},
+		{"LanguageCaseInsensitive", storage.EventFilter{Language: "go"}, true},
+		{"OtherLanguage", storage.EventFilter{Language: "Rust"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.Match(event); got != tt.want {
+				t.Errorf("Match = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,161 @@
+package contract
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/akme/gh-stars-watcher/internal/storage"
+)
+
+// Environment of the writer processes started by TestConcurrentWriters
+const (
+	lockWriterStorageEnv = "STAR_WATCHER_LOCK_WRITER_STORAGE"
+	lockWriterDirEnv     = "STAR_WATCHER_LOCK_WRITER_DIR"
+	lockWriterWritesEnv  = "STAR_WATCHER_LOCK_WRITER_WRITES"
+)
+
+// TestStateLock validates the per-user lock of a store
+func TestStateLock(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewJSONStore(t.TempDir())
+
+	held, err := store.Lock(ctx, "octocat", 0)
+	if err != nil {
+		t.Fatalf("Lock failed: %v", err)
+	}
+
+	start := time.Now()
+	_, err = store.Lock(ctx, "octocat", 200*time.Millisecond)
+	if _, ok := err.(*storage.LockTimeoutError); !ok {
+		t.Fatalf("Lock of a held lock = %v, want *LockTimeoutError", err)
+	}
+	if waited := time.Since(start); waited < 200*time.Millisecond {
+		t.Errorf("Lock gave up after %s, want it to wait for the timeout", waited)
+	}
+
+	other, err := store.Lock(ctx, "github", 0)
+	if err != nil {
+		t.Fatalf("Lock of another user failed: %v", err)
+	}
+	other.Unlock()
+
+	// The lock is handed over as soon as it is released
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		held.Unlock()
+	}()
+	next, err := store.Lock(ctx, "octocat", 5*time.Second)
+	if err != nil {
+		t.Fatalf("Lock after release failed: %v", err)
+	}
+	next.Unlock()
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	relocked, _ := store.Lock(ctx, "octocat", 0)
+	defer relocked.Unlock()
+	if _, err := store.Lock(cancelled, "octocat", time.Minute); err != context.Canceled {
+		t.Errorf("Lock with a cancelled context = %v, want context.Canceled", err)
+	}
+}
+
+// TestConcurrentWriters runs several processes that update the same user's state at once.
+// Each holds the lock from load to save, so no update may be lost.
+func TestConcurrentWriters(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping multi-process test in short mode")
+	}
+
+	const writers, writes = 6, 5
+	for _, backend := range []string{"json", "sqlite://"} {
+		t.Run(backend, func(t *testing.T) {
+			dir := t.TempDir()
+
+			var wg sync.WaitGroup
+			outputs := make([][]byte, writers)
+			errs := make([]error, writers)
+			for i := range writers {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					cmd := exec.Command(os.Args[0], "-test.run=^TestLockWriterProcess$")
+					cmd.Env = append(os.Environ(),
+						lockWriterStorageEnv+"="+backend,
+						lockWriterDirEnv+"="+dir,
+						lockWriterWritesEnv+"="+strconv.Itoa(writes))
+					outputs[i], errs[i] = cmd.CombinedOutput()
+				}()
+			}
+			wg.Wait()
+
+			for i, err := range errs {
+				if err != nil {
+					t.Fatalf("writer %d failed: %v\n%s", i, err, outputs[i])
+				}
+			}
+
+			store, err := storage.Open(backend, dir, storage.DefaultOptions())
+			if err != nil {
+				t.Fatalf("Open failed: %v", err)
+			}
+			defer store.Close()
+			state, err := store.Load("octocat")
+			if err != nil {
+				t.Fatalf("Load failed: %v", err)
+			}
+			if state.CheckCount != writers*writes {
+				t.Errorf("check count = %d, want %d: updates were lost", state.CheckCount, writers*writes)
+			}
+		})
+	}
+}
+
+// TestLockWriterProcess is the writer process of TestConcurrentWriters
+func TestLockWriterProcess(t *testing.T) {
+	backend := os.Getenv(lockWriterStorageEnv)
+	if backend == "" {
+		t.Skip("only runs as a writer process of TestConcurrentWriters")
+	}
+	writes, _ := strconv.Atoi(os.Getenv(lockWriterWritesEnv))
+
+	store, err := storage.Open(backend, os.Getenv(lockWriterDirEnv), storage.DefaultOptions())
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer store.Close()
+
+	for range writes {
+		if err := incrementCheckCount(store, "octocat"); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// incrementCheckCount is a load-compare-save cycle like a monitor run
+func incrementCheckCount(store storage.Store, username string) error {
+	lock, err := store.Lock(context.Background(), username, 30*time.Second)
+	if err != nil {
+		return err
+	}
+	defer lock.Unlock()
+
+	state, err := store.Load(username)
+	if _, ok := err.(*storage.StateNotFoundError); ok {
+		state = storage.NewUserState(username)
+	} else if err != nil {
+		return fmt.Errorf("load failed: %v", err)
+	}
+
+	state.CheckCount++
+	time.Sleep(5 * time.Millisecond) // Widen the window between load and save
+	if err := store.Save(username, state, nil); err != nil {
+		return fmt.Errorf("save failed: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,149 @@
+package contract
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/akme/gh-stars-watcher/internal/storage"
+)
+
+// TestStateMigrations loads a fixture of every state version ever written and checks
+// it is upgraded to the current version
+func TestStateMigrations(t *testing.T) {
+	tests := []struct {
+		fixture      string
+		checkCount   int
+		incremental  bool
+		syncInterval int
+		fullSyncDue  bool // State from before repository IDs needs a full sync to record them
+	}{
+		{"unversioned.json", 3, true, 24, true},
+		{"v1.0.0.json", 3, true, 24, true},
+		{"v1.0.0-incremental.json", 7, true, 12, true},
+		{"v1.1.0.json", 12, true, 24, true},
+		{"v1.2.0.json", 20, true, 24, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			store := storage.NewJSONStore(t.TempDir())
+			copyFixture(t, tt.fixture, store.Path("octocat"))
+
+			state, err := store.Load("octocat")
+			if err != nil {
+				t.Fatalf("Load failed: %v", err)
+			}
+			if state.StateVersion != storage.CurrentStateVersion {
+				t.Errorf("state version = %s, want %s", state.StateVersion, storage.CurrentStateVersion)
+			}
+			if state.CheckCount != tt.checkCount || len(state.Repositories) != 1 || state.Repositories[0].FullName != "golang/go" {
+				t.Errorf("loaded %+v, want the fixture's check count %d and golang/go", state, tt.checkCount)
+			}
+			if state.IncrementalEnabled != tt.incremental || state.FullSyncInterval != tt.syncInterval {
+				t.Errorf("incremental = %v every %dh, want %v every %dh",
+					state.IncrementalEnabled, state.FullSyncInterval, tt.incremental, tt.syncInterval)
+			}
+			if due := state.LastFullSyncAt.IsZero(); due != tt.fullSyncDue {
+				t.Errorf("full sync due = %v (last at %v), want %v", due, state.LastFullSyncAt, tt.fullSyncDue)
+			}
+
+			// The upgraded version is written with the next save
+			if err := store.Save("octocat", state, nil); err != nil {
+				t.Fatalf("Save failed: %v", err)
+			}
+			if version := storedStateVersion(t, store.Path("octocat")); version != storage.CurrentStateVersion {
+				t.Errorf("saved state version = %s, want %s", version, storage.CurrentStateVersion)
+			}
+		})
+	}
+
+	t.Run("NewerVersionIsRefused", func(t *testing.T) {
+		store := storage.NewJSONStore(t.TempDir())
+		path := store.Path("octocat")
+		copyFixture(t, "v99.0.0.json", path)
+		before, _ := os.ReadFile(path)
+
+		_, err := store.Load("octocat")
+		if versionErr, ok := err.(*storage.StateVersionError); !ok {
+			t.Fatalf("Load of a newer state = %T: %v, want *StateVersionError", err, err)
+		} else if versionErr.Version != "99.0.0" {
+			t.Errorf("StateVersionError version = %s, want 99.0.0", versionErr.Version)
+		}
+		if after, _ := os.ReadFile(path); string(after) != string(before) {
+			t.Error("newer state file was modified")
+		}
+	})
+
+	t.Run("SQLite", func(t *testing.T) {
+		store, err := storage.NewSQLiteStorage(filepath.Join(t.TempDir(), "state.db"))
+		if err != nil {
+			t.Fatalf("Failed to open SQLite storage: %v", err)
+		}
+		defer store.Close()
+
+		state := func(version string) *storage.UserState {
+			return &storage.UserState{Username: "octocat", LastCheck: time.Now(), Repositories: []storage.Repository{}, StateVersion: version}
+		}
+		if err := store.Save("octocat", state("1.0.0"), nil); err != nil {
+			t.Fatalf("Save failed: %v", err)
+		}
+		if err := store.Save("octocat", state("1.0.0"), nil); err != nil {
+			t.Fatalf("Save failed: %v", err)
+		}
+
+		loaded, err := store.Load("octocat")
+		if err != nil {
+			t.Fatalf("Load failed: %v", err)
+		}
+		if loaded.StateVersion != storage.CurrentStateVersion || !loaded.IncrementalEnabled || loaded.FullSyncInterval != 24 {
+			t.Errorf("loaded %+v, want it upgraded to %s with incremental fetching", loaded, storage.CurrentStateVersion)
+		}
+		snapshots, err := store.Snapshots("octocat")
+		if err != nil || len(snapshots) != 1 {
+			t.Fatalf("Snapshots = %+v, %v; want one", snapshots, err)
+		}
+		if snapshot, err := store.LoadSnapshot("octocat", snapshots[0].ID); err != nil || snapshot.StateVersion != storage.CurrentStateVersion {
+			t.Errorf("LoadSnapshot = %+v, %v; want it upgraded to %s", snapshot, err, storage.CurrentStateVersion)
+		}
+
+		if err := store.Save("octocat", state("99.0.0"), nil); err != nil {
+			t.Fatalf("Save failed: %v", err)
+		}
+		if _, err := store.Load("octocat"); err == nil {
+			t.Error("Load of a newer state succeeded")
+		} else if _, ok := err.(*storage.StateVersionError); !ok {
+			t.Errorf("Load of a newer state = %T: %v, want *StateVersionError", err, err)
+		}
+	})
+}
+
+// copyFixture copies a state fixture from testdata/state to path
+func copyFixture(t *testing.T, fixture, path string) {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", "state", fixture))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// storedStateVersion returns the state_version written in a state file
+func storedStateVersion(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc struct {
+		StateVersion string `json:"state_version"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatal(err)
+	}
+	return doc.StateVersion
+}
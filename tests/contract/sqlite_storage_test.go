@@ -0,0 +1,199 @@
+package contract
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/akme/gh-stars-watcher/internal/storage"
+)
+
+// TestSQLiteStorage validates the parts of SQLiteStorage beyond the Store contract
+func TestSQLiteStorage(t *testing.T) {
+	tmpDir := t.TempDir()
+	store, err := storage.NewSQLiteStorage(filepath.Join(tmpDir, "state.db"))
+	if err != nil {
+		t.Fatalf("Failed to open SQLite storage: %v", err)
+	}
+	defer store.Close()
+
+	now := time.Date(2026, 3, 1, 12, 0, 0, 123456789, time.UTC)
+	repo := func(name, language string, starredAt time.Time) storage.Repository {
+		return storage.Repository{
+			FullName:  name,
+			StarCount: 10,
+			UpdatedAt: now,
+			URL:       "https://github.com/" + name,
+			StarredAt: starredAt,
+			Language:  language,
+		}
+	}
+	state := func(checkCount int, repos ...storage.Repository) *storage.UserState {
+		return &storage.UserState{
+			Username:     "testuser",
+			LastCheck:    now,
+			Repositories: repos,
+			TotalCount:   len(repos),
+			StateVersion: "1.0.0",
+			CheckCount:   checkCount,
+		}
+	}
+
+	t.Run("RepositoriesRoundTrip", func(t *testing.T) {
+		first := state(1, repo("a/one", "Go", now.Add(-time.Hour)), repo("b/two", "Rust", now))
+		if err := store.Save("testuser", first, nil); err != nil {
+			t.Fatalf("Save failed: %v", err)
+		}
+
+		// Unstar a/one, restar b/two and update its metadata
+		updated := repo("b/two", "Rust", now.Add(time.Minute))
+		updated.StarCount = 11
+		if err := store.Save("testuser", state(2, updated, repo("c/three", "Go", now)), nil); err != nil {
+			t.Fatalf("Save failed: %v", err)
+		}
+
+		loaded, err := store.Load("testuser")
+		if err != nil {
+			t.Fatalf("Load failed: %v", err)
+		}
+		if len(loaded.Repositories) != 2 {
+			t.Fatalf("loaded %d repositories, want 2", len(loaded.Repositories))
+		}
+		got := loaded.Repositories[0]
+		if got.FullName != "b/two" || got.StarCount != 11 || !got.StarredAt.Equal(now.Add(time.Minute)) || !got.UpdatedAt.Equal(now) {
+			t.Errorf("first repository = %+v, want the updated b/two", got)
+		}
+		if !loaded.LastCheck.Equal(now) {
+			t.Errorf("LastCheck = %v, want %v", loaded.LastCheck, now)
+		}
+
+		snapshots, err := store.Snapshots("testuser")
+		if err != nil || len(snapshots) != 1 {
+			t.Fatalf("Snapshots = %+v, %v; want one", snapshots, err)
+		}
+		previous, err := store.LoadSnapshot("testuser", snapshots[0].ID)
+		if err != nil {
+			t.Fatalf("LoadSnapshot failed: %v", err)
+		}
+		if previous.CheckCount != 1 || len(previous.Repositories) != 2 || previous.Repositories[1].FullName != "a/one" {
+			t.Errorf("snapshot = %+v, want the first state", previous)
+		}
+	})
+
+	t.Run("EventsAreFiltered", func(t *testing.T) {
+		events := []storage.Event{
+			{RunID: "run-1", Timestamp: now, Username: "testuser", Type: storage.EventStar, Repository: repo("a/one", "Go", now)},
+			{RunID: "run-1", Timestamp: now, Username: "testuser", Type: storage.EventUpdate, Repository: repo("b/two", "Rust", now), Changes: []string{"star_count", "language"}},
+			{RunID: "run-2", Timestamp: now.Add(time.Hour), Username: "testuser", Type: storage.EventUnstar, Repository: repo("a/one", "Go", now)},
+		}
+		if err := store.Save("eventuser", state(1), events[:2]); err != nil {
+			t.Fatalf("Save failed: %v", err)
+		}
+		if err := store.Save("eventuser", state(2), events[2:]); err != nil {
+			t.Fatalf("Save failed: %v", err)
+		}
+
+		all, err := store.Events("eventuser", storage.EventFilter{})
+		if err != nil {
+			t.Fatalf("Events failed: %v", err)
+		}
+		if len(all) != 3 || all[1].Type != storage.EventUpdate || len(all[1].Changes) != 2 || !all[2].Timestamp.Equal(now.Add(time.Hour)) {
+			t.Errorf("events = %+v, want the three events in order", all)
+		}
+
+		filtered, err := store.Events("eventuser", storage.EventFilter{
+			Since:    now,
+			Until:    now.Add(time.Hour),
+			Types:    []string{storage.EventStar, storage.EventUnstar},
+			Language: "go",
+		})
+		if err != nil {
+			t.Fatalf("Events failed: %v", err)
+		}
+		if len(filtered) != 1 || filtered[0].RunID != "run-1" || filtered[0].Repository.FullName != "a/one" {
+			t.Errorf("filtered events = %+v, want the star of a/one", filtered)
+		}
+	})
+}
+
+// TestOpenStorage validates the storage URLs accepted by storage.Open
+func TestOpenStorage(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	for _, url := range []string{"", "json"} {
+		if store, err := storage.Open(url, tmpDir, storage.DefaultOptions()); err != nil {
+			t.Errorf("Open(%q) failed: %v", url, err)
+		} else if _, ok := store.(*storage.JSONStore); !ok {
+			t.Errorf("Open(%q) = %T, want *storage.JSONStore", url, store)
+		}
+	}
+
+	store, err := storage.Open("sqlite://", tmpDir, storage.DefaultOptions())
+	if err != nil {
+		t.Fatalf("Open(sqlite://) failed: %v", err)
+	}
+	sqliteStore, ok := store.(*storage.SQLiteStorage)
+	if !ok {
+		t.Fatalf("Open(sqlite://) = %T, want *storage.SQLiteStorage", store)
+	}
+	defer sqliteStore.Close()
+	if want := filepath.Join(tmpDir, "star-watcher.db"); sqliteStore.Path() != want {
+		t.Errorf("database path = %s, want %s", sqliteStore.Path(), want)
+	}
+
+	if _, err := storage.Open("postgres://localhost/stars", tmpDir, storage.DefaultOptions()); err == nil {
+		t.Error("Open of an unsupported URL succeeded")
+	}
+}
+
+// TestSQLiteStorage_MovesSharedRepositories opens a database from before repository
+// metadata was kept per user
+func TestSQLiteStorage_MovesSharedRepositories(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.db")
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	for _, statement := range []string{
+		`CREATE TABLE users (state_key TEXT PRIMARY KEY, username TEXT NOT NULL, last_check TEXT NOT NULL,
+			total_count INTEGER NOT NULL, state_version TEXT NOT NULL, check_count INTEGER NOT NULL,
+			last_run_id TEXT NOT NULL DEFAULT '', last_starred_at TEXT NOT NULL, last_full_sync_at TEXT NOT NULL,
+			incremental_enabled INTEGER NOT NULL, full_sync_interval INTEGER NOT NULL, last_incremental_at TEXT NOT NULL,
+			api_calls_saved INTEGER NOT NULL)`,
+		`CREATE TABLE repositories (full_name TEXT PRIMARY KEY, description TEXT NOT NULL, star_count INTEGER NOT NULL,
+			updated_at TEXT NOT NULL, url TEXT NOT NULL, language TEXT NOT NULL, private INTEGER NOT NULL)`,
+		`CREATE TABLE stars (state_key TEXT NOT NULL REFERENCES users(state_key) ON DELETE CASCADE,
+			full_name TEXT NOT NULL REFERENCES repositories(full_name), starred_at TEXT NOT NULL,
+			PRIMARY KEY (state_key, full_name))`,
+		`INSERT INTO users VALUES ('alice', 'alice', '2026-03-01T12:00:00.000000000Z', 1, '1.0.0', 3, '',
+			'2026-02-01T12:00:00.000000000Z', '2026-03-01T12:00:00.000000000Z', 1, 24, '0001-01-01T00:00:00.000000000Z', 0)`,
+		`INSERT INTO repositories VALUES ('golang/go', 'The Go language', 100, '2026-02-15T12:00:00.000000000Z',
+			'https://github.com/golang/go', 'Go', 0)`,
+		`INSERT INTO stars VALUES ('alice', 'golang/go', '2026-02-01T12:00:00.000000000Z')`,
+	} {
+		if _, err := db.Exec(statement); err != nil {
+			t.Fatalf("Failed to create old database: %v", err)
+		}
+	}
+	db.Close()
+
+	store, err := storage.NewSQLiteStorage(path)
+	if err != nil {
+		t.Fatalf("Failed to open old database: %v", err)
+	}
+	defer store.Close()
+
+	loaded, err := store.Load("alice")
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if len(loaded.Repositories) != 1 || loaded.Repositories[0].Description != "The Go language" || loaded.Repositories[0].StarCount != 100 {
+		t.Errorf("loaded %+v, want golang/go with its metadata", loaded.Repositories)
+	}
+
+	loaded.CheckCount++
+	if err := store.Save("alice", loaded, nil); err != nil {
+		t.Errorf("Save after the upgrade failed: %v", err)
+	}
+}
@@ -11,11 +11,7 @@ import (
 
 // TestStateStorageContract validates the StateStorage interface contract
 func TestStateStorageContract(t *testing.T) {
-	// This test will fail until StateStorage interface and implementation exist
-	var store storage.StateStorage
-	if store == nil {
-		t.Skip("StateStorage implementation not available yet - this is expected in TDD Red phase")
-	}
+	var store storage.StateStorage = storage.NewJSONStorage()
 
 	// Create temporary directory for test state files
 	tmpDir, err := os.MkdirTemp("", "star-watcher-test-*")
@@ -31,7 +27,7 @@ func TestStateStorageContract(t *testing.T) {
 			LastCheck:    time.Now(),
 			Repositories: []storage.Repository{},
 			TotalCount:   0,
-			StateVersion: "1.0.0",
+			StateVersion: storage.CurrentStateVersion,
 			CheckCount:   1,
 		}
 
@@ -72,6 +68,9 @@ func TestStateStorageContract(t *testing.T) {
 			t.Error("Expected error when loading nonexistent file")
 		}
 		// Should return specific error type for file not found
+		if _, ok := err.(*storage.StateFileNotFoundError); !ok {
+			t.Errorf("Expected *StateFileNotFoundError, got %T: %v", err, err)
+		}
 	})
 
 	t.Run("AtomicWrite", func(t *testing.T) {
@@ -104,9 +103,8 @@ func TestStateStorageContract(t *testing.T) {
 		}
 	})
 
-	t.Run("BackupPreviousState", func(t *testing.T) {
+	t.Run("SnapshotPreviousState", func(t *testing.T) {
 		statePath := filepath.Join(tmpDir, "backup-test.json")
-		backupPath := statePath + ".bak"
 
 		// Create initial state
 		initialState := &storage.UserState{
@@ -123,7 +121,7 @@ func TestStateStorageContract(t *testing.T) {
 			t.Errorf("Expected no error saving initial state, got: %v", err)
 		}
 
-		// Update state (should create backup)
+		// Update state (should snapshot the initial state)
 		updatedState := &storage.UserState{
 			Username:     "backuptest",
 			LastCheck:    time.Now(),
@@ -138,20 +136,30 @@ func TestStateStorageContract(t *testing.T) {
 			t.Errorf("Expected no error saving updated state, got: %v", err)
 		}
 
-		// Verify backup file exists
-		if _, err := os.Stat(backupPath); os.IsNotExist(err) {
-			t.Error("Expected backup file to be created")
+		// Verify the snapshot is listed and contains the original state
+		jsonStorage := storage.NewJSONStorage()
+		snapshots, err := jsonStorage.Snapshots(statePath)
+		if err != nil {
+			t.Fatalf("Expected no error listing snapshots, got: %v", err)
+		}
+		if len(snapshots) != 1 {
+			t.Fatalf("Expected 1 snapshot, got %d", len(snapshots))
+		}
+		if _, err := os.Stat(storage.SnapshotDir(statePath)); err != nil {
+			t.Errorf("Expected snapshot directory to be created: %v", err)
+		}
+
+		snapshotState, err := jsonStorage.LoadSnapshot(statePath, snapshots[0].ID)
+		if err != nil {
+			t.Fatalf("Expected no error loading snapshot, got: %v", err)
+		}
+		if snapshotState.CheckCount != 1 {
+			t.Errorf("Expected snapshot check count 1, got %d", snapshotState.CheckCount)
 		}
 
-		// Load backup and verify it contains original state
-		if _, err := os.Stat(backupPath); err == nil {
-			backupState, err := store.LoadUserState(backupPath)
-			if err != nil {
-				t.Errorf("Expected no error loading backup, got: %v", err)
-			}
-			if backupState.CheckCount != 1 {
-				t.Errorf("Expected backup check count 1, got %d", backupState.CheckCount)
-			}
+		// Snapshot IDs never name files outside the snapshot directory
+		if _, err := jsonStorage.LoadSnapshot(statePath, "../backup-test"); err == nil {
+			t.Error("Expected error loading a snapshot with an invalid ID")
 		}
 	})
 }
@@ -0,0 +1,351 @@
+package contract
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/akme/gh-stars-watcher/internal/storage"
+)
+
+// TestStoreContract validates the username-keyed Store contract for every backend
+func TestStoreContract(t *testing.T) {
+	t.Run("JSON", func(t *testing.T) {
+		testStoreContract(t, storage.NewJSONStore(t.TempDir()))
+	})
+
+	t.Run("SQLite", func(t *testing.T) {
+		store, err := storage.NewSQLiteStorage(filepath.Join(t.TempDir(), "state.db"))
+		if err != nil {
+			t.Fatalf("Failed to open SQLite storage: %v", err)
+		}
+		testStoreContract(t, store)
+	})
+}
+
+func testStoreContract(t *testing.T, store storage.Store) {
+	defer store.Close()
+
+	state := func(username string, checkCount int) *storage.UserState {
+		return &storage.UserState{
+			Username:     username,
+			LastCheck:    time.Now(),
+			Repositories: []storage.Repository{},
+			StateVersion: "1.0.0",
+			CheckCount:   checkCount,
+		}
+	}
+
+	t.Run("LoadMissingUser", func(t *testing.T) {
+		if _, err := store.Load("nobody"); err == nil {
+			t.Fatal("Expected error when loading a user without state")
+		} else if _, ok := err.(*storage.StateNotFoundError); !ok {
+			t.Errorf("Expected *StateNotFoundError, got %T: %v", err, err)
+		}
+
+		if exists, err := store.Exists("nobody"); err != nil || exists {
+			t.Errorf("Exists = %v, %v; want false", exists, err)
+		}
+		if err := store.Delete("nobody"); err == nil {
+			t.Error("Expected error when deleting a user without state")
+		} else if _, ok := err.(*storage.StateNotFoundError); !ok {
+			t.Errorf("Expected *StateNotFoundError, got %T: %v", err, err)
+		}
+	})
+
+	t.Run("SaveAndLoad", func(t *testing.T) {
+		if err := store.Save("alice", state("alice", 1), nil); err != nil {
+			t.Fatalf("Save failed: %v", err)
+		}
+		if err := store.Save("alice", state("alice", 2), nil); err != nil {
+			t.Fatalf("Save failed: %v", err)
+		}
+
+		loaded, err := store.Load("alice")
+		if err != nil {
+			t.Fatalf("Load failed: %v", err)
+		}
+		if loaded.Username != "alice" || loaded.CheckCount != 2 {
+			t.Errorf("loaded %s with check count %d, want alice with 2", loaded.Username, loaded.CheckCount)
+		}
+
+		snapshots, err := store.Snapshots("alice")
+		if err != nil {
+			t.Fatalf("Snapshots failed: %v", err)
+		}
+		if len(snapshots) != 1 || snapshots[0].CheckCount != 1 {
+			t.Fatalf("snapshots = %+v, want one of the first state", snapshots)
+		}
+		previous, err := store.LoadSnapshot("alice", snapshots[0].ID)
+		if err != nil {
+			t.Fatalf("LoadSnapshot failed: %v", err)
+		}
+		if previous.CheckCount != 1 {
+			t.Errorf("snapshot check count = %d, want 1", previous.CheckCount)
+		}
+		if _, err := store.LoadSnapshot("alice", "20000101T000000.000000000Z"); err == nil {
+			t.Error("Expected error when loading a missing snapshot")
+		} else if _, ok := err.(*storage.SnapshotNotFoundError); !ok {
+			t.Errorf("Expected *SnapshotNotFoundError, got %T: %v", err, err)
+		}
+
+		if exists, err := store.Exists("alice"); err != nil || !exists {
+			t.Errorf("Exists = %v, %v; want true", exists, err)
+		}
+	})
+
+	t.Run("SnapshotsRotate", func(t *testing.T) {
+		rotating, ok := store.(interface{ SetSnapshotPolicy(storage.SnapshotPolicy) })
+		if !ok {
+			t.Fatalf("%T has no snapshot policy", store)
+		}
+		rotating.SetSnapshotPolicy(storage.SnapshotPolicy{Count: 2})
+		defer rotating.SetSnapshotPolicy(storage.DefaultSnapshotPolicy)
+
+		for i := 1; i <= 4; i++ {
+			if err := store.Save("carol", state("carol", i), nil); err != nil {
+				t.Fatalf("Save failed: %v", err)
+			}
+		}
+
+		snapshots, err := store.Snapshots("carol")
+		if err != nil {
+			t.Fatalf("Snapshots failed: %v", err)
+		}
+		if len(snapshots) != 2 || snapshots[0].CheckCount != 3 || snapshots[1].CheckCount != 2 {
+			t.Fatalf("snapshots = %+v, want those of check counts 3 and 2", snapshots)
+		}
+
+		// Restoring a snapshot keeps the replaced state as a new snapshot
+		restored, err := store.LoadSnapshot("carol", snapshots[1].ID)
+		if err != nil {
+			t.Fatalf("LoadSnapshot failed: %v", err)
+		}
+		if err := store.Save("carol", restored, nil); err != nil {
+			t.Fatalf("Save failed: %v", err)
+		}
+		if loaded, err := store.Load("carol"); err != nil || loaded.CheckCount != 2 {
+			t.Errorf("Load after restore = %+v, %v; want check count 2", loaded, err)
+		}
+		if snapshots, err := store.Snapshots("carol"); err != nil || len(snapshots) != 2 || snapshots[0].CheckCount != 4 {
+			t.Errorf("snapshots after restore = %+v, %v; want the replaced check count 4 first", snapshots, err)
+		}
+
+		if err := store.Delete("carol"); err != nil {
+			t.Fatalf("Delete failed: %v", err)
+		}
+		if snapshots, err := store.Snapshots("carol"); err != nil || len(snapshots) != 0 {
+			t.Errorf("snapshots after Delete = %+v, %v; want none", snapshots, err)
+		}
+	})
+
+	t.Run("InvalidStateIsRejected", func(t *testing.T) {
+		if err := store.Save("invalid", &storage.UserState{}, nil); err == nil {
+			t.Fatal("Save of an invalid state succeeded")
+		}
+		if exists, _ := store.Exists("invalid"); exists {
+			t.Error("invalid state was stored")
+		}
+	})
+
+	t.Run("EventsAreKeptPerUser", func(t *testing.T) {
+		event := storage.Event{
+			RunID:      "run-1",
+			Timestamp:  time.Now(),
+			Username:   "bob",
+			Type:       storage.EventStar,
+			Repository: storage.Repository{FullName: "a/one"},
+		}
+		if err := store.Save("bob", state("bob", 1), []storage.Event{event}); err != nil {
+			t.Fatalf("Save failed: %v", err)
+		}
+
+		events, err := store.Events("bob", storage.EventFilter{})
+		if err != nil {
+			t.Fatalf("Events failed: %v", err)
+		}
+		if len(events) != 1 || events[0].RunID != "run-1" || events[0].Repository.FullName != "a/one" {
+			t.Errorf("events = %+v, want the saved star", events)
+		}
+
+		if events, err := store.Events("alice", storage.EventFilter{}); err != nil || len(events) != 0 {
+			t.Errorf("events of alice = %v, %v; want none", events, err)
+		}
+	})
+
+	t.Run("RepositoryIDsAndRenames", func(t *testing.T) {
+		repo := storage.Repository{ID: 42, NodeID: "R_kgDOAAAAKg", FullName: "new-owner/tool", URL: "https://github.com/new-owner/tool"}
+		saved := state("dave", 1)
+		saved.Repositories = []storage.Repository{repo}
+		saved.TotalCount = 1
+		rename := storage.Event{
+			RunID:            "run-1",
+			Timestamp:        time.Now(),
+			Username:         "dave",
+			Type:             storage.EventRename,
+			Repository:       repo,
+			PreviousFullName: "old-owner/tool",
+		}
+		if err := store.Save("dave", saved, []storage.Event{rename}); err != nil {
+			t.Fatalf("Save failed: %v", err)
+		}
+		defer store.Delete("dave")
+
+		loaded, err := store.Load("dave")
+		if err != nil {
+			t.Fatalf("Load failed: %v", err)
+		}
+		if len(loaded.Repositories) != 1 || loaded.Repositories[0].ID != 42 || loaded.Repositories[0].NodeID != "R_kgDOAAAAKg" {
+			t.Errorf("loaded repositories %+v, want the IDs of new-owner/tool", loaded.Repositories)
+		}
+
+		events, err := store.Events("dave", storage.EventFilter{Types: []string{storage.EventRename}})
+		if err != nil {
+			t.Fatalf("Events failed: %v", err)
+		}
+		if len(events) != 1 || events[0].PreviousFullName != "old-owner/tool" || events[0].Repository.ID != 42 {
+			t.Errorf("rename events = %+v, want the rename from old-owner/tool", events)
+		}
+	})
+
+	t.Run("RepositoryMetadataIsKeptPerUser", func(t *testing.T) {
+		repo := storage.Repository{ID: 7, FullName: "golang/go", Description: "The Go language", StarCount: 100,
+			UpdatedAt: time.Now().Add(-time.Hour), URL: "https://github.com/golang/go", Language: "Go"}
+		for _, username := range []string{"erin", "frank"} {
+			saved := state(username, 1)
+			saved.Repositories = []storage.Repository{repo}
+			saved.TotalCount = 1
+			if err := store.Save(username, saved, nil); err != nil {
+				t.Fatalf("Save of %s failed: %v", username, err)
+			}
+			defer store.Delete(username)
+		}
+
+		// erin's next run fetches new metadata
+		updated := repo
+		updated.Description, updated.StarCount, updated.UpdatedAt = "Go", 200, time.Now()
+		saved := state("erin", 2)
+		saved.Repositories = []storage.Repository{updated}
+		saved.TotalCount = 1
+		if err := store.Save("erin", saved, nil); err != nil {
+			t.Fatalf("Save of erin failed: %v", err)
+		}
+
+		// frank still sees what he saved, so his next run reports the update too
+		loaded, err := store.Load("frank")
+		if err != nil {
+			t.Fatalf("Load failed: %v", err)
+		}
+		if len(loaded.Repositories) != 1 || loaded.Repositories[0].Description != "The Go language" || loaded.Repositories[0].StarCount != 100 {
+			t.Errorf("frank loaded %+v, want his own metadata of golang/go", loaded.Repositories)
+		}
+		if loaded, err := store.Load("erin"); err != nil || len(loaded.Repositories) != 1 || loaded.Repositories[0].StarCount != 200 {
+			t.Errorf("erin loaded %+v, %v; want the new metadata of golang/go", loaded, err)
+		}
+	})
+
+	t.Run("ListAndDelete", func(t *testing.T) {
+		usernames, err := store.List()
+		if err != nil {
+			t.Fatalf("List failed: %v", err)
+		}
+		if len(usernames) != 2 || usernames[0] != "alice" || usernames[1] != "bob" {
+			t.Fatalf("List = %v, want [alice bob]", usernames)
+		}
+
+		if err := store.Delete("bob"); err != nil {
+			t.Fatalf("Delete failed: %v", err)
+		}
+		if _, err := store.Load("bob"); err == nil {
+			t.Error("state of bob still loads after Delete")
+		}
+		if events, err := store.Events("bob", storage.EventFilter{}); err != nil || len(events) != 0 {
+			t.Errorf("events of bob after Delete = %v, %v; want none", events, err)
+		}
+
+		if usernames, err := store.List(); err != nil || len(usernames) != 1 || usernames[0] != "alice" {
+			t.Errorf("List after Delete = %v, %v; want [alice]", usernames, err)
+		}
+	})
+}
+
+// TestJSONStoreLayout validates where JSONStore keeps the files of a user
+func TestJSONStoreLayout(t *testing.T) {
+	dir := t.TempDir()
+	newState := func(username string) *storage.UserState {
+		return &storage.UserState{Username: username, LastCheck: time.Now(), StateVersion: "1.0.0", Repositories: []storage.Repository{}}
+	}
+
+	store := storage.NewJSONStore(dir)
+	if got := store.Path("octocat"); got != filepath.Join(dir, "octocat.json") {
+		t.Errorf("Path = %s, want octocat.json in the state directory", got)
+	}
+	if err := store.Save("octocat", newState("octocat"), nil); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	// Other JSON files in the state directory are not users
+	if err := os.WriteFile(filepath.Join(dir, "notifications.json"), []byte(`{"webhook/octocat":{}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if usernames, err := store.List(); err != nil || len(usernames) != 1 || usernames[0] != "octocat" {
+		t.Errorf("List = %v, %v; want [octocat]", usernames, err)
+	}
+
+	fileStore := storage.NewJSONFileStore(filepath.Join(dir, "custom.json"))
+	if got := fileStore.Path("anyone"); got != filepath.Join(dir, "custom.json") {
+		t.Errorf("Path of a file store = %s, want custom.json", got)
+	}
+	if usernames, err := fileStore.List(); err != nil || len(usernames) != 0 {
+		t.Errorf("List of an empty file store = %v, %v; want none", usernames, err)
+	}
+	if err := fileStore.Save("alice", newState("alice"), nil); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if usernames, err := fileStore.List(); err != nil || len(usernames) != 1 || usernames[0] != "alice" {
+		t.Errorf("List of a file store = %v, %v; want [alice]", usernames, err)
+	}
+}
+
+// TestSnapshotPolicy validates which snapshots a policy expires
+func TestSnapshotPolicy(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	snapshots := []storage.Snapshot{
+		{ID: "newest", TakenAt: now.Add(-48 * time.Hour)},
+		{ID: "recent", TakenAt: now.Add(-72 * time.Hour)},
+		{ID: "old", TakenAt: now.Add(-30 * 24 * time.Hour)},
+	}
+	ids := func(snapshots []storage.Snapshot) []string {
+		var ids []string
+		for _, snapshot := range snapshots {
+			ids = append(ids, snapshot.ID)
+		}
+		return ids
+	}
+
+	tests := []struct {
+		name   string
+		policy storage.SnapshotPolicy
+		want   []string
+	}{
+		{"KeepAll", storage.SnapshotPolicy{Count: 5}, nil},
+		{"Count", storage.SnapshotPolicy{Count: 1}, []string{"recent", "old"}},
+		{"MaxAge", storage.SnapshotPolicy{Count: 5, MaxAge: 7 * 24 * time.Hour}, []string{"old"}},
+		{"MaxAgeKeepsNewest", storage.SnapshotPolicy{Count: 5, MaxAge: time.Hour}, []string{"recent", "old"}},
+		{"Disabled", storage.SnapshotPolicy{}, []string{"newest", "recent", "old"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ids(tt.policy.Expired(snapshots, now))
+			if len(got) != len(tt.want) {
+				t.Fatalf("Expired = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("Expired = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package integration
+
+import (
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+// TestHistoryCommand tests filtering and ordering of recorded events
+func TestHistoryCommand(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	binaryPath := "../../bin/star-watcher"
+	if _, err := os.Stat(binaryPath); os.IsNotExist(err) {
+		t.Skip("CLI binary not available yet - this is expected in TDD Red phase")
+	}
+
+	tmpDir := t.TempDir()
+	stateFile := filepath.Join(tmpDir, "alice.json")
+	events := `{"run_id":"r3","timestamp":"2026-03-20T10:00:00Z","username":"alice","type":"unstar","repository":{"full_name":"old/tool","language":"Go"}}
+{"run_id":"r1","timestamp":"2026-01-02T10:00:00Z","username":"alice","type":"baseline","repository":{"full_name":"a/base","language":"Go"}}
+{"run_id":"r2","timestamp":"2026-03-05T10:00:00Z","username":"alice","type":"star","repository":{"full_name":"golang/go","language":"Go"}}
+{"run_id":"r2","timestamp":"2026-03-05T10:00:00Z","username":"alice","type":"star","repository":{"full_name":"rust-lang/rust","language":"Rust"}}
+`
+	if err := os.WriteFile(filepath.Join(tmpDir, "alice.events.jsonl"), []byte(events), 0644); err != nil {
+		t.Fatalf("Failed to write event log: %v", err)
+	}
+
+	history := func(args ...string) []string {
+		t.Helper()
+		cmd := exec.Command(binaryPath, append([]string{"history", "alice", "--state-file", stateFile, "--output", "json"}, args...)...)
+		cmd.Env = append(os.Environ(), "CI=1")
+		output, err := cmd.Output()
+		if err != nil {
+			t.Fatalf("history %v failed: %v", args, err)
+		}
+
+		var result struct {
+			Events []struct {
+				RunID string `json:"run_id"`
+			} `json:"events"`
+		}
+		if err := json.Unmarshal(output, &result); err != nil {
+			t.Fatalf("Expected valid JSON output, got parse error: %v\nOutput: %s", err, output)
+		}
+		runIDs := make([]string, len(result.Events))
+		for i, event := range result.Events {
+			runIDs[i] = event.RunID
+		}
+		return runIDs
+	}
+
+	if got := history(); len(got) != 3 || got[0] != "r2" || got[2] != "r3" {
+		t.Errorf("Expected chronological events without baseline, got %v", got)
+	}
+	if got := history("--since", "2026-03-01", "--until", "2026-03-05", "--language", "go"); len(got) != 1 || got[0] != "r2" {
+		t.Errorf("Expected the Go star of March 5, got %v", got)
+	}
+	if got := history("--event", "unstar,baseline"); len(got) != 2 || got[0] != "r1" || got[1] != "r3" {
+		t.Errorf("Expected baseline and unstar events, got %v", got)
+	}
+}